@@ -26,6 +26,7 @@ import (
 	"github.com/prakyathpnayak/roognis/internal/db"
 	"github.com/prakyathpnayak/roognis/internal/handler"
 	"github.com/prakyathpnayak/roognis/internal/middleware"
+	"github.com/prakyathpnayak/roognis/internal/models"
 	"github.com/prakyathpnayak/roognis/internal/service"
 )
 
@@ -83,21 +84,44 @@ func main() {
 	}
 
 	// ── Services ────────────────────────────────────────────────────
-	cache := service.NewCache(rdb, cfg.CacheTTL)
+	cache := service.NewCache(rdb, cfg.CacheTTL, cfg.CacheStaleTTL, service.NewNormalizer(cfg), cfg.CacheLocalMaxBytes)
 	llm := service.NewLLM(cfg)
+	modelRegistry, err := service.NewModelRegistry(cfg, llm.Providers())
+	if err != nil {
+		slog.Error("failed to load model registry", "error", err)
+		os.Exit(1)
+	}
+	tools := service.NewToolRegistry(
+		service.CalculatorTool{},
+		service.NewHistoryLookupTool(pool),
+	)
 	ctxInjector := service.NewContextInjector()
-	orchestrator := service.NewOrchestrator(llm, cache, ctxInjector, pool)
+	tokenizers := service.NewTokenizers(cfg)
+	summarizer := service.NewSummarizer(llm, pool, cfg)
+	titler := service.NewTitler(llm, pool, cfg)
+	similarCache := service.NewSimilarityCache(cfg, llm, pool, cache)
+	cachePolicy, err := service.NewCachePolicy(cfg)
+	if err != nil {
+		slog.Error("failed to load cache policy", "error", err)
+		os.Exit(1)
+	}
+	orchestrator := service.NewOrchestrator(llm, modelRegistry, tools, tokenizers, cache, similarCache, cachePolicy, ctxInjector, summarizer, titler, pool)
+	streams := service.NewStreams(orchestrator, service.NewStreamHub(rdb, cfg.StreamRetention), cfg)
 	authSvc := service.NewAuth(pool)
+	apiKeys := service.NewAPIKeys(pool)
 
 	// ── Handlers ────────────────────────────────────────────────────
-	healthHandler := handler.NewHealth(pool, cache)
+	healthHandler := handler.NewHealth(pool, cache, llm)
 	authHandler := handler.NewAuthHandler(authSvc, cfg)
-	inferenceHandler := handler.NewInferenceHandler(orchestrator)
+	inferenceHandler := handler.NewInferenceHandler(orchestrator, streams, cfg)
 	attachmentHandler := handler.NewAttachmentHandler()
+	apiKeyHandler := handler.NewAPIKeyHandler(apiKeys)
+	openAIHandler := handler.NewOpenAIHandler(orchestrator)
 
 	// ── Middleware ───────────────────────────────────────────────────
 	rateLimiter := middleware.NewRateLimiter(rdb, cfg.RateLimitRPM)
 	authMiddleware := middleware.Auth(cfg, pool)
+	apiKeyMiddleware := middleware.APIKeyAuth(apiKeys)
 
 	// ── Router ──────────────────────────────────────────────────────
 	mux := http.NewServeMux()
@@ -110,9 +134,26 @@ func main() {
 	// Protected routes
 	protectedMux := http.NewServeMux()
 	protectedMux.HandleFunc("GET /api/v1/auth/me", authHandler.Me)
+	protectedMux.HandleFunc("POST /api/v1/api-keys", apiKeyHandler.Create)
+	protectedMux.HandleFunc("GET /api/v1/api-keys", apiKeyHandler.List)
+	protectedMux.HandleFunc("DELETE /api/v1/api-keys/{id}", apiKeyHandler.Revoke)
 	protectedMux.HandleFunc("POST /api/v1/inference/complete", inferenceHandler.Complete)
+	protectedMux.HandleFunc("GET /api/v1/inference/streams/{message_id}", inferenceHandler.ResumeStream)
+	protectedMux.HandleFunc("GET /api/v1/inference/ws", inferenceHandler.InferenceSocket)
+	protectedMux.HandleFunc("POST /api/v1/inference/{message_id}/cancel", inferenceHandler.CancelGeneration)
+	protectedMux.HandleFunc("GET /api/v1/inference/generations", inferenceHandler.Generations)
+	protectedMux.Handle("GET /api/v1/admin/generations", middleware.RequireRole(models.RoleAdmin)(http.HandlerFunc(inferenceHandler.AdminGenerations)))
+	protectedMux.HandleFunc("GET /api/v1/models", inferenceHandler.Models)
 	protectedMux.HandleFunc("GET /api/v1/conversations", inferenceHandler.Conversations)
+	protectedMux.HandleFunc("PATCH /api/v1/conversations/{id}", inferenceHandler.UpdateConversation)
+	protectedMux.HandleFunc("DELETE /api/v1/conversations/{id}", inferenceHandler.DeleteConversation)
+	protectedMux.HandleFunc("POST /api/v1/conversations/{id}/restore", inferenceHandler.RestoreConversation)
 	protectedMux.HandleFunc("GET /api/v1/conversations/{id}/messages", inferenceHandler.ConversationMessages)
+	protectedMux.HandleFunc("POST /api/v1/conversations/{id}/messages/{message_id}/regenerate", inferenceHandler.Regenerate)
+	protectedMux.HandleFunc("POST /api/v1/conversations/{id}/messages/{message_id}/edit", inferenceHandler.EditMessage)
+	protectedMux.HandleFunc("GET /api/v1/conversations/{id}/messages/{message_id}/branches", inferenceHandler.MessageBranches)
+	protectedMux.HandleFunc("PUT /api/v1/conversations/{id}/branch", inferenceHandler.SwitchBranch)
+	protectedMux.HandleFunc("POST /api/v1/conversations/{id}/fork", inferenceHandler.ForkConversation)
 	protectedMux.HandleFunc("GET /api/v1/conversation-messages", inferenceHandler.ConversationMessagesByQuery)
 	protectedMux.HandleFunc("GET /api/v1/architecture/attachment-points", attachmentHandler.Catalog)
 	protectedMux.HandleFunc("POST /api/v1/image/jobs", attachmentHandler.SubmitImageJob)
@@ -130,6 +171,12 @@ func main() {
 	// Wire protected routes through auth middleware
 	mux.Handle("/api/v1/", authMiddleware(protectedMux))
 
+	// OpenAI-compatible routes, authenticated with API keys
+	openAIMux := http.NewServeMux()
+	openAIMux.HandleFunc("POST /v1/chat/completions", openAIHandler.ChatCompletions)
+	openAIMux.HandleFunc("GET /v1/models", openAIHandler.Models)
+	mux.Handle("/v1/", apiKeyMiddleware(openAIMux))
+
 	// ── Build middleware chain ───────────────────────────────────────
 	// Order (outermost → innermost): Logger → CORS → RateLimiter → Router
 	var h http.Handler = mux
@@ -146,6 +193,14 @@ func main() {
 		IdleTimeout:  60 * time.Second,
 	}
 
+	// ── Background workers ──────────────────────────────────────────
+	bgCtx, stopBackground := context.WithCancel(ctx)
+	defer stopBackground()
+	summarizer.Start(bgCtx)
+	service.NewConversationJanitor(pool, cfg).Start(bgCtx)
+	streams.Listen(bgCtx)
+	cache.Listen(bgCtx)
+
 	// ── Graceful shutdown ───────────────────────────────────────────
 	done := make(chan os.Signal, 1)
 	signal.Notify(done, os.Interrupt, syscall.SIGTERM)
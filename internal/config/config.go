@@ -3,6 +3,7 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"strconv"
@@ -29,6 +30,41 @@ type Config struct {
 	RedisURL string
 	CacheTTL time.Duration
 
+	// Stampede protection: past CacheTTL an answer is still served for
+	// CacheStaleTTL while one request refreshes it, and one instance at a
+	// time regenerates a key. Its lock is renewed while it generates and
+	// expires CacheLockTimeout after a holder dies; others wait as long.
+	CacheStaleTTL    time.Duration
+	CacheLockTimeout time.Duration
+
+	// Cache policy (JSON file, see CachePolicy): which requests are cached
+	// and for how long. When unset, every request is cached for CacheTTL.
+	CachePolicyPath string
+
+	// In-process cache tier in front of Redis, bounded by size in bytes.
+	// 0 disables it.
+	CacheLocalMaxBytes int64
+
+	// Cache key normalization: prompts differing only in Unicode form,
+	// spacing or repeated "?" and "!" share a cache entry. Case folding,
+	// stopword removal and number canonicalization widen that further.
+	CacheNormalize          bool
+	CacheNormalizeCase      bool
+	CacheNormalizeStopwords bool
+	CacheNormalizeNumbers   bool
+
+	// Similarity cache tier: single-turn prompts whose embedding is within
+	// SemanticCacheThreshold (cosine) of a cached one get its answer.
+	// Backend is "memory" (per instance) or "pgvector"; scope is "user" or
+	// "global" (shared between users).
+	SemanticCacheEnabled    bool
+	SemanticCacheBackend    string
+	SemanticCacheScope      string
+	SemanticCacheThreshold  float64
+	SemanticCacheEmbedModel string // defaults to LLM_EMBED_MODEL
+	SemanticCacheMaxEntries int    // memory backend only
+	SemanticCacheLogPrompts bool   // log compared prompt pairs at debug level
+
 	// LLM
 	LLMModel          string
 	LLMAPIKey         string
@@ -36,6 +72,68 @@ type Config struct {
 	LLMTemperature    float64
 	LLMMaxTokens      int
 	LLMTimeoutSeconds int
+	LLMEmbedModel     string
+	LLMContextWindow  int
+	LLMTokenizerPath  string // tiktoken rank file (cl100k_base.tiktoken); heuristic counting when unset
+
+	// LLM HTTP transport (shared by streaming and non-streaming calls)
+	LLMMaxIdleConns          int
+	LLMMaxIdleConnsPerHost   int
+	LLMIdleConnTimeout       time.Duration
+	LLMDialTimeout           time.Duration
+	LLMTLSHandshakeTimeout   time.Duration
+	LLMResponseHeaderTimeout time.Duration
+
+	// LLM resilience (retries + circuit breaker, per provider)
+	LLMMaxRetries       int
+	LLMRetryBaseDelay   time.Duration
+	LLMRetryMaxDelay    time.Duration
+	LLMBreakerThreshold int // consecutive failures before the breaker opens
+	LLMBreakerCooldown  time.Duration
+
+	// Model routing table (JSON file, see ModelTable). When unset, only
+	// LLM_MODEL is allowed, for every role.
+	ModelsConfigPath string
+
+	// LLM providers
+	LLMProvider       string          // default provider for unrouted models
+	LLMProviderRoutes []ProviderRoute // model-name glob → provider
+	OllamaAPIBase     string
+	AnthropicAPIKey   string
+	AnthropicAPIBase  string
+	AnthropicVersion  string
+
+	// Tool calling
+	LLMToolsEnabled      bool // default for models that do not set "tools"
+	LLMMaxToolIterations int  // model → tool round trips per request
+
+	// Structured output: re-prompts after the model returns JSON that does
+	// not match the requested schema
+	LLMJSONMaxRetries int
+
+	// Rolling conversation summaries. A conversation is summarized once more
+	// than LLMSummaryThreshold messages are not yet covered by a summary;
+	// the newest LLMSummaryKeepRecent stay verbatim. 0 disables.
+	LLMSummaryThreshold  int
+	LLMSummaryKeepRecent int
+	LLMSummaryModel      string // defaults to LLM_MODEL
+	LLMSummaryMaxTokens  int
+
+	// Conversation titles generated after the first reply
+	LLMTitleEnabled bool
+	LLMTitleModel   string // defaults to LLM_MODEL
+
+	// Conversations: deleted ones can be restored for ConversationRestoreWindow,
+	// then are purged; the purge runs every ConversationPurgeInterval.
+	ConversationRestoreWindow time.Duration
+	ConversationPurgeInterval time.Duration
+
+	// Streaming: idle SSE streams get a comment line every SSEHeartbeatInterval.
+	// A generation outlives its client by StreamResumeGrace, and its events can
+	// be resumed for StreamRetention after it ends.
+	SSEHeartbeatInterval time.Duration
+	StreamResumeGrace    time.Duration
+	StreamRetention      time.Duration
 
 	// Rate Limiting
 	RateLimitRPM int
@@ -44,6 +142,12 @@ type Config struct {
 	CORSOrigins []string
 }
 
+// ProviderRoute maps a model-name glob (e.g. "claude-*") to a provider name.
+type ProviderRoute struct {
+	Pattern  string
+	Provider string
+}
+
 // Load reads configuration from environment variables with sensible defaults.
 func Load() *Config {
 	return &Config{
@@ -60,12 +164,77 @@ func Load() *Config {
 		RedisURL: envOrDefault("REDIS_URL", "redis://:roognis_redis_secret@localhost:6379/0"),
 		CacheTTL: time.Duration(envOrDefaultInt("CACHE_TTL_SECONDS", 3600)) * time.Second,
 
+		CacheStaleTTL:    time.Duration(envOrDefaultInt("CACHE_STALE_SECONDS", 300)) * time.Second,
+		CacheLockTimeout: time.Duration(max(envOrDefaultInt("CACHE_LOCK_SECONDS", 30), 1)) * time.Second,
+
+		CachePolicyPath: envOrDefault("CACHE_POLICY_PATH", ""),
+
+		CacheLocalMaxBytes: int64(envOrDefaultInt("CACHE_LOCAL_MAX_MB", 64)) << 20,
+
+		CacheNormalize:          envOrDefaultBool("CACHE_NORMALIZE", true),
+		CacheNormalizeCase:      envOrDefaultBool("CACHE_NORMALIZE_CASE", false),
+		CacheNormalizeStopwords: envOrDefaultBool("CACHE_NORMALIZE_STOPWORDS", false),
+		CacheNormalizeNumbers:   envOrDefaultBool("CACHE_NORMALIZE_NUMBERS", false),
+
+		SemanticCacheEnabled:    envOrDefaultBool("SEMANTIC_CACHE_ENABLED", false),
+		SemanticCacheBackend:    envOrDefault("SEMANTIC_CACHE_BACKEND", "memory"),
+		SemanticCacheScope:      envOrDefault("SEMANTIC_CACHE_SCOPE", "user"),
+		SemanticCacheThreshold:  envOrDefaultFloat("SEMANTIC_CACHE_THRESHOLD", 0.92),
+		SemanticCacheEmbedModel: envOrDefault("SEMANTIC_CACHE_EMBED_MODEL", ""),
+		SemanticCacheMaxEntries: envOrDefaultInt("SEMANTIC_CACHE_MAX_ENTRIES", 10000),
+		SemanticCacheLogPrompts: envOrDefaultBool("SEMANTIC_CACHE_LOG_PROMPTS", false),
+
 		LLMModel:          envOrDefault("LLM_MODEL", "gpt-4o-mini"),
 		LLMAPIKey:         envOrDefault("LLM_API_KEY", ""),
 		LLMAPIBase:        envOrDefault("LLM_API_BASE", "https://api.openai.com/v1"),
 		LLMTemperature:    envOrDefaultFloat("LLM_TEMPERATURE", 0.7),
 		LLMMaxTokens:      envOrDefaultInt("LLM_MAX_TOKENS", 2048),
 		LLMTimeoutSeconds: envOrDefaultInt("LLM_TIMEOUT_SECONDS", 60),
+		LLMEmbedModel:     envOrDefault("LLM_EMBED_MODEL", "text-embedding-3-small"),
+		LLMContextWindow:  envOrDefaultInt("LLM_CONTEXT_WINDOW", 8192),
+		LLMTokenizerPath:  envOrDefault("LLM_TOKENIZER_PATH", ""),
+
+		LLMMaxIdleConns:          envOrDefaultInt("LLM_MAX_IDLE_CONNS", 100),
+		LLMMaxIdleConnsPerHost:   envOrDefaultInt("LLM_MAX_IDLE_CONNS_PER_HOST", 32),
+		LLMIdleConnTimeout:       time.Duration(envOrDefaultInt("LLM_IDLE_CONN_TIMEOUT_SECONDS", 90)) * time.Second,
+		LLMDialTimeout:           time.Duration(envOrDefaultInt("LLM_DIAL_TIMEOUT_SECONDS", 10)) * time.Second,
+		LLMTLSHandshakeTimeout:   time.Duration(envOrDefaultInt("LLM_TLS_HANDSHAKE_TIMEOUT_SECONDS", 10)) * time.Second,
+		LLMResponseHeaderTimeout: time.Duration(envOrDefaultInt("LLM_RESPONSE_HEADER_TIMEOUT_SECONDS", 30)) * time.Second,
+
+		LLMMaxRetries:       envOrDefaultInt("LLM_MAX_RETRIES", 2),
+		LLMRetryBaseDelay:   time.Duration(envOrDefaultInt("LLM_RETRY_BASE_MS", 250)) * time.Millisecond,
+		LLMRetryMaxDelay:    time.Duration(envOrDefaultInt("LLM_RETRY_MAX_MS", 5000)) * time.Millisecond,
+		LLMBreakerThreshold: envOrDefaultInt("LLM_BREAKER_THRESHOLD", 5),
+		LLMBreakerCooldown:  time.Duration(envOrDefaultInt("LLM_BREAKER_COOLDOWN_SECONDS", 30)) * time.Second,
+
+		ModelsConfigPath: envOrDefault("MODELS_CONFIG_PATH", ""),
+
+		LLMProvider:       envOrDefault("LLM_PROVIDER", "openai"),
+		LLMProviderRoutes: parseProviderRoutes(envOrDefault("LLM_MODEL_PROVIDERS", "")),
+		OllamaAPIBase:     envOrDefault("OLLAMA_API_BASE", ""),
+		AnthropicAPIKey:   envOrDefault("ANTHROPIC_API_KEY", ""),
+		AnthropicAPIBase:  envOrDefault("ANTHROPIC_API_BASE", "https://api.anthropic.com/v1"),
+		AnthropicVersion:  envOrDefault("ANTHROPIC_VERSION", "2023-06-01"),
+
+		LLMToolsEnabled:      envOrDefaultBool("LLM_TOOLS_ENABLED", false),
+		LLMMaxToolIterations: envOrDefaultInt("LLM_MAX_TOOL_ITERATIONS", 5),
+
+		LLMJSONMaxRetries: envOrDefaultInt("LLM_JSON_MAX_RETRIES", 2),
+
+		LLMSummaryThreshold:  envOrDefaultInt("LLM_SUMMARY_THRESHOLD", 40),
+		LLMSummaryKeepRecent: envOrDefaultInt("LLM_SUMMARY_KEEP_RECENT", 16),
+		LLMSummaryModel:      envOrDefault("LLM_SUMMARY_MODEL", ""),
+		LLMSummaryMaxTokens:  envOrDefaultInt("LLM_SUMMARY_MAX_TOKENS", 512),
+
+		LLMTitleEnabled: envOrDefaultBool("LLM_TITLE_ENABLED", true),
+		LLMTitleModel:   envOrDefault("LLM_TITLE_MODEL", ""),
+
+		ConversationRestoreWindow: time.Duration(envOrDefaultInt("CONVERSATION_RESTORE_WINDOW_HOURS", 720)) * time.Hour,
+		ConversationPurgeInterval: time.Duration(envOrDefaultInt("CONVERSATION_PURGE_INTERVAL_MINUTES", 60)) * time.Minute,
+
+		SSEHeartbeatInterval: time.Duration(envOrDefaultInt("SSE_HEARTBEAT_SECONDS", 15)) * time.Second,
+		StreamResumeGrace:    time.Duration(envOrDefaultInt("STREAM_RESUME_GRACE_SECONDS", 60)) * time.Second,
+		StreamRetention:      time.Duration(envOrDefaultInt("STREAM_RETENTION_SECONDS", 300)) * time.Second,
 
 		RateLimitRPM: envOrDefaultInt("RATE_LIMIT_RPM", 60),
 
@@ -73,6 +242,153 @@ func Load() *Config {
 	}
 }
 
+// ModelConfig declares one model in the routing table.
+type ModelConfig struct {
+	Name          string   `json:"name"`
+	Provider      string   `json:"provider,omitempty"` // pins the model to a provider, overriding LLM_MODEL_PROVIDERS
+	Description   string   `json:"description,omitempty"`
+	Roles         []string `json:"roles,omitempty"` // empty = every role
+	Temperature   *float64 `json:"temperature,omitempty"`
+	MaxTokens     *int     `json:"max_tokens,omitempty"`
+	ContextWindow int      `json:"context_window,omitempty"`
+	Tokenizer     string   `json:"tokenizer,omitempty"` // "cl100k" or "heuristic"; default cl100k when loaded
+	Fallbacks     []string `json:"fallbacks,omitempty"` // tried in order on 5xx / timeout
+	Tools         *bool    `json:"tools,omitempty"`     // offer server-side tools; nil = LLM_TOOLS_ENABLED
+}
+
+// ModelTable is the on-disk format of MODELS_CONFIG_PATH.
+type ModelTable struct {
+	Default string        `json:"default"`
+	Models  []ModelConfig `json:"models"`
+}
+
+// LoadModelTable reads and validates a model routing table.
+func LoadModelTable(path string) (*ModelTable, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config: read model table: %w", err)
+	}
+
+	var table ModelTable
+	if err := json.Unmarshal(data, &table); err != nil {
+		return nil, fmt.Errorf("config: parse model table: %w", err)
+	}
+
+	names := make(map[string]bool, len(table.Models))
+	for _, m := range table.Models {
+		if m.Name == "" {
+			return nil, fmt.Errorf("config: model table: entry without name")
+		}
+		if names[m.Name] {
+			return nil, fmt.Errorf("config: model table: duplicate model %q", m.Name)
+		}
+		names[m.Name] = true
+	}
+	for _, m := range table.Models {
+		for _, fb := range m.Fallbacks {
+			if !names[fb] {
+				return nil, fmt.Errorf("config: model table: %q falls back to undeclared model %q", m.Name, fb)
+			}
+		}
+	}
+	if table.Default == "" && len(table.Models) > 0 {
+		table.Default = table.Models[0].Name
+	}
+	if table.Default != "" && !names[table.Default] {
+		return nil, fmt.Errorf("config: model table: default model %q is not declared", table.Default)
+	}
+	return &table, nil
+}
+
+// CachePolicy is the on-disk format of CACHE_POLICY_PATH. Rules are tried
+// in order and the first whose conditions all hold decides; requests that
+// match no rule are cached for CACHE_TTL_SECONDS.
+type CachePolicy struct {
+	Rules []CacheRule `json:"rules"`
+}
+
+// CacheRule decides cacheability and TTL for the requests it matches.
+type CacheRule struct {
+	Name       string              `json:"name"`
+	Match      CacheMatch          `json:"match"`
+	Cache      *bool               `json:"cache,omitempty"`       // nil = true
+	TTLSeconds int                 `json:"ttl_seconds,omitempty"` // 0 = CACHE_TTL_SECONDS
+	Normalize  *CacheNormalization `json:"normalize,omitempty"`   // nil = CACHE_NORMALIZE*
+}
+
+// CacheMatch holds the conditions of a rule; unset ones always hold.
+type CacheMatch struct {
+	Routes         []string `json:"routes,omitempty"` // CacheRoute* values
+	Models         []string `json:"models,omitempty"`
+	Roles          []string `json:"roles,omitempty"`
+	MinTemperature *float64 `json:"min_temperature,omitempty"`
+	MaxTemperature *float64 `json:"max_temperature,omitempty"`
+	MinPromptChars int      `json:"min_prompt_chars,omitempty"`
+	MaxPromptChars int      `json:"max_prompt_chars,omitempty"`
+	MinDepth       int      `json:"min_depth,omitempty"` // earlier user and assistant messages
+	MaxDepth       *int     `json:"max_depth,omitempty"`
+}
+
+// Request routes a cache rule can match.
+const (
+	CacheRouteComplete        = "complete"         // POST /inference
+	CacheRouteStream          = "stream"           // streamed inference, SSE or WebSocket
+	CacheRouteChatCompletions = "chat_completions" // OpenAI-compatible facade
+)
+
+// CacheNormalization overrides the CACHE_NORMALIZE_* options for a rule's
+// cache keys.
+type CacheNormalization struct {
+	Case      bool `json:"case,omitempty"`
+	Stopwords bool `json:"stopwords,omitempty"`
+	Numbers   bool `json:"numbers,omitempty"`
+}
+
+// LoadCachePolicy reads and validates a cache policy.
+func LoadCachePolicy(path string) (*CachePolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config: read cache policy: %w", err)
+	}
+
+	var policy CachePolicy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("config: parse cache policy: %w", err)
+	}
+
+	names := make(map[string]bool, len(policy.Rules))
+	for _, r := range policy.Rules {
+		if r.Name == "" {
+			return nil, fmt.Errorf("config: cache policy: rule without name")
+		}
+		if names[r.Name] {
+			return nil, fmt.Errorf("config: cache policy: duplicate rule %q", r.Name)
+		}
+		names[r.Name] = true
+		if r.TTLSeconds < 0 {
+			return nil, fmt.Errorf("config: cache policy: rule %q has a negative ttl", r.Name)
+		}
+		m := r.Match
+		for _, route := range m.Routes {
+			switch route {
+			case CacheRouteComplete, CacheRouteStream, CacheRouteChatCompletions:
+			default:
+				return nil, fmt.Errorf("config: cache policy: rule %q: unknown route %q", r.Name, route)
+			}
+		}
+		if m.MinTemperature != nil && m.MaxTemperature != nil && *m.MinTemperature > *m.MaxTemperature {
+			return nil, fmt.Errorf("config: cache policy: rule %q: min_temperature is above max_temperature", r.Name)
+		}
+		if m.MaxPromptChars > 0 && m.MinPromptChars > m.MaxPromptChars {
+			return nil, fmt.Errorf("config: cache policy: rule %q: min_prompt_chars is above max_prompt_chars", r.Name)
+		}
+		if m.MaxDepth != nil && m.MinDepth > *m.MaxDepth {
+			return nil, fmt.Errorf("config: cache policy: rule %q: min_depth is above max_depth", r.Name)
+		}
+	}
+	return &policy, nil
+}
+
 // IsDevelopment returns true when running in development mode.
 func (c *Config) IsDevelopment() bool {
 	return c.AppEnv == "development"
@@ -85,7 +401,7 @@ func (c *Config) Validate() {
 		if c.JWTSecret == "CHANGE_ME_generate_with_openssl_rand_hex_32" || len(c.JWTSecret) < 32 {
 			panic("FATAL: JWT_SECRET must be set to a random value >= 32 chars in non-development mode")
 		}
-		if c.LLMAPIKey == "" {
+		if c.LLMProvider == "openai" && c.LLMAPIKey == "" {
 			panic("FATAL: LLM_API_KEY must be set in non-development mode")
 		}
 	}
@@ -127,6 +443,23 @@ func LoadDotEnv(path string) error {
 	return nil
 }
 
+// parseProviderRoutes parses "pattern=provider" pairs separated by commas,
+// preserving their order (first match wins).
+func parseProviderRoutes(raw string) []ProviderRoute {
+	var routes []ProviderRoute
+	for _, pair := range strings.Split(raw, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			continue
+		}
+		routes = append(routes, ProviderRoute{
+			Pattern:  strings.TrimSpace(parts[0]),
+			Provider: strings.TrimSpace(parts[1]),
+		})
+	}
+	return routes
+}
+
 func envOrDefault(key, fallback string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
@@ -151,3 +484,12 @@ func envOrDefaultFloat(key string, fallback float64) float64 {
 	}
 	return fallback
 }
+
+func envOrDefaultBool(key string, fallback bool) bool {
+	if v := os.Getenv(key); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return fallback
+}
@@ -1,6 +1,10 @@
 package config
 
-import "testing"
+import (
+	"os"
+	"testing"
+	"time"
+)
 
 func TestValidatePanicsInProductionWithDefaultJWTSecret(t *testing.T) {
 	t.Setenv("APP_ENV", "production")
@@ -62,3 +66,59 @@ func TestValidateDoesNotPanicInDevelopment(t *testing.T) {
 		cfg.Validate()
 	}()
 }
+
+func TestLoadKeepsCacheLockTimeoutPositive(t *testing.T) {
+	for _, v := range []string{"0", "-5"} {
+		t.Setenv("CACHE_LOCK_SECONDS", v)
+		if got := Load().CacheLockTimeout; got != time.Second {
+			t.Fatalf("CACHE_LOCK_SECONDS=%s: expected 1s, got %v", v, got)
+		}
+	}
+}
+
+func TestLoadModelTableRejectsUndeclaredFallback(t *testing.T) {
+	path := t.TempDir() + "/models.json"
+	table := `{"models":[{"name":"a","fallbacks":["missing"]}]}`
+	if err := os.WriteFile(path, []byte(table), 0o600); err != nil {
+		t.Fatalf("write table: %v", err)
+	}
+
+	if _, err := LoadModelTable(path); err == nil {
+		t.Fatal("expected error for fallback to undeclared model")
+	}
+}
+
+func TestLoadModelTableDefaultsToFirstModel(t *testing.T) {
+	path := t.TempDir() + "/models.json"
+	table := `{"models":[{"name":"a"},{"name":"b","fallbacks":["a"]}]}`
+	if err := os.WriteFile(path, []byte(table), 0o600); err != nil {
+		t.Fatalf("write table: %v", err)
+	}
+
+	got, err := LoadModelTable(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Default != "a" {
+		t.Fatalf("expected default %q, got %q", "a", got.Default)
+	}
+}
+
+func TestLoadCachePolicyRejectsInvalidRules(t *testing.T) {
+	for _, policy := range []string{
+		`{"rules":[{"match":{}}]}`,
+		`{"rules":[{"name":"a"},{"name":"a"}]}`,
+		`{"rules":[{"name":"a","ttl_seconds":-1}]}`,
+		`{"rules":[{"name":"a","match":{"min_temperature":1,"max_temperature":0.5}}]}`,
+		`{"rules":[{"name":"a","match":{"min_depth":3,"max_depth":1}}]}`,
+		`{"rules":[{"name":"a","match":{"routes":["completions"]}}]}`,
+	} {
+		path := t.TempDir() + "/cache_policy.json"
+		if err := os.WriteFile(path, []byte(policy), 0o600); err != nil {
+			t.Fatalf("write policy: %v", err)
+		}
+		if _, err := LoadCachePolicy(path); err == nil {
+			t.Fatalf("expected error for %s", policy)
+		}
+	}
+}
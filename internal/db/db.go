@@ -6,7 +6,9 @@ import (
 	"context"
 	"embed"
 	"fmt"
+	"io/fs"
 	"log/slog"
+	"sort"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -47,19 +49,27 @@ func New(ctx context.Context, dsn string) (*Pool, error) {
 	return &Pool{pool}, nil
 }
 
-// RunMigrations executes the embedded SQL migration files.
+// RunMigrations executes the embedded *.up.sql migration files in order.
+// Every migration is written to be idempotent, so all of them run on boot.
 // In production, use golang-migrate CLI. This is a bootstrap convenience.
 func (p *Pool) RunMigrations(ctx context.Context) error {
-	data, err := MigrationsFS.ReadFile("migrations/000001_init.up.sql")
+	files, err := fs.Glob(MigrationsFS, "migrations/*.up.sql")
 	if err != nil {
-		return fmt.Errorf("db: read migration: %w", err)
+		return fmt.Errorf("db: list migrations: %w", err)
 	}
+	sort.Strings(files)
 
-	_, err = p.Exec(ctx, string(data))
-	if err != nil {
-		return fmt.Errorf("db: run migration: %w", err)
+	for _, name := range files {
+		data, err := MigrationsFS.ReadFile(name)
+		if err != nil {
+			return fmt.Errorf("db: read migration %s: %w", name, err)
+		}
+
+		if _, err := p.Exec(ctx, string(data)); err != nil {
+			return fmt.Errorf("db: run migration %s: %w", name, err)
+		}
 	}
 
-	slog.Info("database migrations applied")
+	slog.Info("database migrations applied", "count", len(files))
 	return nil
 }
@@ -0,0 +1,5 @@
+-- 000002_tool_messages.down.sql
+-- PostgreSQL cannot drop an enum value; 'tool' stays on message_role.
+DELETE FROM messages WHERE role = 'tool';
+ALTER TABLE messages DROP COLUMN IF EXISTS tool_call_id;
+ALTER TABLE messages DROP COLUMN IF EXISTS tool_calls;
@@ -0,0 +1,7 @@
+-- 000002_tool_messages.up.sql
+-- Function calling: tool role, assistant tool calls and tool results.
+
+ALTER TYPE message_role ADD VALUE IF NOT EXISTS 'tool';
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS tool_calls   JSONB;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS tool_call_id VARCHAR(128);
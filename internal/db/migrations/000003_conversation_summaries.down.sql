@@ -0,0 +1,2 @@
+-- 000003_conversation_summaries.down.sql
+DROP TABLE IF EXISTS conversation_summaries;
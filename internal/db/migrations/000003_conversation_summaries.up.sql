@@ -0,0 +1,18 @@
+-- 000003_conversation_summaries.up.sql
+-- Rolling summaries of conversation turns that fell out of the history window.
+
+CREATE TABLE IF NOT EXISTS conversation_summaries (
+    id               UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    conversation_id  UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    content          TEXT NOT NULL,
+    first_message_id UUID NOT NULL,
+    last_message_id  UUID NOT NULL,
+    covered_until    TIMESTAMPTZ NOT NULL, -- created_at of last_message_id
+    message_count    INT NOT NULL,         -- messages covered, cumulative
+    model_used       VARCHAR(128),
+    token_count      INT,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_conversation_summaries_conv_created
+    ON conversation_summaries (conversation_id, created_at DESC);
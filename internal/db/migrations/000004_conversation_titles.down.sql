@@ -0,0 +1,2 @@
+-- 000004_conversation_titles.down.sql
+ALTER TABLE conversations DROP COLUMN IF EXISTS title_set_by_user;
@@ -0,0 +1,4 @@
+-- 000004_conversation_titles.up.sql
+-- Generated titles never overwrite a title the user chose.
+
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS title_set_by_user BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,6 @@
+-- 000005_conversation_management.down.sql
+DROP INDEX IF EXISTS idx_conversations_deleted;
+DROP INDEX IF EXISTS idx_conversations_user_list;
+ALTER TABLE conversations DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE conversations DROP COLUMN IF EXISTS archived_at;
+ALTER TABLE conversations DROP COLUMN IF EXISTS pinned;
@@ -0,0 +1,13 @@
+-- 000005_conversation_management.up.sql
+-- Pinning, archiving and soft deletion of conversations.
+
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS pinned      BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ;
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS deleted_at  TIMESTAMPTZ;
+
+-- Keyset pagination order for the conversation list
+CREATE INDEX IF NOT EXISTS idx_conversations_user_list
+    ON conversations (user_id, pinned DESC, updated_at DESC, id DESC);
+
+CREATE INDEX IF NOT EXISTS idx_conversations_deleted
+    ON conversations (deleted_at) WHERE deleted_at IS NOT NULL;
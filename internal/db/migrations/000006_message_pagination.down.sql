@@ -0,0 +1,2 @@
+-- 000006_message_pagination.down.sql
+DROP INDEX IF EXISTS idx_messages_conv_created_id;
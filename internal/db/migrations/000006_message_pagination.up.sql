@@ -0,0 +1,4 @@
+-- 000006_message_pagination.up.sql
+-- Keyset pagination of conversation history on (created_at, id).
+
+CREATE INDEX IF NOT EXISTS idx_messages_conv_created_id ON messages (conversation_id, created_at, id);
@@ -0,0 +1,4 @@
+-- 000007_message_branches.down.sql
+DROP INDEX IF EXISTS idx_messages_parent;
+ALTER TABLE conversations DROP COLUMN IF EXISTS active_leaf_id;
+ALTER TABLE messages DROP COLUMN IF EXISTS parent_id;
@@ -0,0 +1,42 @@
+-- 000007_message_branches.up.sql
+-- Messages form a tree: regenerating an answer or editing a prompt adds a
+-- sibling branch. Each conversation follows the branch ending at its active
+-- leaf.
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'messages' AND column_name = 'parent_id'
+    ) THEN
+        ALTER TABLE messages ADD COLUMN parent_id UUID REFERENCES messages(id) ON DELETE CASCADE;
+
+        -- Existing history is a single branch in creation order.
+        UPDATE messages m SET parent_id = x.prev
+        FROM (
+            SELECT id, lag(id) OVER (PARTITION BY conversation_id ORDER BY created_at, id) AS prev
+            FROM messages
+        ) x
+        WHERE m.id = x.id AND x.prev IS NOT NULL;
+    END IF;
+
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'conversations' AND column_name = 'active_leaf_id'
+    ) THEN
+        ALTER TABLE conversations ADD COLUMN active_leaf_id UUID REFERENCES messages(id) ON DELETE SET NULL;
+
+        -- Keep updated_at (the list order) as it was.
+        ALTER TABLE conversations DISABLE TRIGGER set_updated_at_conversations;
+        UPDATE conversations c SET active_leaf_id = (
+            SELECT id FROM messages m
+            WHERE m.conversation_id = c.id
+            ORDER BY created_at DESC, id DESC
+            LIMIT 1
+        );
+        ALTER TABLE conversations ENABLE TRIGGER set_updated_at_conversations;
+    END IF;
+END
+$$;
+
+CREATE INDEX IF NOT EXISTS idx_messages_parent ON messages (parent_id);
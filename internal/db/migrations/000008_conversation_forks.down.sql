@@ -0,0 +1,3 @@
+-- 000008_conversation_forks.down.sql
+ALTER TABLE conversations DROP COLUMN IF EXISTS forked_from_message_id;
+ALTER TABLE conversations DROP COLUMN IF EXISTS forked_from_conversation_id;
@@ -0,0 +1,5 @@
+-- 000008_conversation_forks.up.sql
+-- A conversation forked from another records where it was copied from.
+
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS forked_from_conversation_id UUID REFERENCES conversations(id) ON DELETE SET NULL;
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS forked_from_message_id      UUID REFERENCES messages(id) ON DELETE SET NULL;
@@ -0,0 +1,6 @@
+-- 000009_message_outcomes.down.sql
+ALTER TABLE messages DROP COLUMN IF EXISTS completion_tokens;
+ALTER TABLE messages DROP COLUMN IF EXISTS prompt_tokens;
+ALTER TABLE messages DROP COLUMN IF EXISTS ttft_ms;
+ALTER TABLE messages DROP COLUMN IF EXISTS finish_reason;
+ALTER TABLE messages DROP COLUMN IF EXISTS status;
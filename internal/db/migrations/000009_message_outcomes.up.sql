@@ -0,0 +1,10 @@
+-- 000009_message_outcomes.up.sql
+-- How each answer ended, its timing and token usage. Streamed turns are
+-- stored even when the client disconnects or the upstream fails.
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'complete'
+    CHECK (status IN ('complete', 'cancelled', 'error'));
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS finish_reason     VARCHAR(32);
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS ttft_ms           DOUBLE PRECISION;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS prompt_tokens     INT;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS completion_tokens INT;
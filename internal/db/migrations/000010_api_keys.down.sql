@@ -0,0 +1,2 @@
+-- 000010_api_keys.down.sql
+DROP TABLE IF EXISTS api_keys;
@@ -0,0 +1,17 @@
+-- 000010_api_keys.up.sql
+-- API keys for the OpenAI-compatible endpoints. Only a SHA-256 hash of each
+-- key is stored; the key itself is shown once, when it is created.
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id           UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id      UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name         VARCHAR(100) NOT NULL,
+    prefix       VARCHAR(16) NOT NULL, -- first characters of the key, to tell keys apart
+    key_hash     CHAR(64) NOT NULL UNIQUE,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_used_at TIMESTAMPTZ,
+    revoked_at   TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_user
+    ON api_keys (user_id, created_at DESC);
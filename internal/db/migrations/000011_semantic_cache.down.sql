@@ -0,0 +1,2 @@
+-- 000011_semantic_cache.down.sql
+DROP TABLE IF EXISTS semantic_cache_entries;
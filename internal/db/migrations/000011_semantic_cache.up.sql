@@ -0,0 +1,18 @@
+-- 000011_semantic_cache.up.sql
+-- Prompt embeddings of the similarity cache tier (SEMANTIC_CACHE_BACKEND=pgvector).
+-- Each row points at an answer in the response cache. The embedding has no
+-- fixed dimension, since it depends on the embedding model; the scope
+-- includes that model, so rows of one scope are always comparable.
+
+CREATE TABLE IF NOT EXISTS semantic_cache_entries (
+    id         UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    scope      CHAR(64) NOT NULL, -- hash of model, parameters, system prompt (and user)
+    prompt     TEXT NOT NULL,     -- normalized prompt that was embedded
+    cache_key  TEXT NOT NULL,     -- response cache key of the answer
+    embedding  vector NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_semantic_cache_entries_scope
+    ON semantic_cache_entries (scope, expires_at);
@@ -4,7 +4,12 @@ package db
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
@@ -75,13 +80,71 @@ func (p *Pool) CreateConversation(ctx context.Context, c *models.Conversation) e
 	return nil
 }
 
-// GetConversation fetches a single conversation by ID.
+// ForkConversation creates conversation c with a copy of path (a branch,
+// oldest first) as its history, in one transaction. The copies get new IDs
+// but keep their timestamps; the last one becomes the active leaf.
+func (p *Pool) ForkConversation(ctx context.Context, c *models.Conversation, path []models.Message) error {
+	tx, err := p.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("db.ForkConversation: begin: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO conversations (id, user_id, title, title_set_by_user, forked_from_conversation_id, forked_from_message_id)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		c.ID, c.UserID, c.Title, c.TitleSetByUser, c.ForkedFromConversationID, c.ForkedFromMessageID,
+	)
+	if err != nil {
+		return fmt.Errorf("db.ForkConversation: conversation: %w", err)
+	}
+
+	var parent *uuid.UUID
+	for _, m := range path {
+		id := uuid.New()
+		_, err := tx.Exec(ctx, `
+			INSERT INTO messages (id, conversation_id, parent_id, role, content, token_count, model_used, latency_ms,
+				status, finish_reason, ttft_ms, prompt_tokens, completion_tokens, tool_calls, tool_call_id, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)`,
+			id, c.ID, parent, m.Role, m.Content, m.TokenCount, m.ModelUsed, m.LatencyMs,
+			messageStatus(m.Status), m.FinishReason, m.TTFTMs, m.PromptTokens, m.CompletionTokens, toolCallsJSON(m.ToolCalls), m.ToolCallID, m.CreatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("db.ForkConversation: message: %w", err)
+		}
+		parent = &id
+	}
+
+	if parent != nil {
+		if _, err := tx.Exec(ctx, `UPDATE conversations SET active_leaf_id = $2 WHERE id = $1`, c.ID, *parent); err != nil {
+			return fmt.Errorf("db.ForkConversation: active leaf: %w", err)
+		}
+		c.ActiveLeafID = parent
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("db.ForkConversation: commit: %w", err)
+	}
+	return nil
+}
+
+// conversationColumns is the column list scanned by scanConversation.
+const conversationColumns = `id, user_id, title, title_set_by_user, pinned, archived_at, deleted_at, active_leaf_id,
+	forked_from_conversation_id, forked_from_message_id, created_at, updated_at`
+
+func scanConversation(row pgx.Row, c *models.Conversation) error {
+	return row.Scan(&c.ID, &c.UserID, &c.Title, &c.TitleSetByUser, &c.Pinned, &c.ArchivedAt, &c.DeletedAt, &c.ActiveLeafID,
+		&c.ForkedFromConversationID, &c.ForkedFromMessageID, &c.CreatedAt, &c.UpdatedAt)
+}
+
+// GetConversation fetches a single conversation by ID, including a
+// soft-deleted one (check DeletedAt).
 func (p *Pool) GetConversation(ctx context.Context, id uuid.UUID) (*models.Conversation, error) {
 	var c models.Conversation
-	err := p.QueryRow(ctx, `
-		SELECT id, user_id, title, created_at, updated_at
+	err := scanConversation(p.QueryRow(ctx, `
+		SELECT `+conversationColumns+`
 		FROM conversations WHERE id = $1`, id,
-	).Scan(&c.ID, &c.UserID, &c.Title, &c.CreatedAt, &c.UpdatedAt)
+	), &c)
 
 	if err == pgx.ErrNoRows {
 		return nil, nil
@@ -92,15 +155,41 @@ func (p *Pool) GetConversation(ctx context.Context, id uuid.UUID) (*models.Conve
 	return &c, nil
 }
 
-// ListConversations returns all conversations for a given user (most recent first).
-func (p *Pool) ListConversations(ctx context.Context, userID uuid.UUID) ([]models.Conversation, error) {
-	rows, err := p.Query(ctx, `
-		SELECT id, user_id, title, created_at, updated_at
+// ListConversations returns one page of a user's conversations, pinned
+// first and then most recently updated, starting after params.After.
+func (p *Pool) ListConversations(ctx context.Context, userID uuid.UUID, params models.ConversationListParams) ([]models.Conversation, error) {
+	query := `
+		SELECT ` + conversationColumns + `
 		FROM conversations
-		WHERE user_id = $1
-		ORDER BY updated_at DESC
-		LIMIT 50`, userID,
-	)
+		WHERE user_id = $1`
+	args := []any{userID}
+
+	if params.Deleted {
+		query += ` AND deleted_at IS NOT NULL`
+	} else {
+		query += ` AND deleted_at IS NULL`
+	}
+	if params.Archived != nil {
+		if *params.Archived {
+			query += ` AND archived_at IS NOT NULL`
+		} else {
+			query += ` AND archived_at IS NULL`
+		}
+	}
+	if params.Pinned != nil {
+		args = append(args, *params.Pinned)
+		query += fmt.Sprintf(` AND pinned = $%d`, len(args))
+	}
+	if params.After != nil {
+		args = append(args, params.After.Pinned, params.After.UpdatedAt, params.After.ID)
+		query += fmt.Sprintf(` AND (pinned, updated_at, id) < ($%d, $%d, $%d)`, len(args)-2, len(args)-1, len(args))
+	}
+	args = append(args, params.Limit)
+	query += fmt.Sprintf(`
+		ORDER BY pinned DESC, updated_at DESC, id DESC
+		LIMIT $%d`, len(args))
+
+	rows, err := p.Query(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("db.ListConversations: %w", err)
 	}
@@ -109,7 +198,7 @@ func (p *Pool) ListConversations(ctx context.Context, userID uuid.UUID) ([]model
 	var convos []models.Conversation
 	for rows.Next() {
 		var c models.Conversation
-		if err := rows.Scan(&c.ID, &c.UserID, &c.Title, &c.CreatedAt, &c.UpdatedAt); err != nil {
+		if err := scanConversation(rows, &c); err != nil {
 			return nil, fmt.Errorf("db.ListConversations scan: %w", err)
 		}
 		convos = append(convos, c)
@@ -117,14 +206,117 @@ func (p *Pool) ListConversations(ctx context.Context, userID uuid.UUID) ([]model
 	return convos, rows.Err()
 }
 
+// SetGeneratedConversationTitle stores a generated title. It only applies
+// while the conversation has no title, so it never overwrites one the user
+// set. Reports whether the row was updated.
+func (p *Pool) SetGeneratedConversationTitle(ctx context.Context, id uuid.UUID, title string) (bool, error) {
+	tag, err := p.Exec(ctx, `
+		UPDATE conversations SET title = $2
+		WHERE id = $1 AND title IS NULL AND NOT title_set_by_user`,
+		id, title,
+	)
+	if err != nil {
+		return false, fmt.Errorf("db.SetGeneratedConversationTitle: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// RenameConversation sets a user-chosen title and marks it as such.
+func (p *Pool) RenameConversation(ctx context.Context, id uuid.UUID, title string) error {
+	_, err := p.Exec(ctx, `
+		UPDATE conversations SET title = $2, title_set_by_user = true
+		WHERE id = $1`,
+		id, title,
+	)
+	if err != nil {
+		return fmt.Errorf("db.RenameConversation: %w", err)
+	}
+	return nil
+}
+
+// SetConversationState updates the pinned and archived flags. A nil value
+// leaves the flag unchanged; archiving keeps the original archived_at.
+func (p *Pool) SetConversationState(ctx context.Context, id uuid.UUID, pinned, archived *bool) error {
+	_, err := p.Exec(ctx, `
+		UPDATE conversations SET
+			pinned      = COALESCE($2, pinned),
+			archived_at = CASE
+				WHEN $3::boolean IS NULL THEN archived_at
+				WHEN $3::boolean THEN COALESCE(archived_at, now())
+				ELSE NULL
+			END
+		WHERE id = $1`,
+		id, pinned, archived,
+	)
+	if err != nil {
+		return fmt.Errorf("db.SetConversationState: %w", err)
+	}
+	return nil
+}
+
+// SoftDeleteConversation marks a conversation as deleted. Its messages are
+// kept until PurgeDeletedConversations removes it.
+func (p *Pool) SoftDeleteConversation(ctx context.Context, id uuid.UUID) error {
+	_, err := p.Exec(ctx, `
+		UPDATE conversations SET deleted_at = now()
+		WHERE id = $1 AND deleted_at IS NULL`, id,
+	)
+	if err != nil {
+		return fmt.Errorf("db.SoftDeleteConversation: %w", err)
+	}
+	return nil
+}
+
+// RestoreConversation clears deleted_at if the conversation was deleted after
+// notBefore. Reports whether it was restored.
+func (p *Pool) RestoreConversation(ctx context.Context, id uuid.UUID, notBefore time.Time) (bool, error) {
+	tag, err := p.Exec(ctx, `
+		UPDATE conversations SET deleted_at = NULL
+		WHERE id = $1 AND deleted_at IS NOT NULL AND deleted_at > $2`,
+		id, notBefore,
+	)
+	if err != nil {
+		return false, fmt.Errorf("db.RestoreConversation: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// PurgeDeletedConversations permanently removes conversations soft-deleted
+// before cutoff, together with their messages and summaries.
+func (p *Pool) PurgeDeletedConversations(ctx context.Context, cutoff time.Time) (int64, error) {
+	tag, err := p.Exec(ctx, `
+		DELETE FROM conversations
+		WHERE deleted_at IS NOT NULL AND deleted_at <= $1`, cutoff,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("db.PurgeDeletedConversations: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// SetActiveLeaf makes the branch ending at leafID the conversation's active
+// branch.
+func (p *Pool) SetActiveLeaf(ctx context.Context, conversationID, leafID uuid.UUID) error {
+	_, err := p.Exec(ctx, `
+		UPDATE conversations SET active_leaf_id = $2
+		WHERE id = $1`, conversationID, leafID,
+	)
+	if err != nil {
+		return fmt.Errorf("db.SetActiveLeaf: %w", err)
+	}
+	return nil
+}
+
 // ── Messages ───────────────────────────────────────────────────────
 
 // CreateMessage inserts a new message.
 func (p *Pool) CreateMessage(ctx context.Context, m *models.Message) error {
 	_, err := p.Exec(ctx, `
-		INSERT INTO messages (id, conversation_id, role, content, token_count, model_used, latency_ms)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
-		m.ID, m.ConversationID, m.Role, m.Content, m.TokenCount, m.ModelUsed, m.LatencyMs,
+		INSERT INTO messages (id, conversation_id, parent_id, role, content, token_count, model_used, latency_ms,
+			status, finish_reason, ttft_ms, prompt_tokens, completion_tokens, tool_calls, tool_call_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)`,
+		m.ID, m.ConversationID, m.ParentID, m.Role, m.Content, m.TokenCount, m.ModelUsed, m.LatencyMs,
+		messageStatus(m.Status), m.FinishReason, m.TTFTMs, m.PromptTokens, m.CompletionTokens, toolCallsJSON(m.ToolCalls), m.ToolCallID,
 	)
 	if err != nil {
 		return fmt.Errorf("db.CreateMessage: %w", err)
@@ -132,26 +324,393 @@ func (p *Pool) CreateMessage(ctx context.Context, m *models.Message) error {
 	return nil
 }
 
-// GetConversationMessages returns the messages for a conversation in chronological order.
+// activeBranch is a CTE selecting the ids on a conversation's active branch
+// ($1), from its active leaf up to the first message.
+const activeBranch = `
+	WITH RECURSIVE branch AS (
+		SELECT m.id, m.parent_id
+		FROM messages m JOIN conversations c ON c.active_leaf_id = m.id
+		WHERE c.id = $1
+		UNION ALL
+		SELECT m.id, m.parent_id
+		FROM messages m JOIN branch b ON m.id = b.parent_id
+	)`
+
+// GetConversationMessages returns the messages on a conversation's active
+// branch in chronological order.
 func (p *Pool) GetConversationMessages(ctx context.Context, conversationID uuid.UUID) ([]models.Message, error) {
-	rows, err := p.Query(ctx, `
-		SELECT id, conversation_id, role, content, token_count, model_used, latency_ms, created_at
+	rows, err := p.Query(ctx, activeBranch+`
+		SELECT `+messageColumns+`
 		FROM messages
-		WHERE conversation_id = $1
-		ORDER BY created_at ASC`, conversationID,
+		WHERE id IN (SELECT id FROM branch)
+		ORDER BY created_at ASC, id ASC`, conversationID,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("db.GetConversationMessages: %w", err)
 	}
+	return collectMessages(rows, "db.GetConversationMessages")
+}
+
+// GetMessage fetches a single message by ID (nil if not found).
+func (p *Pool) GetMessage(ctx context.Context, id uuid.UUID) (*models.Message, error) {
+	rows, err := p.Query(ctx, `
+		SELECT `+messageColumns+`
+		FROM messages WHERE id = $1`, id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("db.GetMessage: %w", err)
+	}
+	msgs, err := collectMessages(rows, "db.GetMessage")
+	if err != nil || len(msgs) == 0 {
+		return nil, err
+	}
+	return &msgs[0], nil
+}
+
+// GetMessagePath returns the branch ending at message id, from the first
+// message of the conversation through id.
+func (p *Pool) GetMessagePath(ctx context.Context, id uuid.UUID) ([]models.Message, error) {
+	rows, err := p.Query(ctx, `
+		WITH RECURSIVE path AS (
+			SELECT id, parent_id FROM messages WHERE id = $1
+			UNION ALL
+			SELECT m.id, m.parent_id FROM messages m JOIN path p ON m.id = p.parent_id
+		)
+		SELECT `+messageColumns+`
+		FROM messages
+		WHERE id IN (SELECT id FROM path)
+		ORDER BY created_at ASC, id ASC`, id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("db.GetMessagePath: %w", err)
+	}
+	return collectMessages(rows, "db.GetMessagePath")
+}
+
+// ListMessageSiblings returns the versions of message m: all messages of its
+// conversation with the same parent, oldest first, m included.
+func (p *Pool) ListMessageSiblings(ctx context.Context, m *models.Message) ([]models.Message, error) {
+	rows, err := p.Query(ctx, `
+		SELECT `+messageColumns+`
+		FROM messages
+		WHERE conversation_id = $1 AND parent_id IS NOT DISTINCT FROM $2
+		ORDER BY created_at ASC, id ASC`, m.ConversationID, m.ParentID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("db.ListMessageSiblings: %w", err)
+	}
+	return collectMessages(rows, "db.ListMessageSiblings")
+}
+
+// LatestDescendant returns the newest message in the subtree rooted at id
+// (id itself when it has no replies). Being the newest, it is a leaf.
+func (p *Pool) LatestDescendant(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+	var leaf uuid.UUID
+	err := p.QueryRow(ctx, `
+		WITH RECURSIVE sub AS (
+			SELECT id, created_at FROM messages WHERE id = $1
+			UNION ALL
+			SELECT m.id, m.created_at FROM messages m JOIN sub s ON m.parent_id = s.id
+		)
+		SELECT id FROM sub
+		ORDER BY created_at DESC, id DESC
+		LIMIT 1`, id,
+	).Scan(&leaf)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("db.LatestDescendant: %w", err)
+	}
+	return leaf, nil
+}
+
+// ListMessages returns one page of a conversation's active branch in
+// chronological order. Pages before a cursor (and the default newest page)
+// hold the latest messages preceding it; pages after a cursor or instant hold
+// the earliest messages following it.
+func (p *Pool) ListMessages(ctx context.Context, conversationID uuid.UUID, params models.MessageListParams) ([]models.Message, error) {
+	query := activeBranch + `
+		SELECT ` + messageColumns + `
+		FROM messages
+		WHERE id IN (SELECT id FROM branch)`
+	args := []any{conversationID}
+
+	order := "DESC"
+	switch {
+	case params.Before != nil:
+		args = append(args, params.Before.CreatedAt, params.Before.ID)
+		query += ` AND (created_at, id) < ($2, $3)`
+	case params.After != nil:
+		args = append(args, params.After.CreatedAt, params.After.ID)
+		query += ` AND (created_at, id) > ($2, $3)`
+		order = "ASC"
+	case params.Since != nil:
+		args = append(args, *params.Since)
+		query += ` AND created_at > $2`
+		order = "ASC"
+	}
+	args = append(args, params.Limit)
+	query += fmt.Sprintf(`
+		ORDER BY created_at %[1]s, id %[1]s
+		LIMIT $%[2]d`, order, len(args))
+
+	rows, err := p.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("db.ListMessages: %w", err)
+	}
+	msgs, err := collectMessages(rows, "db.ListMessages")
+	if err != nil {
+		return nil, err
+	}
+	if order == "DESC" {
+		slices.Reverse(msgs)
+	}
+	return msgs, nil
+}
+
+// SearchConversationMessages returns up to limit messages on a
+// conversation's active branch whose content contains query literally
+// (case-insensitive), most recent first.
+func (p *Pool) SearchConversationMessages(ctx context.Context, conversationID uuid.UUID, query string, limit int) ([]models.Message, error) {
+	rows, err := p.Query(ctx, activeBranch+`
+		SELECT `+messageColumns+`
+		FROM messages
+		WHERE id IN (SELECT id FROM branch)
+		  AND role IN ('user', 'assistant')
+		  AND content ILIKE '%' || $2 || '%' ESCAPE '\'
+		ORDER BY created_at DESC
+		LIMIT $3`, conversationID, likeEscaper.Replace(query), limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("db.SearchConversationMessages: %w", err)
+	}
+	return collectMessages(rows, "db.SearchConversationMessages")
+}
+
+// likeEscaper escapes the LIKE wildcards in a search term so it matches
+// literally, with \ as the ESCAPE character.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// messageColumns is the column list scanned by collectMessages.
+const messageColumns = `id, conversation_id, parent_id, role, content, token_count, model_used, latency_ms,
+	status, finish_reason, ttft_ms, prompt_tokens, completion_tokens, tool_calls, tool_call_id, created_at`
+
+func collectMessages(rows pgx.Rows, op string) ([]models.Message, error) {
 	defer rows.Close()
 
 	var msgs []models.Message
 	for rows.Next() {
 		var m models.Message
-		if err := rows.Scan(&m.ID, &m.ConversationID, &m.Role, &m.Content, &m.TokenCount, &m.ModelUsed, &m.LatencyMs, &m.CreatedAt); err != nil {
-			return nil, fmt.Errorf("db.GetConversationMessages scan: %w", err)
+		var toolCalls []byte
+		if err := rows.Scan(&m.ID, &m.ConversationID, &m.ParentID, &m.Role, &m.Content, &m.TokenCount, &m.ModelUsed, &m.LatencyMs,
+			&m.Status, &m.FinishReason, &m.TTFTMs, &m.PromptTokens, &m.CompletionTokens, &toolCalls, &m.ToolCallID, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s scan: %w", op, err)
+		}
+		if len(toolCalls) > 0 {
+			if err := json.Unmarshal(toolCalls, &m.ToolCalls); err != nil {
+				return nil, fmt.Errorf("%s tool_calls: %w", op, err)
+			}
 		}
 		msgs = append(msgs, m)
 	}
 	return msgs, rows.Err()
 }
+
+// messageStatus defaults an unset status to complete.
+func messageStatus(s models.MessageStatus) models.MessageStatus {
+	if s == "" {
+		return models.MessageStatusComplete
+	}
+	return s
+}
+
+// toolCallsJSON encodes tool calls for the JSONB column (NULL when empty).
+func toolCallsJSON(calls []models.LLMToolCall) []byte {
+	if len(calls) == 0 {
+		return nil
+	}
+	data, _ := json.Marshal(calls)
+	return data
+}
+
+// ── Conversation summaries ─────────────────────────────────────────
+
+// CreateConversationSummary inserts a rolling summary.
+func (p *Pool) CreateConversationSummary(ctx context.Context, s *models.ConversationSummary) error {
+	_, err := p.Exec(ctx, `
+		INSERT INTO conversation_summaries
+			(id, conversation_id, content, first_message_id, last_message_id, covered_until, message_count, model_used, token_count)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		s.ID, s.ConversationID, s.Content, s.FirstMessageID, s.LastMessageID, s.CoveredUntil, s.MessageCount, s.ModelUsed, s.TokenCount,
+	)
+	if err != nil {
+		return fmt.Errorf("db.CreateConversationSummary: %w", err)
+	}
+	return nil
+}
+
+// GetLatestConversationSummary returns the newest summary for a conversation,
+// or nil if it has none.
+func (p *Pool) GetLatestConversationSummary(ctx context.Context, conversationID uuid.UUID) (*models.ConversationSummary, error) {
+	var s models.ConversationSummary
+	err := p.QueryRow(ctx, `
+		SELECT id, conversation_id, content, first_message_id, last_message_id, covered_until,
+		       message_count, model_used, token_count, created_at
+		FROM conversation_summaries
+		WHERE conversation_id = $1
+		ORDER BY created_at DESC
+		LIMIT 1`, conversationID,
+	).Scan(&s.ID, &s.ConversationID, &s.Content, &s.FirstMessageID, &s.LastMessageID, &s.CoveredUntil,
+		&s.MessageCount, &s.ModelUsed, &s.TokenCount, &s.CreatedAt)
+
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("db.GetLatestConversationSummary: %w", err)
+	}
+	return &s, nil
+}
+
+// ── API keys ───────────────────────────────────────────────────────
+
+// CreateAPIKey inserts a new API key.
+func (p *Pool) CreateAPIKey(ctx context.Context, k *models.APIKey) error {
+	err := p.QueryRow(ctx, `
+		INSERT INTO api_keys (id, user_id, name, prefix, key_hash)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at`,
+		k.ID, k.UserID, k.Name, k.Prefix, k.KeyHash,
+	).Scan(&k.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("db.CreateAPIKey: %w", err)
+	}
+	return nil
+}
+
+// ListAPIKeys returns a user's API keys, revoked ones included, newest first.
+func (p *Pool) ListAPIKeys(ctx context.Context, userID uuid.UUID) ([]models.APIKey, error) {
+	rows, err := p.Query(ctx, `
+		SELECT id, user_id, name, prefix, key_hash, created_at, last_used_at, revoked_at
+		FROM api_keys
+		WHERE user_id = $1
+		ORDER BY created_at DESC`, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("db.ListAPIKeys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []models.APIKey
+	for rows.Next() {
+		var k models.APIKey
+		if err := rows.Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, &k.KeyHash, &k.CreatedAt, &k.LastUsedAt, &k.RevokedAt); err != nil {
+			return nil, fmt.Errorf("db.ListAPIKeys scan: %w", err)
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// GetAPIKeyByHash finds an API key by the hash of its secret (nil if not
+// found).
+func (p *Pool) GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	var k models.APIKey
+	err := p.QueryRow(ctx, `
+		SELECT id, user_id, name, prefix, key_hash, created_at, last_used_at, revoked_at
+		FROM api_keys WHERE key_hash = $1`, hash,
+	).Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, &k.KeyHash, &k.CreatedAt, &k.LastUsedAt, &k.RevokedAt)
+
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("db.GetAPIKeyByHash: %w", err)
+	}
+	return &k, nil
+}
+
+// RevokeAPIKey revokes one of the user's keys. Reports whether a live key
+// was revoked.
+func (p *Pool) RevokeAPIKey(ctx context.Context, id, userID uuid.UUID) (bool, error) {
+	tag, err := p.Exec(ctx, `
+		UPDATE api_keys SET revoked_at = now()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`,
+		id, userID,
+	)
+	if err != nil {
+		return false, fmt.Errorf("db.RevokeAPIKey: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// TouchAPIKey records that a key was used.
+func (p *Pool) TouchAPIKey(ctx context.Context, id uuid.UUID) error {
+	_, err := p.Exec(ctx, `UPDATE api_keys SET last_used_at = now() WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("db.TouchAPIKey: %w", err)
+	}
+	return nil
+}
+
+// ── Semantic cache ─────────────────────────────────────────────────
+
+// InsertSemanticCacheEntry stores a prompt embedding and drops the expired
+// entries of its scope.
+func (p *Pool) InsertSemanticCacheEntry(ctx context.Context, e *models.SemanticCacheEntry) error {
+	batch := &pgx.Batch{}
+	batch.Queue(`DELETE FROM semantic_cache_entries WHERE scope = $1 AND expires_at <= now()`, e.Scope)
+	batch.Queue(`
+		INSERT INTO semantic_cache_entries (id, scope, prompt, cache_key, embedding, expires_at)
+		VALUES ($1, $2, $3, $4, $5::vector, $6)`,
+		e.ID, e.Scope, e.Prompt, e.CacheKey, vectorLiteral(e.Embedding), e.ExpiresAt,
+	)
+	if err := p.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("db.InsertSemanticCacheEntry: %w", err)
+	}
+	return nil
+}
+
+// NearestSemanticCacheEntry returns the live entry of scope closest to
+// embedding by cosine distance, and its cosine similarity (nil if the scope
+// has none).
+func (p *Pool) NearestSemanticCacheEntry(ctx context.Context, scope string, embedding []float32) (*models.SemanticCacheEntry, float64, error) {
+	var e models.SemanticCacheEntry
+	var score float64
+	err := p.QueryRow(ctx, `
+		SELECT id, scope, prompt, cache_key, expires_at, created_at,
+		       1 - (embedding <=> $2::vector) AS score
+		FROM semantic_cache_entries
+		WHERE scope = $1 AND expires_at > now() AND vector_dims(embedding) = $3
+		ORDER BY embedding <=> $2::vector
+		LIMIT 1`, scope, vectorLiteral(embedding), len(embedding),
+	).Scan(&e.ID, &e.Scope, &e.Prompt, &e.CacheKey, &e.ExpiresAt, &e.CreatedAt, &score)
+
+	if err == pgx.ErrNoRows {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("db.NearestSemanticCacheEntry: %w", err)
+	}
+	return &e, score, nil
+}
+
+// DeleteSemanticCacheEntry removes an entry whose answer has left the cache.
+func (p *Pool) DeleteSemanticCacheEntry(ctx context.Context, id uuid.UUID) error {
+	_, err := p.Exec(ctx, `DELETE FROM semantic_cache_entries WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("db.DeleteSemanticCacheEntry: %w", err)
+	}
+	return nil
+}
+
+// vectorLiteral formats v as a pgvector text literal, e.g. "[1,0.5]".
+func vectorLiteral(v []float32) string {
+	b := make([]byte, 0, 2+len(v)*10)
+	b = append(b, '[')
+	for i, x := range v {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = strconv.AppendFloat(b, float64(x), 'g', -1, 32)
+	}
+	return string(append(b, ']'))
+}
@@ -0,0 +1,105 @@
+// API key handler — create, list and revoke keys for the /v1 endpoints.
+// Maps to design.swift: AuthN/AuthZ (API keys for machine clients)
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/prakyathpnayak/roognis/internal/middleware"
+	"github.com/prakyathpnayak/roognis/internal/models"
+	"github.com/prakyathpnayak/roognis/internal/service"
+)
+
+// APIKeyHandler manages the caller's API keys.
+type APIKeyHandler struct {
+	keys *service.APIKeys
+}
+
+// NewAPIKeyHandler creates a new API key handler.
+func NewAPIKeyHandler(keys *service.APIKeys) *APIKeyHandler {
+	return &APIKeyHandler{keys: keys}
+}
+
+// Create handles POST /api/v1/api-keys.
+// Returns the new key, which is shown only this once.
+func (h *APIKeyHandler) Create(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 4<<10)
+
+	user := middleware.UserFromContext(r.Context())
+	if user == nil {
+		writeError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || utf8.RuneCountInString(req.Name) > 100 {
+		writeError(w, "name is required and must be at most 100 characters", http.StatusBadRequest)
+		return
+	}
+
+	key, err := h.keys.Create(r.Context(), user.ID, req.Name)
+	if err != nil {
+		slog.Error("api_keys.create_error", "error", err, "user_id", user.ID)
+		writeError(w, "failed to create api key", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, key)
+}
+
+// List handles GET /api/v1/api-keys.
+// Lists the caller's keys, revoked ones included, without the secrets.
+func (h *APIKeyHandler) List(w http.ResponseWriter, r *http.Request) {
+	user := middleware.UserFromContext(r.Context())
+	if user == nil {
+		writeError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	keys, err := h.keys.List(r.Context(), user.ID)
+	if err != nil {
+		slog.Error("api_keys.list_error", "error", err, "user_id", user.ID)
+		writeError(w, "failed to list api keys", http.StatusInternalServerError)
+		return
+	}
+	if keys == nil {
+		keys = []models.APIKey{}
+	}
+	writeJSON(w, http.StatusOK, keys)
+}
+
+// Revoke handles DELETE /api/v1/api-keys/{id}.
+func (h *APIKeyHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	user := middleware.UserFromContext(r.Context())
+	if user == nil {
+		writeError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, "invalid api key id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.keys.Revoke(r.Context(), id, user.ID); err != nil {
+		if errors.Is(err, service.ErrAPIKeyNotFound) {
+			writeError(w, "api key not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("api_keys.revoke_error", "error", err, "user_id", user.ID, "id", id)
+		writeError(w, "failed to revoke api key", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
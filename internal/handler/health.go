@@ -15,11 +15,12 @@ import (
 type Health struct {
 	pool  *db.Pool
 	cache *service.Cache
+	llm   *service.LLM
 }
 
 // NewHealth creates a new health handler.
-func NewHealth(pool *db.Pool, cache *service.Cache) *Health {
-	return &Health{pool: pool, cache: cache}
+func NewHealth(pool *db.Pool, cache *service.Cache, llm *service.LLM) *Health {
+	return &Health{pool: pool, cache: cache, llm: llm}
 }
 
 // ServeHTTP implements http.Handler.
@@ -47,6 +48,14 @@ func (h *Health) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		resp.Redis = "up"
 	}
 
+	// Report LLM circuit breakers. An open breaker is served around by the
+	// fallback chain, so it does not fail the readiness probe.
+	resp.LLMProviders = h.llm.BreakerStates()
+
+	// Report cache hit rates per tier.
+	stats := h.cache.Stats()
+	resp.Cache = &stats
+
 	w.Header().Set("Content-Type", "application/json")
 	if resp.Status != "ok" {
 		w.WriteHeader(http.StatusServiceUnavailable)
@@ -5,11 +5,18 @@ package handler
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 	"unicode/utf8"
 
 	"github.com/google/uuid"
+	"github.com/prakyathpnayak/roognis/internal/config"
 	"github.com/prakyathpnayak/roognis/internal/middleware"
 	"github.com/prakyathpnayak/roognis/internal/models"
 	"github.com/prakyathpnayak/roognis/internal/service"
@@ -18,11 +25,13 @@ import (
 // InferenceHandler handles POST /api/v1/inference/complete.
 type InferenceHandler struct {
 	orchestrator *service.Orchestrator
+	streams      *service.Streams
+	cfg          *config.Config
 }
 
 // NewInferenceHandler creates a new inference handler.
-func NewInferenceHandler(orch *service.Orchestrator) *InferenceHandler {
-	return &InferenceHandler{orchestrator: orch}
+func NewInferenceHandler(orch *service.Orchestrator, streams *service.Streams, cfg *config.Config) *InferenceHandler {
+	return &InferenceHandler{orchestrator: orch, streams: streams, cfg: cfg}
 }
 
 // Complete handles POST /api/v1/inference/complete.
@@ -53,16 +62,250 @@ func (h *InferenceHandler) Complete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.dispatch(w, r, &req, user)
+}
+
+// dispatch validates the output options of req and runs it, streaming or not.
+func (h *InferenceHandler) dispatch(w http.ResponseWriter, r *http.Request, req *models.InferenceRequest, user *models.User) {
+	if err := checkOutputOptions(req); err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	if req.Stream {
-		h.handleStream(w, r, &req, user)
+		h.handleStream(w, r, req, user)
 	} else {
-		h.handleComplete(w, r, &req, user)
+		h.handleComplete(w, r, req, user)
+	}
+}
+
+// checkOutputOptions validates the response format of req.
+func checkOutputOptions(req *models.InferenceRequest) error {
+	if err := service.CheckResponseFormat(req.ResponseFormat); err != nil {
+		return err
+	}
+	// Structured output is validated (and re-prompted) on the complete
+	// answer, which a token stream cannot offer.
+	if req.Stream && req.ResponseFormat != nil && req.ResponseFormat.Type != models.ResponseFormatText {
+		return errors.New("response_format is not supported with stream")
+	}
+	return nil
+}
+
+// Regenerate handles POST /api/v1/conversations/{id}/messages/{message_id}/regenerate.
+// Answers the prompt behind an assistant message again as a new branch. The
+// optional body takes the inference options (model, temperature, max_tokens,
+// response_format, stream); prompt is ignored.
+func (h *InferenceHandler) Regenerate(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 64<<10)
+
+	user := middleware.UserFromContext(r.Context())
+	if user == nil {
+		writeError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	conversationID, messageID, ok := branchPathIDs(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.InferenceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Prompt = ""
+	req.ConversationID = &conversationID
+	req.RegenerateOf = &messageID
+
+	h.dispatch(w, r, &req, user)
+}
+
+// EditMessage handles POST /api/v1/conversations/{id}/messages/{message_id}/edit.
+// Sends prompt as a new version of a user message, branching from its
+// parent, and answers it. Takes the same body as /inference/complete.
+func (h *InferenceHandler) EditMessage(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 64<<10)
+
+	user := middleware.UserFromContext(r.Context())
+	if user == nil {
+		writeError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	conversationID, messageID, ok := branchPathIDs(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.InferenceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Prompt == "" {
+		writeError(w, "prompt is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := validatePrompt(req.Prompt); err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req.ConversationID = &conversationID
+	req.EditOf = &messageID
+
+	h.dispatch(w, r, &req, user)
+}
+
+// MessageBranches handles GET /api/v1/conversations/{id}/messages/{message_id}/branches.
+// Lists the versions of a message, oldest first, marking the active one.
+func (h *InferenceHandler) MessageBranches(w http.ResponseWriter, r *http.Request) {
+	user := middleware.UserFromContext(r.Context())
+	if user == nil {
+		writeError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	conversationID, messageID, ok := branchPathIDs(w, r)
+	if !ok {
+		return
+	}
+
+	branches, err := h.orchestrator.ListMessageBranches(r.Context(), conversationID, user.ID, messageID)
+	if err != nil {
+		if writeBranchError(w, err) {
+			return
+		}
+		slog.Error("inference.list_branches_error", "error", err, "user_id", user.ID, "conversation_id", conversationID)
+		writeError(w, "failed to list branches", http.StatusInternalServerError)
+		return
 	}
+
+	writeJSON(w, http.StatusOK, branches)
+}
+
+// SwitchBranch handles PUT /api/v1/conversations/{id}/branch.
+// Makes the branch through message_id active and returns the conversation.
+func (h *InferenceHandler) SwitchBranch(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 16<<10)
+
+	user := middleware.UserFromContext(r.Context())
+	if user == nil {
+		writeError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	conversationID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, "invalid conversation id", http.StatusBadRequest)
+		return
+	}
+
+	var req models.SwitchBranchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.MessageID == uuid.Nil {
+		writeError(w, "message_id is required", http.StatusBadRequest)
+		return
+	}
+
+	conv, err := h.orchestrator.SwitchBranch(r.Context(), conversationID, user.ID, req.MessageID)
+	if err != nil {
+		if writeBranchError(w, err) {
+			return
+		}
+		slog.Error("inference.switch_branch_error", "error", err, "user_id", user.ID, "conversation_id", conversationID)
+		writeError(w, "failed to switch branch", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, conv)
+}
+
+// ForkConversation handles POST /api/v1/conversations/{id}/fork.
+// Copies the history up to message_id into a new conversation owned by the
+// caller and returns it.
+func (h *InferenceHandler) ForkConversation(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 16<<10)
+
+	user := middleware.UserFromContext(r.Context())
+	if user == nil {
+		writeError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	conversationID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, "invalid conversation id", http.StatusBadRequest)
+		return
+	}
+
+	var req models.ForkConversationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.MessageID == uuid.Nil {
+		writeError(w, "message_id is required", http.StatusBadRequest)
+		return
+	}
+
+	conv, err := h.orchestrator.ForkConversation(r.Context(), conversationID, user, req.MessageID)
+	if err != nil {
+		if writeBranchError(w, err) {
+			return
+		}
+		slog.Error("inference.fork_conversation_error", "error", err, "user_id", user.ID, "conversation_id", conversationID)
+		writeError(w, "failed to fork conversation", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, conv)
+}
+
+// branchPathIDs parses the {id} and {message_id} path values.
+func branchPathIDs(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	conversationID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, "invalid conversation id", http.StatusBadRequest)
+		return uuid.Nil, uuid.Nil, false
+	}
+	messageID, err := uuid.Parse(r.PathValue("message_id"))
+	if err != nil {
+		writeError(w, "invalid message id", http.StatusBadRequest)
+		return uuid.Nil, uuid.Nil, false
+	}
+	return conversationID, messageID, true
+}
+
+// writeBranchError writes the response for the ownership and message errors
+// of the branch endpoints and reports whether err was one of them.
+func writeBranchError(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, service.ErrConversationForbidden):
+		writeError(w, "forbidden", http.StatusForbidden)
+	case errors.Is(err, service.ErrConversationNotFound):
+		writeError(w, "conversation not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrMessageNotFound):
+		writeError(w, "message not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrNotAssistantMessage), errors.Is(err, service.ErrNotUserMessage),
+		errors.Is(err, service.ErrInvalidForkPoint):
+		writeError(w, err.Error(), http.StatusBadRequest)
+	default:
+		return false
+	}
+	return true
 }
 
 // handleComplete processes a non-streaming inference request.
 func (h *InferenceHandler) handleComplete(w http.ResponseWriter, r *http.Request, req *models.InferenceRequest, user *models.User) {
-	resp, err := h.orchestrator.Complete(r.Context(), req, user.ID)
+	resp, err := h.orchestrator.Complete(r.Context(), req, user)
 	if err != nil {
 		if errors.Is(err, service.ErrConversationForbidden) {
 			writeError(w, "forbidden", http.StatusForbidden)
@@ -72,6 +315,27 @@ func (h *InferenceHandler) handleComplete(w http.ResponseWriter, r *http.Request
 			writeError(w, "conversation not found", http.StatusNotFound)
 			return
 		}
+		if errors.Is(err, service.ErrMessageNotFound) {
+			writeError(w, "message not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, service.ErrNotAssistantMessage) || errors.Is(err, service.ErrNotUserMessage) {
+			writeError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, service.ErrModelNotAllowed) {
+			writeError(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if errors.Is(err, service.ErrContextOverflow) {
+			writeError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var schemaErr *service.SchemaValidationError
+		if errors.As(err, &schemaErr) {
+			writeError(w, schemaErr.Error(), http.StatusUnprocessableEntity)
+			return
+		}
 		slog.Error("inference.complete_error", "error", err, "user_id", user.ID)
 		writeError(w, "inference failed: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -81,45 +345,234 @@ func (h *InferenceHandler) handleComplete(w http.ResponseWriter, r *http.Request
 }
 
 // handleStream processes a streaming inference request via SSE.
+//
+// The generation runs detached from the request and its events are read
+// back from its stream, so a client that loses the connection can resume
+// with ResumeStream. Every event has an id: field. A stream opens with
+// "meta" (conversation, user message and assistant message IDs), then sends
+// "context", "delta" events with the content and any "tool_call"/
+// "tool_result" events. Once the answer is stored it sends "usage", then
+// "error" if the stream failed, and closes with "done" and the data-only
+// [DONE] sentinel. Comment heartbeats keep idle streams open.
 func (h *InferenceHandler) handleStream(w http.ResponseWriter, r *http.Request, req *models.InferenceRequest, user *models.User) {
+	// The server's write timeout is meant for plain requests; a stream
+	// stays open for as long as the generation runs.
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
 	sse, err := service.NewSSEWriter(w)
 	if err != nil {
 		writeError(w, "streaming not supported", http.StatusInternalServerError)
 		return
 	}
+	stopHeartbeat := sse.StartHeartbeat(h.cfg.SSEHeartbeatInterval)
+	defer stopHeartbeat()
 
-	streamErr := h.orchestrator.StreamComplete(r.Context(), req, user.ID, func(chunk models.LLMResponse) error {
-		if len(chunk.Choices) == 0 {
-			return nil
+	meta, err := h.streams.Start(r.Context(), req, user)
+	if err != nil {
+		if r.Context().Err() != nil {
+			return
+		}
+		code, msg := service.StreamErrorCode(err)
+		if code == "upstream_error" {
+			slog.Error("inference.stream_error", "error", err, "user_id", user.ID)
 		}
+		sse.WriteError(code, msg)
+		sse.WriteEvent("done", models.StreamDone{Status: models.MessageStatusError})
+		sse.WriteDone()
+		return
+	}
+
+	h.relayStream(r, sse, meta.MessageID, user.ID)
+}
+
+// ResumeStream handles GET /api/v1/inference/streams/{message_id}.
+// Replays the events of a streamed generation after the one named by the
+// Last-Event-ID header (or last_event_id query parameter, for clients that
+// cannot set headers), then follows it live until "done". Streams can be
+// resumed while running and for STREAM_RETENTION_SECONDS after.
+func (h *InferenceHandler) ResumeStream(w http.ResponseWriter, r *http.Request) {
+	user := middleware.UserFromContext(r.Context())
+	if user == nil {
+		writeError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
 
-		sc := models.StreamChunk{
-			Delta:        chunk.Choices[0].Delta.Content,
-			FinishReason: chunk.Choices[0].FinishReason,
-			Model:        chunk.Model,
+	messageID, err := uuid.Parse(r.PathValue("message_id"))
+	if err != nil {
+		writeError(w, "invalid message id", http.StatusBadRequest)
+		return
+	}
+
+	var after int64
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("last_event_id")
+	}
+	if lastID != "" {
+		after, err = strconv.ParseInt(lastID, 10, 64)
+		if err != nil || after < 0 {
+			writeError(w, "invalid Last-Event-ID", http.StatusBadRequest)
+			return
 		}
-		return sse.WriteData(sc)
-	})
+	}
 
-	if streamErr != nil {
-		if errors.Is(streamErr, service.ErrConversationForbidden) {
-			sse.WriteError("forbidden")
-			sse.WriteDone()
+	// Check the stream exists before committing to an SSE response.
+	events, err := h.streams.Subscribe(r.Context(), messageID, user.ID, after)
+	if err != nil {
+		if errors.Is(err, service.ErrStreamNotFound) {
+			writeError(w, "stream not found", http.StatusNotFound)
 			return
 		}
-		if errors.Is(streamErr, service.ErrConversationNotFound) {
-			sse.WriteError("conversation not found")
-			sse.WriteDone()
+		slog.Error("inference.resume_stream_error", "error", err, "user_id", user.ID, "message_id", messageID)
+		writeError(w, "failed to resume stream", http.StatusInternalServerError)
+		return
+	}
+
+	// As in handleStream, the stream outlives the server's write timeout.
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	sse, err := service.NewSSEWriter(w)
+	if err != nil {
+		writeError(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	stopHeartbeat := sse.StartHeartbeat(h.cfg.SSEHeartbeatInterval)
+	defer stopHeartbeat()
+
+	h.relayEvents(r, sse, messageID, events)
+}
+
+// CancelGeneration handles POST /api/v1/inference/{message_id}/cancel.
+// Stops a running streamed generation of the caller (any user's, for
+// admins), including the upstream model call. The answer so far is stored
+// with status cancelled. Responds 200 once it is stored, or 202 if the
+// generation is still stopping after a few seconds.
+func (h *InferenceHandler) CancelGeneration(w http.ResponseWriter, r *http.Request) {
+	user := middleware.UserFromContext(r.Context())
+	if user == nil {
+		writeError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	messageID, err := uuid.Parse(r.PathValue("message_id"))
+	if err != nil {
+		writeError(w, "invalid message id", http.StatusBadRequest)
+		return
+	}
+
+	gen, err := h.streams.Cancel(r.Context(), messageID, user)
+	if err != nil {
+		if errors.Is(err, service.ErrGenerationNotFound) {
+			writeError(w, "generation not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("inference.cancel_error", "error", err, "user_id", user.ID, "message_id", messageID)
+		writeError(w, "failed to cancel generation", http.StatusInternalServerError)
+		return
+	}
+
+	status := http.StatusOK
+	if !h.streams.WaitStopped(r.Context(), messageID) {
+		status = http.StatusAccepted
+	}
+	writeJSON(w, status, gen)
+}
+
+// Generations handles GET /api/v1/inference/generations.
+// Lists the caller's running streamed generations, oldest first.
+func (h *InferenceHandler) Generations(w http.ResponseWriter, r *http.Request) {
+	user := middleware.UserFromContext(r.Context())
+	if user == nil {
+		writeError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	h.writeGenerations(w, r, &user.ID)
+}
+
+// AdminGenerations handles GET /api/v1/admin/generations (admins only).
+// Lists the running streamed generations of all users, or of user_id.
+func (h *InferenceHandler) AdminGenerations(w http.ResponseWriter, r *http.Request) {
+	var userID *uuid.UUID
+	if v := r.URL.Query().Get("user_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			writeError(w, "invalid user_id", http.StatusBadRequest)
+			return
+		}
+		userID = &id
+	}
+
+	h.writeGenerations(w, r, userID)
+}
+
+func (h *InferenceHandler) writeGenerations(w http.ResponseWriter, r *http.Request, userID *uuid.UUID) {
+	gens, err := h.streams.Generations(r.Context(), userID)
+	if err != nil {
+		slog.Error("inference.list_generations_error", "error", err)
+		writeError(w, "failed to list generations", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, gens)
+}
+
+// relayStream sends the events of message id's stream from the start.
+func (h *InferenceHandler) relayStream(r *http.Request, sse *service.SSEWriter, id, userID uuid.UUID) {
+	events, err := h.streams.Subscribe(r.Context(), id, userID, 0)
+	if err != nil {
+		slog.Error("inference.stream_subscribe_error", "error", err, "user_id", userID, "message_id", id)
+		sse.WriteError("upstream_error", "stream unavailable")
+		sse.WriteDone()
+		return
+	}
+	h.relayEvents(r, sse, id, events)
+}
+
+// relayEvents copies stream events to the client until the stream ends or
+// the client goes away, keeping the generation's lease while reading.
+func (h *InferenceHandler) relayEvents(r *http.Request, sse *service.SSEWriter, id uuid.UUID, events <-chan models.StreamEvent) {
+	lease := time.NewTicker(max(h.cfg.StreamResumeGrace/2, time.Second))
+	defer lease.Stop()
+	h.streams.Touch(r.Context(), id)
+
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				if r.Context().Err() == nil {
+					sse.WriteDone()
+				}
+				return
+			}
+			if err := sse.WriteStreamEvent(ev); err != nil {
+				return
+			}
+		case <-lease.C:
+			h.streams.Touch(r.Context(), id)
+		case <-r.Context().Done():
 			return
 		}
-		slog.Error("inference.stream_error", "error", streamErr, "user_id", user.ID)
-		sse.WriteError(streamErr.Error())
+	}
+}
+
+// Models handles GET /api/v1/models.
+// Lists the models the caller's role is allowed to request.
+func (h *InferenceHandler) Models(w http.ResponseWriter, r *http.Request) {
+	user := middleware.UserFromContext(r.Context())
+	if user == nil {
+		writeError(w, "unauthorized", http.StatusUnauthorized)
+		return
 	}
 
-	sse.WriteDone()
+	writeJSON(w, http.StatusOK, h.orchestrator.ListModels(user.Role))
 }
 
 // Conversations handles GET /api/v1/conversations.
+//
+// Query parameters: limit (default 50, max 100), cursor (from X-Next-Cursor),
+// archived=false|true|all (default false), pinned=true|false and
+// deleted=true to list conversations that can still be restored. The body is
+// the page as a JSON array; X-Next-Cursor and a Link rel="next" header are
+// set when another page follows.
 func (h *InferenceHandler) Conversations(w http.ResponseWriter, r *http.Request) {
 	user := middleware.UserFromContext(r.Context())
 	if user == nil {
@@ -127,37 +580,180 @@ func (h *InferenceHandler) Conversations(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	conversations, err := h.orchestrator.ListConversations(r.Context(), user.ID)
+	q := r.URL.Query()
+	params := models.ConversationListParams{Archived: new(bool)}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > service.MaxConversationPageSize {
+			writeError(w, fmt.Sprintf("limit must be between 1 and %d", service.MaxConversationPageSize), http.StatusBadRequest)
+			return
+		}
+		params.Limit = limit
+	}
+	if v := q.Get("cursor"); v != "" {
+		cursor, err := service.DecodeConversationCursor(v)
+		if err != nil {
+			writeError(w, "invalid cursor", http.StatusBadRequest)
+			return
+		}
+		params.After = cursor
+	}
+
+	deleted, err := boolFilter(q.Get("deleted"), new(bool))
+	if err != nil {
+		writeError(w, "deleted must be true or false", http.StatusBadRequest)
+		return
+	}
+	params.Deleted = *deleted
+	if params.Deleted {
+		params.Archived = nil // the trash shows archived conversations too
+	}
+
+	if q.Get("archived") == "all" {
+		params.Archived = nil
+	} else if params.Archived, err = boolFilter(q.Get("archived"), params.Archived); err != nil {
+		writeError(w, "archived must be true, false or all", http.StatusBadRequest)
+		return
+	}
+	if params.Pinned, err = boolFilter(q.Get("pinned"), nil); err != nil {
+		writeError(w, "pinned must be true or false", http.StatusBadRequest)
+		return
+	}
+
+	conversations, next, err := h.orchestrator.ListConversations(r.Context(), user.ID, params)
 	if err != nil {
 		slog.Error("inference.list_conversations_error", "error", err, "user_id", user.ID)
 		writeError(w, "failed to list conversations", http.StatusInternalServerError)
 		return
 	}
 
+	if next != "" {
+		nextQuery := r.URL.Query()
+		nextQuery.Set("cursor", next)
+		w.Header().Set("X-Next-Cursor", next)
+		w.Header().Set("Link", fmt.Sprintf(`<%s?%s>; rel="next"`, r.URL.Path, nextQuery.Encode()))
+	}
+	if conversations == nil {
+		conversations = []models.Conversation{}
+	}
 	writeJSON(w, http.StatusOK, conversations)
 }
 
-// ConversationMessages handles GET /api/v1/conversations/{id}/messages.
-func (h *InferenceHandler) ConversationMessages(w http.ResponseWriter, r *http.Request) {
+// boolFilter parses an optional true/false query value, returning def when
+// it is empty.
+func boolFilter(v string, def *bool) (*bool, error) {
+	if v == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// UpdateConversation handles PATCH /api/v1/conversations/{id}.
+// A title set here is never overwritten by generated titles.
+func (h *InferenceHandler) UpdateConversation(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 16<<10)
+
 	user := middleware.UserFromContext(r.Context())
 	if user == nil {
 		writeError(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	conversationIDRaw := r.PathValue("id")
-	if conversationIDRaw == "" {
-		writeError(w, "conversation id is required", http.StatusBadRequest)
+	conversationID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, "invalid conversation id", http.StatusBadRequest)
 		return
 	}
 
-	conversationID, err := uuid.Parse(conversationIDRaw)
+	var req models.UpdateConversationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Title != nil {
+		title := strings.TrimSpace(*req.Title)
+		if title == "" {
+			writeError(w, "title must not be empty", http.StatusBadRequest)
+			return
+		}
+		if utf8.RuneCountInString(title) > 512 {
+			writeError(w, "title exceeds maximum length of 512 characters", http.StatusBadRequest)
+			return
+		}
+		req.Title = &title
+	}
+
+	conv, err := h.orchestrator.UpdateConversation(r.Context(), conversationID, user.ID, &req)
+	if err != nil {
+		if errors.Is(err, service.ErrConversationForbidden) {
+			writeError(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		if errors.Is(err, service.ErrConversationNotFound) {
+			writeError(w, "conversation not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("inference.update_conversation_error", "error", err, "user_id", user.ID, "conversation_id", conversationID)
+		writeError(w, "failed to update conversation", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, conv)
+}
+
+// DeleteConversation handles DELETE /api/v1/conversations/{id}.
+// The conversation is soft-deleted and can be restored until it is purged.
+func (h *InferenceHandler) DeleteConversation(w http.ResponseWriter, r *http.Request) {
+	user := middleware.UserFromContext(r.Context())
+	if user == nil {
+		writeError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	conversationID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, "invalid conversation id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.orchestrator.DeleteConversation(r.Context(), conversationID, user.ID); err != nil {
+		if errors.Is(err, service.ErrConversationForbidden) {
+			writeError(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		if errors.Is(err, service.ErrConversationNotFound) {
+			writeError(w, "conversation not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("inference.delete_conversation_error", "error", err, "user_id", user.ID, "conversation_id", conversationID)
+		writeError(w, "failed to delete conversation", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RestoreConversation handles POST /api/v1/conversations/{id}/restore.
+func (h *InferenceHandler) RestoreConversation(w http.ResponseWriter, r *http.Request) {
+	user := middleware.UserFromContext(r.Context())
+	if user == nil {
+		writeError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	conversationID, err := uuid.Parse(r.PathValue("id"))
 	if err != nil {
 		writeError(w, "invalid conversation id", http.StatusBadRequest)
 		return
 	}
 
-	messages, err := h.orchestrator.ListConversationMessages(r.Context(), conversationID, user.ID)
+	conv, err := h.orchestrator.RestoreConversation(r.Context(), conversationID, user.ID)
 	if err != nil {
 		if errors.Is(err, service.ErrConversationForbidden) {
 			writeError(w, "forbidden", http.StatusForbidden)
@@ -167,12 +763,39 @@ func (h *InferenceHandler) ConversationMessages(w http.ResponseWriter, r *http.R
 			writeError(w, "conversation not found", http.StatusNotFound)
 			return
 		}
-		slog.Error("inference.list_conversation_messages_error", "error", err, "user_id", user.ID, "conversation_id", conversationID)
-		writeError(w, "failed to list conversation messages", http.StatusInternalServerError)
+		if errors.Is(err, service.ErrRestoreExpired) {
+			writeError(w, err.Error(), http.StatusGone)
+			return
+		}
+		slog.Error("inference.restore_conversation_error", "error", err, "user_id", user.ID, "conversation_id", conversationID)
+		writeError(w, "failed to restore conversation", http.StatusInternalServerError)
 		return
 	}
 
-	writeJSON(w, http.StatusOK, messages)
+	writeJSON(w, http.StatusOK, conv)
+}
+
+// ConversationMessages handles GET /api/v1/conversations/{id}/messages.
+func (h *InferenceHandler) ConversationMessages(w http.ResponseWriter, r *http.Request) {
+	user := middleware.UserFromContext(r.Context())
+	if user == nil {
+		writeError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	conversationIDRaw := r.PathValue("id")
+	if conversationIDRaw == "" {
+		writeError(w, "conversation id is required", http.StatusBadRequest)
+		return
+	}
+
+	conversationID, err := uuid.Parse(conversationIDRaw)
+	if err != nil {
+		writeError(w, "invalid conversation id", http.StatusBadRequest)
+		return
+	}
+
+	h.writeMessagePage(w, r, user, conversationID, "inference.list_conversation_messages_error")
 }
 
 // ConversationMessagesByQuery handles GET /api/v1/conversation-messages?conversation_id=<uuid>.
@@ -196,7 +819,24 @@ func (h *InferenceHandler) ConversationMessagesByQuery(w http.ResponseWriter, r
 		return
 	}
 
-	messages, err := h.orchestrator.ListConversationMessages(r.Context(), conversationID, user.ID)
+	h.writeMessagePage(w, r, user, conversationID, "inference.list_conversation_messages_query_error")
+}
+
+// writeMessagePage serves one page of a conversation's history.
+//
+// Query parameters: limit (default 100, max 500) and at most one of before
+// and after (cursors from X-Prev-Cursor / X-Next-Cursor) or since (RFC 3339
+// timestamp, for incremental sync). Without them the newest page is
+// returned. Messages are in chronological order. The response carries an
+// ETag; a matching If-None-Match gets 304 Not Modified.
+func (h *InferenceHandler) writeMessagePage(w http.ResponseWriter, r *http.Request, user *models.User, conversationID uuid.UUID, errEvent string) {
+	params, err := messageListParams(r.URL.Query())
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.orchestrator.ListConversationMessages(r.Context(), conversationID, user.ID, params, r.Header.Get("If-None-Match"))
 	if err != nil {
 		if errors.Is(err, service.ErrConversationForbidden) {
 			writeError(w, "forbidden", http.StatusForbidden)
@@ -206,14 +846,91 @@ func (h *InferenceHandler) ConversationMessagesByQuery(w http.ResponseWriter, r
 			writeError(w, "conversation not found", http.StatusNotFound)
 			return
 		}
-		slog.Error("inference.list_conversation_messages_query_error", "error", err, "user_id", user.ID, "conversation_id", conversationID)
+		slog.Error(errEvent, "error", err, "user_id", user.ID, "conversation_id", conversationID)
 		writeError(w, "failed to list conversation messages", http.StatusInternalServerError)
 		return
 	}
 
+	w.Header().Set("ETag", page.ETag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	if page.NotModified {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	var links []string
+	for _, l := range []struct{ cursor, param, rel, header string }{
+		{page.PrevCursor, "before", "prev", "X-Prev-Cursor"},
+		{page.NextCursor, "after", "next", "X-Next-Cursor"},
+	} {
+		if l.cursor == "" {
+			continue
+		}
+		q := r.URL.Query()
+		q.Del("before")
+		q.Del("after")
+		q.Del("since")
+		q.Set(l.param, l.cursor)
+		w.Header().Set(l.header, l.cursor)
+		links = append(links, fmt.Sprintf(`<%s?%s>; rel="%s"`, r.URL.Path, q.Encode(), l.rel))
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+
+	messages := page.Messages
+	if messages == nil {
+		messages = []models.Message{}
+	}
 	writeJSON(w, http.StatusOK, messages)
 }
 
+// messageListParams parses the history paging query parameters.
+func messageListParams(q url.Values) (models.MessageListParams, error) {
+	var params models.MessageListParams
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > service.MaxMessagePageSize {
+			return params, fmt.Errorf("limit must be between 1 and %d", service.MaxMessagePageSize)
+		}
+		params.Limit = limit
+	}
+
+	set := 0
+	for _, name := range []string{"before", "after", "since"} {
+		if q.Get(name) != "" {
+			set++
+		}
+	}
+	if set > 1 {
+		return params, errors.New("use only one of before, after and since")
+	}
+
+	if v := q.Get("before"); v != "" {
+		cursor, err := service.DecodeMessageCursor(v)
+		if err != nil {
+			return params, errors.New("invalid before cursor")
+		}
+		params.Before = cursor
+	}
+	if v := q.Get("after"); v != "" {
+		cursor, err := service.DecodeMessageCursor(v)
+		if err != nil {
+			return params, errors.New("invalid after cursor")
+		}
+		params.After = cursor
+	}
+	if v := q.Get("since"); v != "" {
+		since, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return params, errors.New("since must be an RFC 3339 timestamp")
+		}
+		params.Since = &since
+	}
+	return params, nil
+}
+
 func validatePrompt(prompt string) error {
 	if utf8.RuneCountInString(prompt) > 32000 {
 		return errors.New("prompt exceeds maximum length of 32000 characters")
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"net/url"
 	"strings"
 	"testing"
 )
@@ -18,3 +19,23 @@ func TestValidatePrompt_RejectsOverLimitUnicodeChars(t *testing.T) {
 		t.Fatal("expected prompt over limit to be rejected")
 	}
 }
+
+func TestMessageListParams(t *testing.T) {
+	if _, err := messageListParams(url.Values{"before": {"x"}, "since": {"2026-01-01T00:00:00Z"}}); err == nil {
+		t.Fatal("expected before and since together to be rejected")
+	}
+	if _, err := messageListParams(url.Values{"limit": {"0"}}); err == nil {
+		t.Fatal("expected limit 0 to be rejected")
+	}
+	if _, err := messageListParams(url.Values{"after": {"garbage"}}); err == nil {
+		t.Fatal("expected an invalid cursor to be rejected")
+	}
+
+	params, err := messageListParams(url.Values{"limit": {"20"}, "since": {"2026-01-01T00:00:00.5Z"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if params.Limit != 20 || params.Since == nil || params.Since.Nanosecond() != 500000000 {
+		t.Fatalf("unexpected params: %+v", params)
+	}
+}
@@ -0,0 +1,277 @@
+// Inference WebSocket — bidirectional streaming sessions.
+// Maps to design.swift: Request Router → Prompt Orchestrator → Token Streamer (WebSocket)
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/google/uuid"
+	"github.com/prakyathpnayak/roognis/internal/middleware"
+	"github.com/prakyathpnayak/roognis/internal/models"
+	"github.com/prakyathpnayak/roognis/internal/service"
+)
+
+const (
+	maxSocketGenerations = 4
+	socketWriteTimeout   = 10 * time.Second
+)
+
+// InferenceSocket handles GET /api/v1/inference/ws.
+//
+// Authenticates like every protected route; browsers pass the token as the
+// access_token query parameter. The client sends JSON frames (see
+// models.WSClientMessage): "prompt" with an id of its choice and a request
+// as for /inference/complete, "cancel" with the id of a running prompt, and
+// "ping". Up to four generations run at once, each answered with the same
+// events as the SSE stream, tagged with its id (see models.WSServerMessage).
+// Generations left running when the socket closes can be resumed over SSE.
+func (h *InferenceHandler) InferenceSocket(w http.ResponseWriter, r *http.Request) {
+	user := middleware.UserFromContext(r.Context())
+	if user == nil {
+		writeError(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// The server's read and write timeouts are meant for plain requests; a
+	// socket stays open for as long as the client wants.
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+
+	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{OriginPatterns: h.cfg.CORSOrigins})
+	if err != nil {
+		slog.Warn("inference.ws_accept_error", "error", err, "user_id", user.ID)
+		return
+	}
+	conn.SetReadLimit(64 << 10)
+
+	s := &inferenceSocket{
+		h:    h,
+		conn: conn,
+		user: user,
+		gens: make(map[string]*socketGeneration),
+	}
+	// The request context must not be used once the connection is hijacked.
+	s.serve(context.WithoutCancel(r.Context()))
+}
+
+// inferenceSocket is one WebSocket session.
+type inferenceSocket struct {
+	h    *InferenceHandler
+	conn *websocket.Conn
+	user *models.User
+
+	mu   sync.Mutex
+	gens map[string]*socketGeneration // by client ID
+	wg   sync.WaitGroup
+}
+
+// socketGeneration is a generation started over the socket.
+type socketGeneration struct {
+	messageID uuid.UUID // set once the stream is open
+	cancelled bool      // a cancel frame arrived
+}
+
+// serve reads client frames until the socket closes, then waits for the
+// relays of its generations to stop.
+func (s *inferenceSocket) serve(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer func() {
+		cancel()
+		s.wg.Wait()
+		s.conn.Close(websocket.StatusNormalClosure, "")
+	}()
+	go s.keepAlive(ctx)
+
+	for {
+		_, data, err := s.conn.Read(ctx)
+		if err != nil {
+			if websocket.CloseStatus(err) == -1 && ctx.Err() == nil {
+				slog.Debug("inference.ws_read_error", "error", err, "user_id", s.user.ID)
+			}
+			return
+		}
+
+		var msg models.WSClientMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			s.sendError(ctx, "", "invalid_request", "invalid frame")
+			continue
+		}
+		switch msg.Type {
+		case "ping":
+			s.send(ctx, models.WSServerMessage{Type: "pong", ID: msg.ID})
+		case "prompt":
+			s.start(ctx, msg)
+		case "cancel":
+			s.cancel(ctx, msg.ID)
+		default:
+			s.sendError(ctx, msg.ID, "invalid_request", "unknown frame type")
+		}
+	}
+}
+
+// start validates a prompt frame and runs its generation.
+func (s *inferenceSocket) start(ctx context.Context, msg models.WSClientMessage) {
+	if msg.ID == "" || msg.Request == nil {
+		s.sendError(ctx, msg.ID, "invalid_request", "id and request are required")
+		return
+	}
+	req := msg.Request
+	req.Stream = true
+	if req.Prompt == "" {
+		s.sendError(ctx, msg.ID, "invalid_request", "prompt is required")
+		return
+	}
+	if err := validatePrompt(req.Prompt); err != nil {
+		s.sendError(ctx, msg.ID, "invalid_request", err.Error())
+		return
+	}
+	if err := checkOutputOptions(req); err != nil {
+		s.sendError(ctx, msg.ID, "invalid_request", err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	if _, busy := s.gens[msg.ID]; busy {
+		s.mu.Unlock()
+		s.sendError(ctx, msg.ID, "duplicate_id", "a generation with this id is running")
+		return
+	}
+	if len(s.gens) >= maxSocketGenerations {
+		s.mu.Unlock()
+		s.sendError(ctx, msg.ID, "too_many_generations", "too many generations on this connection")
+		return
+	}
+	gen := &socketGeneration{}
+	s.gens[msg.ID] = gen
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer func() {
+			s.mu.Lock()
+			delete(s.gens, msg.ID)
+			s.mu.Unlock()
+		}()
+		s.run(ctx, msg.ID, gen, req)
+	}()
+}
+
+// run starts a generation and relays its events until "done" or until the
+// socket closes.
+func (s *inferenceSocket) run(ctx context.Context, id string, gen *socketGeneration, req *models.InferenceRequest) {
+	meta, err := s.h.streams.Start(ctx, req, s.user)
+	if err != nil {
+		code, msg := service.StreamErrorCode(err)
+		if code == "upstream_error" {
+			slog.Error("inference.ws_stream_error", "error", err, "user_id", s.user.ID)
+		}
+		s.sendError(ctx, id, code, msg)
+		s.sendEvent(ctx, id, 0, "done", models.StreamDone{Status: models.MessageStatusError})
+		return
+	}
+
+	s.mu.Lock()
+	gen.messageID = meta.MessageID
+	cancelled := gen.cancelled
+	s.mu.Unlock()
+	if cancelled {
+		s.cancelGeneration(ctx, meta.MessageID)
+	}
+
+	events, err := s.h.streams.Subscribe(ctx, meta.MessageID, s.user.ID, 0)
+	if err != nil {
+		slog.Error("inference.ws_subscribe_error", "error", err, "user_id", s.user.ID, "message_id", meta.MessageID)
+		s.sendError(ctx, id, "upstream_error", "stream unavailable")
+		return
+	}
+	for ev := range events {
+		if err := s.send(ctx, models.WSServerMessage{Type: ev.Event, ID: id, EventID: ev.ID, Data: ev.Data}); err != nil {
+			return
+		}
+	}
+}
+
+// cancel stops the generation started under id. Its stream still ends with
+// "usage" and a "done" event of status cancelled.
+func (s *inferenceSocket) cancel(ctx context.Context, id string) {
+	s.mu.Lock()
+	gen := s.gens[id]
+	var messageID uuid.UUID
+	if gen != nil {
+		gen.cancelled = true
+		messageID = gen.messageID
+	}
+	s.mu.Unlock()
+
+	if gen == nil {
+		s.sendError(ctx, id, "generation_not_found", "no generation with this id is running")
+		return
+	}
+	// Before its stream is open, run cancels it as soon as it is.
+	if messageID != uuid.Nil {
+		s.cancelGeneration(ctx, messageID)
+	}
+}
+
+// cancelGeneration cancels a running generation; one that has already ended
+// needs nothing more.
+func (s *inferenceSocket) cancelGeneration(ctx context.Context, messageID uuid.UUID) {
+	if _, err := s.h.streams.Cancel(ctx, messageID, s.user); err != nil && !errors.Is(err, service.ErrGenerationNotFound) {
+		slog.Error("inference.ws_cancel_error", "error", err, "user_id", s.user.ID, "message_id", messageID)
+	}
+}
+
+// keepAlive pings the client so proxies do not close an idle socket.
+func (s *inferenceSocket) keepAlive(ctx context.Context) {
+	interval := s.h.cfg.SSEHeartbeatInterval
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pingCtx, cancel := context.WithTimeout(ctx, socketWriteTimeout)
+			err := s.conn.Ping(pingCtx)
+			cancel()
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *inferenceSocket) sendEvent(ctx context.Context, id string, eventID int64, event string, data any) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return s.send(ctx, models.WSServerMessage{Type: event, ID: id, EventID: eventID, Data: b})
+}
+
+func (s *inferenceSocket) sendError(ctx context.Context, id, code, msg string) error {
+	return s.sendEvent(ctx, id, 0, "error", models.StreamError{Code: code, Message: msg})
+}
+
+func (s *inferenceSocket) send(ctx context.Context, msg models.WSServerMessage) error {
+	ctx, cancel := context.WithTimeout(ctx, socketWriteTimeout)
+	defer cancel()
+	err := wsjson.Write(ctx, s.conn, msg)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		slog.Debug("inference.ws_write_error", "error", err, "user_id", s.user.ID)
+	}
+	return err
+}
@@ -0,0 +1,133 @@
+// OpenAI-compatible handler — /v1/chat/completions and /v1/models.
+// Maps to design.swift: API Gateway → Prompt Orchestrator → Token Streamer (SSE)
+//
+// Lets OpenAI SDKs and tools use roognis by pointing their base URL at
+// /v1 and passing an API key. Responses, stream chunks and errors follow
+// the OpenAI wire format.
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/prakyathpnayak/roognis/internal/middleware"
+	"github.com/prakyathpnayak/roognis/internal/models"
+	"github.com/prakyathpnayak/roognis/internal/service"
+)
+
+// OpenAIHandler serves the OpenAI-compatible endpoints.
+type OpenAIHandler struct {
+	orchestrator *service.Orchestrator
+	created      int64 // reported as the creation time of every model
+}
+
+// NewOpenAIHandler creates the OpenAI-compatible handler.
+func NewOpenAIHandler(orch *service.Orchestrator) *OpenAIHandler {
+	return &OpenAIHandler{orchestrator: orch, created: time.Now().Unix()}
+}
+
+// ChatCompletions handles POST /v1/chat/completions.
+// Takes the OpenAI request (messages, model, stream, stream_options,
+// temperature, max_tokens or max_completion_tokens, response_format) and
+// answers from the caller's messages alone; nothing is stored. With stream,
+// the response is data-only SSE chunks ending with data: [DONE].
+func (h *OpenAIHandler) ChatCompletions(w http.ResponseWriter, r *http.Request) {
+	// The caller sends the whole conversation, so allow more than for a prompt.
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+
+	user := middleware.UserFromContext(r.Context())
+	if user == nil {
+		writeOpenAIError(w, http.StatusUnauthorized, "unauthorized", "invalid_request_error", "invalid_api_key")
+		return
+	}
+
+	var req models.ChatCompletionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid request body: "+err.Error(), "invalid_request_error", "")
+		return
+	}
+
+	if !req.Stream {
+		resp, err := h.orchestrator.ChatComplete(r.Context(), &req, user)
+		if err != nil {
+			writeChatError(w, err, user)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	// Headers are only sent with the first chunk, so errors before it still
+	// get a proper status. The stream outlives the server's write timeout.
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	var sse *service.SSEWriter
+	err := h.orchestrator.ChatCompleteStream(r.Context(), &req, user, func(chunk models.ChatCompletion) error {
+		if sse == nil {
+			var err error
+			if sse, err = service.NewSSEWriter(w); err != nil {
+				return err
+			}
+		}
+		return sse.WriteUnnumbered(chunk)
+	})
+	switch {
+	case err == nil:
+		sse.WriteDone()
+	case sse == nil:
+		writeChatError(w, err, user)
+	case r.Context().Err() == nil:
+		// Mid-stream failures are reported the way OpenAI does, as an
+		// error object in place of the next chunk.
+		slog.Error("openai.stream_error", "error", err, "user_id", user.ID)
+		sse.WriteUnnumbered(models.OpenAIError{Error: models.OpenAIErrorBody{Message: "inference failed: " + err.Error(), Type: "server_error"}})
+		sse.WriteDone()
+	}
+}
+
+// Models handles GET /v1/models.
+// Lists the models the key's owner may request.
+func (h *OpenAIHandler) Models(w http.ResponseWriter, r *http.Request) {
+	user := middleware.UserFromContext(r.Context())
+	if user == nil {
+		writeOpenAIError(w, http.StatusUnauthorized, "unauthorized", "invalid_request_error", "invalid_api_key")
+		return
+	}
+
+	allowed := h.orchestrator.ListModels(user.Role)
+	list := models.OpenAIModelList{Object: "list", Data: make([]models.OpenAIModel, len(allowed))}
+	for i, m := range allowed {
+		list.Data[i] = models.OpenAIModel{ID: m.ID, Object: "model", Created: h.created, OwnedBy: "roognis"}
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// writeChatError maps a chat completion error to an OpenAI error response.
+func writeChatError(w http.ResponseWriter, err error, user *models.User) {
+	var schemaErr *service.SchemaValidationError
+	switch {
+	case errors.Is(err, service.ErrInvalidChatRequest), errors.Is(err, service.ErrInvalidResponseFormat):
+		writeOpenAIError(w, http.StatusBadRequest, err.Error(), "invalid_request_error", "")
+	case errors.Is(err, service.ErrModelNotAllowed):
+		writeOpenAIError(w, http.StatusNotFound, err.Error(), "invalid_request_error", "model_not_found")
+	case errors.Is(err, service.ErrContextOverflow):
+		writeOpenAIError(w, http.StatusBadRequest, err.Error(), "invalid_request_error", "context_length_exceeded")
+	case errors.As(err, &schemaErr):
+		writeOpenAIError(w, http.StatusUnprocessableEntity, schemaErr.Error(), "invalid_request_error", "")
+	default:
+		slog.Error("openai.completion_error", "error", err, "user_id", user.ID)
+		writeOpenAIError(w, http.StatusInternalServerError, "inference failed: "+err.Error(), "server_error", "")
+	}
+}
+
+// writeOpenAIError writes an error in the OpenAI envelope. An empty code is
+// sent as null.
+func writeOpenAIError(w http.ResponseWriter, status int, msg, typ, code string) {
+	body := models.OpenAIError{Error: models.OpenAIErrorBody{Message: msg, Type: typ}}
+	if code != "" {
+		body.Error.Code = &code
+	}
+	writeJSON(w, status, body)
+}
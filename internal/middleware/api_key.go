@@ -0,0 +1,56 @@
+// API key authentication middleware for the OpenAI-compatible endpoints.
+// Maps to design.swift: AuthN/AuthZ (API keys for machine clients)
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/prakyathpnayak/roognis/internal/models"
+	"github.com/prakyathpnayak/roognis/internal/service"
+)
+
+// APIKeyAuth returns middleware that authenticates "Authorization: Bearer
+// rk_..." API keys. Errors use the OpenAI error envelope so SDKs report them
+// properly. The user is stored in the context as by Auth.
+func APIKeyAuth(keys *service.APIKeys) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			parts := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+			if len(parts) != 2 || !strings.EqualFold(parts[0], "bearer") || parts[1] == "" {
+				writeOpenAIError(w, http.StatusUnauthorized, "missing API key; pass it as a bearer token", "invalid_request_error", "missing_api_key")
+				return
+			}
+
+			user, err := keys.Authenticate(r.Context(), strings.TrimSpace(parts[1]))
+			if err != nil {
+				if !errors.Is(err, service.ErrInvalidAPIKey) {
+					slog.Error("api_key_auth.error", "error", err)
+					writeOpenAIError(w, http.StatusInternalServerError, "authentication failed", "server_error", "")
+					return
+				}
+				writeOpenAIError(w, http.StatusUnauthorized, "invalid API key", "invalid_request_error", "invalid_api_key")
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), userContextKey, user)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// writeOpenAIError writes an error in the OpenAI envelope. An empty code is
+// sent as null.
+func writeOpenAIError(w http.ResponseWriter, status int, msg, typ, code string) {
+	body := models.OpenAIError{Error: models.OpenAIErrorBody{Message: msg, Type: typ}}
+	if code != "" {
+		body.Error.Code = &code
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
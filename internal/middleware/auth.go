@@ -56,6 +56,11 @@ func Auth(cfg *config.Config, pool *db.Pool) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			header := r.Header.Get("Authorization")
+			// Browsers cannot set headers on a WebSocket handshake, so it
+			// may carry the token in the query instead.
+			if token := r.URL.Query().Get("access_token"); header == "" && token != "" && isWebSocketUpgrade(r) {
+				header = "Bearer " + token
+			}
 			if header == "" {
 				http.Error(w, `{"error":"missing authorization header","code":401}`, http.StatusUnauthorized)
 				return
@@ -94,6 +99,11 @@ func Auth(cfg *config.Config, pool *db.Pool) func(http.Handler) http.Handler {
 	}
 }
 
+// isWebSocketUpgrade reports whether r is a WebSocket handshake.
+func isWebSocketUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
 // UserFromContext extracts the authenticated user from the request context.
 func UserFromContext(ctx context.Context) *models.User {
 	u, _ := ctx.Value(userContextKey).(*models.User)
@@ -37,6 +37,12 @@ func (rw *responseWriter) Flush() {
 	}
 }
 
+// Unwrap returns the underlying writer, for http.ResponseController and
+// WebSocket upgrades.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // Logger returns middleware that logs every HTTP request.
 func Logger(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -4,6 +4,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -32,14 +33,51 @@ type User struct {
 	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
 }
 
+// APIKey authenticates a user on the OpenAI-compatible endpoints. Only the
+// SHA-256 hash of the key is stored.
+type APIKey struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	UserID     uuid.UUID  `json:"user_id" db:"user_id"`
+	Name       string     `json:"name" db:"name"`
+	Prefix     string     `json:"prefix" db:"prefix"` // first characters of the key
+	KeyHash    string     `json:"-" db:"key_hash"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+}
+
 // ── Conversation & Messages (Interaction Logger) ────────────────────
 
 type Conversation struct {
-	ID        uuid.UUID `json:"id" db:"id"`
-	UserID    uuid.UUID `json:"user_id" db:"user_id"`
-	Title     *string   `json:"title,omitempty" db:"title"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ID                       uuid.UUID  `json:"id" db:"id"`
+	UserID                   uuid.UUID  `json:"user_id" db:"user_id"`
+	Title                    *string    `json:"title,omitempty" db:"title"`
+	TitleSetByUser           bool       `json:"title_set_by_user" db:"title_set_by_user"`
+	Pinned                   bool       `json:"pinned" db:"pinned"`
+	ArchivedAt               *time.Time `json:"archived_at,omitempty" db:"archived_at"`
+	DeletedAt                *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`         // soft-deleted; restorable until purged
+	ActiveLeafID             *uuid.UUID `json:"active_leaf_id,omitempty" db:"active_leaf_id"` // last message of the active branch
+	ForkedFromConversationID *uuid.UUID `json:"forked_from_conversation_id,omitempty" db:"forked_from_conversation_id"`
+	ForkedFromMessageID      *uuid.UUID `json:"forked_from_message_id,omitempty" db:"forked_from_message_id"`
+	CreatedAt                time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt                time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// ConversationCursor is the keyset position in the conversation list, which
+// is ordered by pinned, updated_at and id, all descending.
+type ConversationCursor struct {
+	Pinned    bool      `json:"p"`
+	UpdatedAt time.Time `json:"u"`
+	ID        uuid.UUID `json:"i"`
+}
+
+// ConversationListParams selects a page of a user's conversations.
+type ConversationListParams struct {
+	Limit    int
+	After    *ConversationCursor
+	Archived *bool // nil = both; default in the API is false
+	Pinned   *bool // nil = both
+	Deleted  bool  // list soft-deleted conversations instead of live ones
 }
 
 type MessageRole string
@@ -48,49 +86,275 @@ const (
 	RoleUserMsg      MessageRole = "user"
 	RoleAssistantMsg MessageRole = "assistant"
 	RoleSystemMsg    MessageRole = "system"
+	RoleToolMsg      MessageRole = "tool"
+)
+
+// MessageStatus records how an assistant message ended.
+type MessageStatus string
+
+const (
+	MessageStatusComplete  MessageStatus = "complete"
+	MessageStatusCancelled MessageStatus = "cancelled" // client went away mid-answer
+	MessageStatusError     MessageStatus = "error"     // upstream failed mid-answer
 )
 
 type Message struct {
-	ID             uuid.UUID   `json:"id" db:"id"`
-	ConversationID uuid.UUID   `json:"conversation_id" db:"conversation_id"`
-	Role           MessageRole `json:"role" db:"role"`
-	Content        string      `json:"content" db:"content"`
-	TokenCount     *int        `json:"token_count,omitempty" db:"token_count"`
-	ModelUsed      *string     `json:"model_used,omitempty" db:"model_used"`
-	LatencyMs      *float64    `json:"latency_ms,omitempty" db:"latency_ms"`
-	CreatedAt      time.Time   `json:"created_at" db:"created_at"`
+	ID               uuid.UUID     `json:"id" db:"id"`
+	ConversationID   uuid.UUID     `json:"conversation_id" db:"conversation_id"`
+	ParentID         *uuid.UUID    `json:"parent_id,omitempty" db:"parent_id"` // previous message on the branch; nil for the first
+	Role             MessageRole   `json:"role" db:"role"`
+	Content          string        `json:"content" db:"content"`
+	TokenCount       *int          `json:"token_count,omitempty" db:"token_count"`
+	ModelUsed        *string       `json:"model_used,omitempty" db:"model_used"`
+	LatencyMs        *float64      `json:"latency_ms,omitempty" db:"latency_ms"`
+	Status           MessageStatus `json:"status" db:"status"`
+	FinishReason     *string       `json:"finish_reason,omitempty" db:"finish_reason"`
+	TTFTMs           *float64      `json:"ttft_ms,omitempty" db:"ttft_ms"` // time to first token
+	PromptTokens     *int          `json:"prompt_tokens,omitempty" db:"prompt_tokens"`
+	CompletionTokens *int          `json:"completion_tokens,omitempty" db:"completion_tokens"`
+	ToolCalls        []LLMToolCall `json:"tool_calls,omitempty" db:"tool_calls"`
+	ToolCallID       *string       `json:"tool_call_id,omitempty" db:"tool_call_id"`
+	CreatedAt        time.Time     `json:"created_at" db:"created_at"`
+}
+
+// ConversationSummary is a rolling summary of the oldest turns of a
+// conversation, from FirstMessageID through LastMessageID.
+type ConversationSummary struct {
+	ID             uuid.UUID `json:"id" db:"id"`
+	ConversationID uuid.UUID `json:"conversation_id" db:"conversation_id"`
+	Content        string    `json:"content" db:"content"`
+	FirstMessageID uuid.UUID `json:"first_message_id" db:"first_message_id"`
+	LastMessageID  uuid.UUID `json:"last_message_id" db:"last_message_id"`
+	CoveredUntil   time.Time `json:"covered_until" db:"covered_until"`
+	MessageCount   int       `json:"message_count" db:"message_count"`
+	ModelUsed      *string   `json:"model_used,omitempty" db:"model_used"`
+	TokenCount     *int      `json:"token_count,omitempty" db:"token_count"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+}
+
+// SemanticCacheEntry is a cached single-turn prompt in the similarity
+// cache tier, pointing at its answer in the response cache under CacheKey.
+type SemanticCacheEntry struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	Scope     string    `json:"scope" db:"scope"`
+	Prompt    string    `json:"prompt" db:"prompt"`
+	CacheKey  string    `json:"cache_key" db:"cache_key"`
+	Embedding []float32 `json:"-" db:"embedding"`
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
 }
 
 // ── API Request/Response ────────────────────────────────────────────
 
 // InferenceRequest is the client → Request Router contract.
 type InferenceRequest struct {
-	Prompt         string     `json:"prompt" validate:"required,min=1,max=32000"`
-	ConversationID *uuid.UUID `json:"conversation_id,omitempty"`
-	Stream         bool       `json:"stream"`
-	Model          string     `json:"model,omitempty"`
-	Temperature    *float64   `json:"temperature,omitempty"`
-	MaxTokens      *int       `json:"max_tokens,omitempty"`
+	Prompt         string          `json:"prompt" validate:"required,min=1,max=32000"`
+	ConversationID *uuid.UUID      `json:"conversation_id,omitempty"`
+	Stream         bool            `json:"stream"`
+	Model          string          `json:"model,omitempty"`
+	Temperature    *float64        `json:"temperature,omitempty"`
+	MaxTokens      *int            `json:"max_tokens,omitempty"`
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+
+	// Set by the regenerate and edit endpoints, not by clients: answer again
+	// the prompt of assistant message RegenerateOf, or send Prompt as a new
+	// version of user message EditOf. Both start a sibling branch.
+	RegenerateOf *uuid.UUID `json:"-"`
+	EditOf       *uuid.UUID `json:"-"`
+}
+
+// Response format types for structured output.
+const (
+	ResponseFormatText       = "text"
+	ResponseFormatJSONObject = "json_object"
+	ResponseFormatJSONSchema = "json_schema"
+)
+
+// ResponseFormat asks the model for JSON output, optionally matching a
+// schema. The shape follows the OpenAI chat completions API.
+type ResponseFormat struct {
+	Type       string            `json:"type"`
+	JSONSchema *JSONSchemaFormat `json:"json_schema,omitempty"`
+}
+
+type JSONSchemaFormat struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Schema      json.RawMessage `json:"schema"`
+	Strict      bool            `json:"strict,omitempty"`
 }
 
 // InferenceResponse is the non-streaming response.
 type InferenceResponse struct {
-	ID             uuid.UUID `json:"id"`
-	ConversationID uuid.UUID `json:"conversation_id"`
-	Content        string    `json:"content"`
-	Model          string    `json:"model"`
-	TokenCount     *int      `json:"token_count,omitempty"`
-	LatencyMs      float64   `json:"latency_ms"`
-	Cached         bool      `json:"cached"`
+	ID             uuid.UUID      `json:"id"` // the stored assistant message
+	ConversationID uuid.UUID      `json:"conversation_id"`
+	UserMessageID  *uuid.UUID     `json:"user_message_id,omitempty"`
+	Content        string         `json:"content"`
+	Model          string         `json:"model"`
+	TokenCount     *int           `json:"token_count,omitempty"`
+	LatencyMs      float64        `json:"latency_ms"`
+	Cached         bool           `json:"cached"`
+	ToolEvents     []ToolEvent    `json:"tool_events,omitempty"`
+	Context        *ContextBudget `json:"context,omitempty"`
+}
+
+// ContextBudget reports how the prompt was fitted into the model's context
+// window. All counts are in tokens of the named tokenizer.
+type ContextBudget struct {
+	Tokenizer       string `json:"tokenizer"`
+	ContextWindow   int    `json:"context_window"`
+	ReservedTokens  int    `json:"reserved_tokens"` // max_tokens kept free for the answer
+	SystemTokens    int    `json:"system_tokens"`   // system prompt + RAG context
+	HistoryTokens   int    `json:"history_tokens"`
+	PromptTokens    int    `json:"prompt_tokens"`
+	TotalTokens     int    `json:"total_tokens"`
+	HistoryMessages int    `json:"history_messages"` // history messages kept
+	DroppedMessages int    `json:"dropped_messages"` // oldest history messages trimmed
+}
+
+// ToolEvent surfaces a server-side tool invocation to the client, either as
+// an SSE event while streaming or in InferenceResponse.ToolEvents.
+type ToolEvent struct {
+	Type       string `json:"type"` // "tool_call" or "tool_result"
+	ToolCallID string `json:"tool_call_id"`
+	Name       string `json:"name"`
+	Arguments  string `json:"arguments,omitempty"`
+	Result     string `json:"result,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// MessageCursor is the keyset position of a message in a conversation's
+// history, which is ordered by created_at and id.
+type MessageCursor struct {
+	CreatedAt time.Time `json:"c"`
+	ID        uuid.UUID `json:"i"`
+}
+
+// MessageListParams selects a page of a conversation's history. At most one
+// of Before, After and Since is set; with none, the newest page is returned.
+type MessageListParams struct {
+	Limit  int
+	Before *MessageCursor
+	After  *MessageCursor
+	Since  *time.Time // messages created after this instant
+}
+
+// MessageBranch is one version of a message among its siblings (messages
+// with the same parent). Active marks the one on the active branch.
+type MessageBranch struct {
+	Message
+	Active bool `json:"active"`
 }
 
-// StreamChunk is a single SSE event: Token Streamer → Client.
+// ForkConversationRequest copies a conversation's history up to MessageID
+// into a new conversation.
+type ForkConversationRequest struct {
+	MessageID uuid.UUID `json:"message_id"`
+}
+
+// SwitchBranchRequest makes the branch through MessageID active.
+type SwitchBranchRequest struct {
+	MessageID uuid.UUID `json:"message_id"`
+}
+
+// UpdateConversationRequest is the PATCH /api/v1/conversations/{id} body.
+// Omitted fields are left unchanged.
+type UpdateConversationRequest struct {
+	Title    *string `json:"title,omitempty" validate:"omitempty,min=1,max=512"`
+	Pinned   *bool   `json:"pinned,omitempty"`
+	Archived *bool   `json:"archived,omitempty"`
+}
+
+// StreamChunk is a "delta" SSE event: Token Streamer → Client.
 type StreamChunk struct {
 	ID             uuid.UUID `json:"id"`
 	ConversationID uuid.UUID `json:"conversation_id"`
 	Delta          string    `json:"delta"`
 	FinishReason   *string   `json:"finish_reason,omitempty"`
 	Model          string    `json:"model,omitempty"`
+	Cached         bool      `json:"cached,omitempty"` // replayed from the response cache
+}
+
+// StreamMeta opens a stream: which conversation and assistant message the
+// deltas belong to, and whether they are replayed from the cache.
+type StreamMeta struct {
+	ConversationID uuid.UUID `json:"conversation_id"`
+	UserMessageID  uuid.UUID `json:"user_message_id"`
+	MessageID      uuid.UUID `json:"message_id"`
+	Model          string    `json:"model"`
+	Cached         bool      `json:"cached"`
+}
+
+// StreamUsage is the "usage" event sent once the answer is complete.
+type StreamUsage struct {
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	TTFTMs           float64 `json:"ttft_ms"`
+	LatencyMs        float64 `json:"latency_ms"`
+}
+
+// StreamDone is the "done" event that closes a stream. The IDs are unset
+// when the stream failed before the model was called.
+type StreamDone struct {
+	ConversationID *uuid.UUID    `json:"conversation_id,omitempty"`
+	MessageID      *uuid.UUID    `json:"message_id,omitempty"`
+	Status         MessageStatus `json:"status"`
+	FinishReason   string        `json:"finish_reason,omitempty"`
+}
+
+// StreamEvent is a buffered SSE event of a resumable stream. IDs count
+// from 1 per stream and are sent as the SSE id: field.
+type StreamEvent struct {
+	ID    int64           `json:"id"`
+	Event string          `json:"event"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// ActiveGeneration is a streamed generation that is still running.
+type ActiveGeneration struct {
+	MessageID      uuid.UUID `json:"message_id"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+	UserID         uuid.UUID `json:"user_id"`
+	Model          string    `json:"model"`
+	StartedAt      time.Time `json:"started_at"`
+}
+
+// WSClientMessage is a frame from a client of the inference WebSocket. Type
+// is "prompt" (with Request), "cancel" or "ping". ID is chosen by the client
+// and names the generation a prompt starts or a cancel stops.
+type WSClientMessage struct {
+	Type    string            `json:"type"`
+	ID      string            `json:"id,omitempty"`
+	Request *InferenceRequest `json:"request,omitempty"`
+}
+
+// WSServerMessage is a frame to a client of the inference WebSocket. For a
+// generation, Type is a stream event ("meta", "delta", ..., "done") with the
+// same data as over SSE, and EventID is its number in the stream. Frames
+// about the connection itself ("pong", "error") have no ID.
+type WSServerMessage struct {
+	Type    string          `json:"type"`
+	ID      string          `json:"id,omitempty"`
+	EventID int64           `json:"event_id,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// StreamError is the "error" event. Code is machine-readable, e.g.
+// "conversation_not_found" or "upstream_error".
+type StreamError struct {
+	Code    string `json:"code"`
+	Message string `json:"error"`
+}
+
+// ModelInfo describes a model the caller may select (GET /api/v1/models).
+type ModelInfo struct {
+	ID            string   `json:"id"`
+	Description   string   `json:"description,omitempty"`
+	ContextWindow int      `json:"context_window"`
+	Temperature   *float64 `json:"temperature,omitempty"`
+	MaxTokens     *int     `json:"max_tokens,omitempty"`
+	Default       bool     `json:"default"`
 }
 
 // AuthRequest represents login credentials.
@@ -117,10 +381,27 @@ type TokenResponse struct {
 
 // HealthResponse for the health check endpoint.
 type HealthResponse struct {
-	Status   string `json:"status"`
-	Version  string `json:"version"`
-	Database string `json:"database"`
-	Redis    string `json:"redis"`
+	Status       string            `json:"status"`
+	Version      string            `json:"version"`
+	Database     string            `json:"database"`
+	Redis        string            `json:"redis"`
+	LLMProviders map[string]string `json:"llm_providers,omitempty"` // provider → circuit breaker state
+	Cache        *CacheStats       `json:"cache,omitempty"`
+}
+
+// CacheStats reports the response cache tiers since startup.
+type CacheStats struct {
+	Local CacheTierStats `json:"local"`
+	Redis CacheTierStats `json:"redis"`
+}
+
+// CacheTierStats are the lookups answered and missed by one cache tier.
+// Entries and Bytes are only reported for the in-process tier.
+type CacheTierStats struct {
+	Hits    int64 `json:"hits"`
+	Misses  int64 `json:"misses"`
+	Entries int   `json:"entries,omitempty"`
+	Bytes   int64 `json:"bytes,omitempty"`
 }
 
 // ErrorResponse is the standard error envelope.
@@ -130,19 +411,169 @@ type ErrorResponse struct {
 	Code    int    `json:"code"`
 }
 
+// CreateAPIKeyRequest is the POST /api/v1/api-keys body.
+type CreateAPIKeyRequest struct {
+	Name string `json:"name" validate:"required,min=1,max=100"`
+}
+
+// CreatedAPIKey is returned once when a key is created; Key is not stored
+// and cannot be shown again.
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// ── OpenAI-compatible API (/v1) ─────────────────────────────────────
+
+// ChatCompletionRequest is the POST /v1/chat/completions body. The
+// unsupported OpenAI parameters are decoded only so that requests setting
+// them are rejected rather than answered without them.
+type ChatCompletionRequest struct {
+	Model               string          `json:"model"`
+	Messages            []ChatMessage   `json:"messages"`
+	Stream              bool            `json:"stream,omitempty"`
+	StreamOptions       *StreamOptions  `json:"stream_options,omitempty"`
+	Temperature         *float64        `json:"temperature,omitempty"`
+	MaxTokens           *int            `json:"max_tokens,omitempty"`
+	MaxCompletionTokens *int            `json:"max_completion_tokens,omitempty"` // newer name of max_tokens
+	N                   *int            `json:"n,omitempty"`                     // only 1 is supported
+	ResponseFormat      *ResponseFormat `json:"response_format,omitempty"`
+
+	// Unsupported
+	Tools             json.RawMessage `json:"tools,omitempty"`
+	ToolChoice        json.RawMessage `json:"tool_choice,omitempty"`
+	ParallelToolCalls json.RawMessage `json:"parallel_tool_calls,omitempty"`
+	Functions         json.RawMessage `json:"functions,omitempty"`
+	FunctionCall      json.RawMessage `json:"function_call,omitempty"`
+	Stop              json.RawMessage `json:"stop,omitempty"`
+	TopP              json.RawMessage `json:"top_p,omitempty"`
+	PresencePenalty   json.RawMessage `json:"presence_penalty,omitempty"`
+	FrequencyPenalty  json.RawMessage `json:"frequency_penalty,omitempty"`
+	LogitBias         json.RawMessage `json:"logit_bias,omitempty"`
+	Logprobs          json.RawMessage `json:"logprobs,omitempty"`
+	TopLogprobs       json.RawMessage `json:"top_logprobs,omitempty"`
+	Seed              json.RawMessage `json:"seed,omitempty"`
+}
+
+// ChatMessage is a message of a chat completion request. Content is a string
+// or an array of content parts, of which only text parts are supported.
+type ChatMessage struct {
+	Role       string          `json:"role"`
+	Content    json.RawMessage `json:"content,omitempty"`
+	Name       string          `json:"name,omitempty"`
+	ToolCalls  []LLMToolCall   `json:"tool_calls,omitempty"`
+	ToolCallID string          `json:"tool_call_id,omitempty"`
+}
+
+// ChatContentPart is one part of an array message content.
+type ChatContentPart struct {
+	Type string `json:"type"`
+	Text string `json:"text,omitempty"`
+}
+
+// ChatCompletion is a chat completion response ("chat.completion") or a
+// streamed chunk of one ("chat.completion.chunk").
+type ChatCompletion struct {
+	ID      string                 `json:"id"`
+	Object  string                 `json:"object"`
+	Created int64                  `json:"created"`
+	Model   string                 `json:"model"`
+	Choices []ChatCompletionChoice `json:"choices"`
+	Usage   *LLMUsage              `json:"usage,omitempty"`
+}
+
+// ChatCompletionChoice carries Message in a response and Delta in a chunk.
+// Logprobs is always null; FinishReason is null until the last chunk.
+type ChatCompletionChoice struct {
+	Index        int                    `json:"index"`
+	Message      *ChatCompletionMessage `json:"message,omitempty"`
+	Delta        *ChatCompletionMessage `json:"delta,omitempty"`
+	Logprobs     *struct{}              `json:"logprobs"`
+	FinishReason *string                `json:"finish_reason"`
+}
+
+type ChatCompletionMessage struct {
+	Role    string  `json:"role,omitempty"`
+	Content *string `json:"content,omitempty"`
+}
+
+// OpenAIModelList is the GET /v1/models response.
+type OpenAIModelList struct {
+	Object string        `json:"object"` // always "list"
+	Data   []OpenAIModel `json:"data"`
+}
+
+type OpenAIModel struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"` // always "model"
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+// OpenAIError is the error envelope of the /v1 endpoints.
+type OpenAIError struct {
+	Error OpenAIErrorBody `json:"error"`
+}
+
+type OpenAIErrorBody struct {
+	Message string  `json:"message"`
+	Type    string  `json:"type"` // e.g. "invalid_request_error"
+	Param   *string `json:"param"`
+	Code    *string `json:"code"`
+}
+
 // LLM internal types for talking to the OpenAI-compatible API.
 
 type LLMMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string        `json:"role"`
+	Content    string        `json:"content"`
+	ToolCalls  []LLMToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string        `json:"tool_call_id,omitempty"`
+	Name       string        `json:"name,omitempty"`
+}
+
+// LLMToolCall is a function call requested by the model. In streamed deltas
+// Index identifies which call a fragment belongs to and Arguments arrives in
+// pieces.
+type LLMToolCall struct {
+	Index    *int            `json:"index,omitempty"`
+	ID       string          `json:"id,omitempty"`
+	Type     string          `json:"type,omitempty"`
+	Function LLMFunctionCall `json:"function"`
+}
+
+type LLMFunctionCall struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"` // JSON-encoded object
+}
+
+// LLMTool declares a function the model may call.
+type LLMTool struct {
+	Type     string         `json:"type"` // always "function"
+	Function LLMFunctionDef `json:"function"`
+}
+
+type LLMFunctionDef struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters"` // JSON Schema object
 }
 
 type LLMRequest struct {
-	Model       string       `json:"model"`
-	Messages    []LLMMessage `json:"messages"`
-	Temperature float64      `json:"temperature"`
-	MaxTokens   int          `json:"max_tokens,omitempty"`
-	Stream      bool         `json:"stream"`
+	Model          string          `json:"model"`
+	Messages       []LLMMessage    `json:"messages"`
+	Temperature    float64         `json:"temperature"`
+	MaxTokens      int             `json:"max_tokens,omitempty"`
+	Stream         bool            `json:"stream"`
+	StreamOptions  *StreamOptions  `json:"stream_options,omitempty"`
+	Tools          []LLMTool       `json:"tools,omitempty"`
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+}
+
+// StreamOptions asks OpenAI-compatible servers to end a stream with a
+// usage-only chunk.
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
 }
 
 type LLMChoice struct {
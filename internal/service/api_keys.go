@@ -0,0 +1,126 @@
+// API keys — long-lived credentials for the OpenAI-compatible endpoints.
+// Maps to design.swift: AuthN/AuthZ (API keys for machine clients)
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/prakyathpnayak/roognis/internal/db"
+	"github.com/prakyathpnayak/roognis/internal/models"
+)
+
+// APIKeyPrefix starts every API key, so keys are recognisable and cannot be
+// mistaken for JWTs.
+const APIKeyPrefix = "rk_"
+
+// apiKeyShownPrefix is how much of a key is stored in clear to tell keys
+// apart in listings.
+const apiKeyShownPrefix = 10
+
+var (
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	ErrInvalidAPIKey  = errors.New("invalid api key")
+)
+
+// APIKeys manages users' API keys.
+type APIKeys struct {
+	pool *db.Pool
+}
+
+// NewAPIKeys creates the API key service.
+func NewAPIKeys(pool *db.Pool) *APIKeys {
+	return &APIKeys{pool: pool}
+}
+
+// GenerateAPIKey returns a new random API key.
+func GenerateAPIKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("api keys: generate: %w", err)
+	}
+	return APIKeyPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashAPIKey returns the hex SHA-256 of key, as stored. Keys are random, so
+// a fast hash is enough.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Create issues a new key for the user. The key is only returned here.
+func (a *APIKeys) Create(ctx context.Context, userID uuid.UUID, name string) (*models.CreatedAPIKey, error) {
+	key, err := GenerateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+	k := models.APIKey{
+		ID:      uuid.New(),
+		UserID:  userID,
+		Name:    name,
+		Prefix:  key[:apiKeyShownPrefix],
+		KeyHash: HashAPIKey(key),
+	}
+	if err := a.pool.CreateAPIKey(ctx, &k); err != nil {
+		return nil, fmt.Errorf("api keys: create: %w", err)
+	}
+	slog.Info("api_keys.created", "id", k.ID, "user_id", userID)
+	return &models.CreatedAPIKey{APIKey: k, Key: key}, nil
+}
+
+// List returns the user's keys, newest first.
+func (a *APIKeys) List(ctx context.Context, userID uuid.UUID) ([]models.APIKey, error) {
+	keys, err := a.pool.ListAPIKeys(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("api keys: list: %w", err)
+	}
+	return keys, nil
+}
+
+// Revoke revokes one of the user's keys.
+func (a *APIKeys) Revoke(ctx context.Context, id, userID uuid.UUID) error {
+	ok, err := a.pool.RevokeAPIKey(ctx, id, userID)
+	if err != nil {
+		return fmt.Errorf("api keys: revoke: %w", err)
+	}
+	if !ok {
+		return ErrAPIKeyNotFound
+	}
+	slog.Info("api_keys.revoked", "id", id, "user_id", userID)
+	return nil
+}
+
+// Authenticate returns the active user owning key, which must not be
+// revoked.
+func (a *APIKeys) Authenticate(ctx context.Context, key string) (*models.User, error) {
+	if !strings.HasPrefix(key, APIKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+	k, err := a.pool.GetAPIKeyByHash(ctx, HashAPIKey(key))
+	if err != nil {
+		return nil, fmt.Errorf("api keys: get: %w", err)
+	}
+	if k == nil || k.RevokedAt != nil {
+		return nil, ErrInvalidAPIKey
+	}
+	user, err := a.pool.GetUserByID(ctx, k.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("api keys: get user: %w", err)
+	}
+	if user == nil || !user.IsActive {
+		return nil, ErrInvalidAPIKey
+	}
+	if err := a.pool.TouchAPIKey(ctx, k.ID); err != nil {
+		slog.Warn("api_keys.touch_error", "id", k.ID, "error", err)
+	}
+	return user, nil
+}
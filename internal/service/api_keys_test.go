@@ -0,0 +1,23 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateAPIKey(t *testing.T) {
+	a, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b, _ := GenerateAPIKey()
+	if !strings.HasPrefix(a, APIKeyPrefix) || len(a) != len(APIKeyPrefix)+43 {
+		t.Fatalf("unexpected key format %q", a)
+	}
+	if a == b {
+		t.Fatal("expected distinct keys")
+	}
+	if h := HashAPIKey(a); len(h) != 64 || h != HashAPIKey(a) || h == HashAPIKey(b) {
+		t.Fatalf("unexpected hash %q", h)
+	}
+}
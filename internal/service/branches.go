@@ -0,0 +1,248 @@
+// Conversation branches — regenerate, edit-and-resend, branch switching and forks.
+// Maps to design.swift: Interaction Logger (message tree) → Context Assembler
+//
+// Messages form a tree through parent_id. Regenerating an answer adds a new
+// reply to the same prompt; editing a prompt adds a sibling of it. Each
+// conversation follows the branch ending at its active leaf, which is what
+// buildMessages sends to the model and what the history endpoints return.
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/prakyathpnayak/roognis/internal/models"
+)
+
+var (
+	ErrMessageNotFound     = errors.New("message not found")
+	ErrNotAssistantMessage = errors.New("only assistant messages can be regenerated")
+	ErrNotUserMessage      = errors.New("only user messages can be edited")
+	ErrInvalidForkPoint    = errors.New("cannot fork in the middle of a tool call")
+)
+
+// turn is where a new exchange attaches to the conversation tree.
+type turn struct {
+	history   []models.Message // branch the prompt follows, oldest first
+	prompt    string
+	parentID  *uuid.UUID // parent of the user message; nil for the first
+	userMsgID uuid.UUID
+	existing  bool // the user message is already stored (regenerate)
+}
+
+// resolveTurn works out the history and the user message of the turn asked
+// for by req: by default a new prompt after the active leaf, otherwise a new
+// answer to the prompt of req.RegenerateOf or a new version of req.EditOf.
+func (o *Orchestrator) resolveTurn(ctx context.Context, conv *models.Conversation, req *models.InferenceRequest) (*turn, error) {
+	switch {
+	case req.RegenerateOf != nil:
+		msg, err := o.conversationMessage(ctx, conv.ID, *req.RegenerateOf)
+		if err != nil {
+			return nil, err
+		}
+		if msg.Role != models.RoleAssistantMsg {
+			return nil, ErrNotAssistantMessage
+		}
+		path, err := o.pool.GetMessagePath(ctx, msg.ID)
+		if err != nil {
+			return nil, fmt.Errorf("orchestrator: get message path: %w", err)
+		}
+		// Answer again the prompt that led to msg, which may be several
+		// tool round trips back.
+		i := lastUserMessage(path)
+		if i < 0 {
+			return nil, ErrNotAssistantMessage
+		}
+		return &turn{
+			history:   path[:i],
+			prompt:    path[i].Content,
+			parentID:  path[i].ParentID,
+			userMsgID: path[i].ID,
+			existing:  true,
+		}, nil
+
+	case req.EditOf != nil:
+		msg, err := o.conversationMessage(ctx, conv.ID, *req.EditOf)
+		if err != nil {
+			return nil, err
+		}
+		if msg.Role != models.RoleUserMsg {
+			return nil, ErrNotUserMessage
+		}
+		t := &turn{prompt: req.Prompt, parentID: msg.ParentID, userMsgID: uuid.New()}
+		if msg.ParentID != nil {
+			if t.history, err = o.pool.GetMessagePath(ctx, *msg.ParentID); err != nil {
+				return nil, fmt.Errorf("orchestrator: get message path: %w", err)
+			}
+		}
+		return t, nil
+
+	default:
+		history, err := o.pool.GetConversationMessages(ctx, conv.ID)
+		if err != nil {
+			return nil, fmt.Errorf("orchestrator: get history: %w", err)
+		}
+		t := &turn{history: history, prompt: req.Prompt, userMsgID: uuid.New()}
+		if len(history) > 0 {
+			t.parentID = &history[len(history)-1].ID
+		}
+		return t, nil
+	}
+}
+
+// ListMessageBranches returns the versions of a message (itself and its
+// siblings), oldest first, in a conversation owned by the user.
+func (o *Orchestrator) ListMessageBranches(ctx context.Context, conversationID, userID, messageID uuid.UUID) ([]models.MessageBranch, error) {
+	if _, err := o.ownedConversation(ctx, conversationID, userID); err != nil {
+		return nil, err
+	}
+	msg, err := o.conversationMessage(ctx, conversationID, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	siblings, err := o.pool.ListMessageSiblings(ctx, msg)
+	if err != nil {
+		return nil, fmt.Errorf("orchestrator: list siblings: %w", err)
+	}
+	active, err := o.pool.GetConversationMessages(ctx, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("orchestrator: get history: %w", err)
+	}
+
+	branches := make([]models.MessageBranch, len(siblings))
+	for i, m := range siblings {
+		branches[i] = models.MessageBranch{Message: m, Active: onBranch(active, m.ID)}
+	}
+	return branches, nil
+}
+
+// SwitchBranch makes the branch through messageID active, continuing to the
+// newest message below it, and returns the updated conversation.
+func (o *Orchestrator) SwitchBranch(ctx context.Context, conversationID, userID, messageID uuid.UUID) (*models.Conversation, error) {
+	conv, err := o.ownedConversation(ctx, conversationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := o.conversationMessage(ctx, conv.ID, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	leaf, err := o.pool.LatestDescendant(ctx, msg.ID)
+	if err != nil {
+		return nil, fmt.Errorf("orchestrator: find leaf: %w", err)
+	}
+	if err := o.pool.SetActiveLeaf(ctx, conv.ID, leaf); err != nil {
+		return nil, fmt.Errorf("orchestrator: set active leaf: %w", err)
+	}
+	conv.ActiveLeafID = &leaf
+	return conv, nil
+}
+
+// ForkConversation copies the branch of conversation conversationID ending at
+// messageID into a new conversation owned by user, recording the origin.
+// Users can fork their own conversations, teachers their students', e.g. to
+// continue a student's thread as a demonstration, and admins anyone's.
+func (o *Orchestrator) ForkConversation(ctx context.Context, conversationID uuid.UUID, user *models.User, messageID uuid.UUID) (*models.Conversation, error) {
+	source, err := o.pool.GetConversation(ctx, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("orchestrator: get conversation: %w", err)
+	}
+	if source == nil || source.DeletedAt != nil {
+		return nil, ErrConversationNotFound
+	}
+	var owner *models.User
+	if source.UserID != user.ID {
+		if owner, err = o.pool.GetUserByID(ctx, source.UserID); err != nil {
+			return nil, fmt.Errorf("orchestrator: get user: %w", err)
+		}
+	}
+	if !canFork(source, owner, user) {
+		return nil, ErrConversationForbidden
+	}
+
+	msg, err := o.conversationMessage(ctx, source.ID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	// The copy must not end with tool calls that have no results.
+	if msg.Role == models.RoleToolMsg || len(msg.ToolCalls) > 0 {
+		return nil, ErrInvalidForkPoint
+	}
+	path, err := o.pool.GetMessagePath(ctx, msg.ID)
+	if err != nil {
+		return nil, fmt.Errorf("orchestrator: get message path: %w", err)
+	}
+
+	fork := &models.Conversation{
+		ID:                       uuid.New(),
+		UserID:                   user.ID,
+		Title:                    source.Title,
+		TitleSetByUser:           source.TitleSetByUser,
+		ForkedFromConversationID: &source.ID,
+		ForkedFromMessageID:      &msg.ID,
+	}
+	if err := o.pool.ForkConversation(ctx, fork, path); err != nil {
+		return nil, fmt.Errorf("orchestrator: fork conversation: %w", err)
+	}
+	slog.Info("orchestrator.conversation_forked",
+		"id", fork.ID,
+		"source_id", source.ID,
+		"message_id", msg.ID,
+		"user_id", user.ID,
+		"messages", len(path),
+	)
+
+	created, err := o.pool.GetConversation(ctx, fork.ID)
+	if err != nil {
+		return nil, fmt.Errorf("orchestrator: get conversation: %w", err)
+	}
+	if created == nil {
+		return nil, ErrConversationNotFound
+	}
+	return created, nil
+}
+
+// canFork reports whether user may fork conv, owned by owner (nil when
+// unknown).
+func canFork(conv *models.Conversation, owner, user *models.User) bool {
+	switch {
+	case conv.UserID == user.ID, user.Role == models.RoleAdmin:
+		return true
+	case user.Role == models.RoleTeacher:
+		return owner != nil && owner.ID == conv.UserID && owner.Role == models.RoleStudent
+	}
+	return false
+}
+
+// conversationMessage loads a message, which must belong to conversationID.
+func (o *Orchestrator) conversationMessage(ctx context.Context, conversationID, messageID uuid.UUID) (*models.Message, error) {
+	msg, err := o.pool.GetMessage(ctx, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("orchestrator: get message: %w", err)
+	}
+	if msg == nil || msg.ConversationID != conversationID {
+		return nil, ErrMessageNotFound
+	}
+	return msg, nil
+}
+
+// lastUserMessage returns the index of the last user message in path, or -1.
+func lastUserMessage(path []models.Message) int {
+	for i := len(path) - 1; i >= 0; i-- {
+		if path[i].Role == models.RoleUserMsg {
+			return i
+		}
+	}
+	return -1
+}
+
+// onBranch reports whether message id is part of branch.
+func onBranch(branch []models.Message, id uuid.UUID) bool {
+	return slices.ContainsFunc(branch, func(m models.Message) bool { return m.ID == id })
+}
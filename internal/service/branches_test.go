@@ -0,0 +1,69 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/prakyathpnayak/roognis/internal/models"
+)
+
+func TestLastUserMessageSkipsToolRoundTrips(t *testing.T) {
+	path := []models.Message{
+		{Role: models.RoleUserMsg, Content: "first"},
+		{Role: models.RoleAssistantMsg, Content: "answer"},
+		{Role: models.RoleUserMsg, Content: "what is 6*7?"},
+		{Role: models.RoleAssistantMsg, ToolCalls: []models.LLMToolCall{{ID: "c1"}}},
+		{Role: models.RoleToolMsg, Content: "42"},
+		{Role: models.RoleAssistantMsg, Content: "It is 42."},
+	}
+
+	if i := lastUserMessage(path); i != 2 {
+		t.Fatalf("expected the prompt behind the tool round trip, got index %d", i)
+	}
+	if i := lastUserMessage(path[1:2]); i != -1 {
+		t.Fatalf("expected -1 without a user message, got %d", i)
+	}
+}
+
+func TestOnBranch(t *testing.T) {
+	branch := testHistory(3)
+	if !onBranch(branch, branch[1].ID) {
+		t.Fatal("expected a message of the branch to be found")
+	}
+	if onBranch(branch, uuid.New()) {
+		t.Fatal("expected a message of another branch not to be found")
+	}
+}
+
+func TestCanFork(t *testing.T) {
+	student := &models.User{ID: uuid.New(), Role: models.RoleStudent}
+	teacher := &models.User{ID: uuid.New(), Role: models.RoleTeacher}
+	admin := &models.User{ID: uuid.New(), Role: models.RoleAdmin}
+
+	for _, tc := range []struct {
+		name  string
+		owner *models.User
+		user  *models.User
+		want  bool
+	}{
+		{"student own", student, student, true},
+		{"teacher own", teacher, teacher, true},
+		{"student to student", student, &models.User{ID: uuid.New(), Role: models.RoleStudent}, false},
+		{"parent to student", student, &models.User{ID: uuid.New(), Role: models.RoleParent}, false},
+		{"teacher to student", student, &models.User{ID: uuid.New(), Role: models.RoleTeacher}, true},
+		{"teacher to teacher", teacher, &models.User{ID: uuid.New(), Role: models.RoleTeacher}, false},
+		{"teacher to admin", admin, &models.User{ID: uuid.New(), Role: models.RoleTeacher}, false},
+		{"teacher to unknown owner", nil, &models.User{ID: uuid.New(), Role: models.RoleTeacher}, false},
+		{"admin to student", student, &models.User{ID: uuid.New(), Role: models.RoleAdmin}, true},
+		{"admin to teacher", teacher, &models.User{ID: uuid.New(), Role: models.RoleAdmin}, true},
+	} {
+		ownerID := uuid.New()
+		if tc.owner != nil {
+			ownerID = tc.owner.ID
+		}
+		conv := &models.Conversation{ID: uuid.New(), UserID: ownerID}
+		if got := canFork(conv, tc.owner, tc.user); got != tc.want {
+			t.Fatalf("%s: canFork = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
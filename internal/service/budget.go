@@ -0,0 +1,80 @@
+// Context budgeter — fits the prompt into the model's context window.
+// Maps to design.swift: Context Assembler (hard token budget)
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/prakyathpnayak/roognis/internal/models"
+)
+
+// ErrContextOverflow is returned when the system prompt, RAG context and new
+// prompt alone do not fit in the context window.
+var ErrContextOverflow = errors.New("prompt does not fit in the model context window")
+
+// FitContext trims conversation history, oldest turns first, until the
+// messages fit in window minus reserve (the tokens kept free for the
+// answer). messages must be laid out as the orchestrator builds them:
+// leading system messages (system prompt, RAG context), history, and the new
+// user prompt last. System messages and the prompt are never dropped.
+func FitContext(tok Tokenizer, messages []models.LLMMessage, window, reserve int) ([]models.LLMMessage, models.ContextBudget, error) {
+	budget := models.ContextBudget{
+		Tokenizer:      tok.Name(),
+		ContextWindow:  window,
+		ReservedTokens: reserve,
+	}
+	if len(messages) == 0 {
+		return messages, budget, nil
+	}
+
+	nSystem := 0
+	for nSystem < len(messages)-1 && messages[nSystem].Role == "system" {
+		nSystem++
+	}
+	system := messages[:nSystem]
+	history := messages[nSystem : len(messages)-1]
+	prompt := messages[len(messages)-1]
+
+	for _, m := range system {
+		budget.SystemTokens += CountMessage(tok, m)
+	}
+	budget.PromptTokens = CountMessage(tok, prompt)
+
+	available := window - reserve - tokensPerReply - budget.SystemTokens - budget.PromptTokens
+	if available < 0 {
+		budget.TotalTokens = budget.SystemTokens + budget.PromptTokens + tokensPerReply
+		return nil, budget, fmt.Errorf("%w: needs %d tokens, %d available after reserving %d for the answer",
+			ErrContextOverflow, budget.TotalTokens, window-reserve, reserve)
+	}
+
+	// Walk history newest → oldest and keep what fits.
+	costs := make([]int, len(history))
+	keep := len(history)
+	used := 0
+	for i := len(history) - 1; i >= 0; i-- {
+		costs[i] = CountMessage(tok, history[i])
+		if used+costs[i] > available {
+			break
+		}
+		used += costs[i]
+		keep = i
+	}
+	// A tool result is only valid after the assistant message that called
+	// it, so never start the kept history on one.
+	for keep < len(history) && history[keep].Role == string(models.RoleToolMsg) {
+		used -= costs[keep]
+		keep++
+	}
+
+	budget.HistoryTokens = used
+	budget.HistoryMessages = len(history) - keep
+	budget.DroppedMessages = keep
+	budget.TotalTokens = budget.SystemTokens + budget.HistoryTokens + budget.PromptTokens + tokensPerReply
+
+	out := make([]models.LLMMessage, 0, nSystem+budget.HistoryMessages+1)
+	out = append(out, system...)
+	out = append(out, history[keep:]...)
+	out = append(out, prompt)
+	return out, budget, nil
+}
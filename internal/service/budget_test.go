@@ -0,0 +1,73 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/prakyathpnayak/roognis/internal/models"
+)
+
+// wordTokenizer counts one token per space-separated word.
+type wordTokenizer struct{}
+
+func (wordTokenizer) Name() string          { return "words" }
+func (wordTokenizer) Count(text string) int { return len(strings.Fields(text)) }
+
+func TestFitContextTrimsOldestHistory(t *testing.T) {
+	msgs := []models.LLMMessage{
+		{Role: "system", Content: "be helpful"},          // 4 + 2
+		{Role: "user", Content: "one two three four"},    // 4 + 4
+		{Role: "assistant", Content: "five six"},         // 4 + 2
+		{Role: "user", Content: "seven"},                 // 4 + 1
+		{Role: "assistant", Content: "eight nine"},       // 4 + 2
+		{Role: "user", Content: "what did I say first?"}, // 4 + 5
+	}
+
+	// window 40 - reserve 10 - reply 3 - system 6 - prompt 9 = 12 for history
+	out, budget, err := FitContext(wordTokenizer{}, msgs, 40, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(out) != 4 || out[1].Content != "seven" || out[2].Content != "eight nine" {
+		t.Fatalf("expected system + last two history messages + prompt, got %+v", out)
+	}
+	if budget.HistoryMessages != 2 || budget.DroppedMessages != 2 || budget.HistoryTokens != 11 {
+		t.Fatalf("unexpected budget: %+v", budget)
+	}
+	if budget.TotalTokens != 6+11+9+3 {
+		t.Fatalf("unexpected total: %d", budget.TotalTokens)
+	}
+}
+
+func TestFitContextDoesNotStartOnToolResult(t *testing.T) {
+	msgs := []models.LLMMessage{
+		{Role: "user", Content: "compute a big sum please"},
+		{Role: "assistant", ToolCalls: []models.LLMToolCall{{ID: "c1", Function: models.LLMFunctionCall{Name: "calculator", Arguments: "x x x x x x"}}}},
+		{Role: "tool", ToolCallID: "c1", Content: "42"},
+		{Role: "assistant", Content: "it is 42"},
+		{Role: "user", Content: "thanks"},
+	}
+
+	// Room for the tool result and the answer but not the call.
+	out, budget, err := FitContext(wordTokenizer{}, msgs, 30, 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, m := range out {
+		if m.Role == "tool" {
+			t.Fatalf("orphaned tool result kept: %+v", out)
+		}
+	}
+	if budget.HistoryMessages != 1 {
+		t.Fatalf("expected only the final answer kept, got %+v", budget)
+	}
+}
+
+func TestFitContextOverflow(t *testing.T) {
+	msgs := []models.LLMMessage{{Role: "user", Content: strings.Repeat("word ", 50)}}
+	_, _, err := FitContext(wordTokenizer{}, msgs, 40, 10)
+	if !errors.Is(err, ErrContextOverflow) {
+		t.Fatalf("expected ErrContextOverflow, got %v", err)
+	}
+}
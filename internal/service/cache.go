@@ -2,7 +2,10 @@
 // Maps to design.swift: Response Cache (Redis / In-Process)
 //
 // Uses a SHA-256 hash of prompt+model as the cache key.
-// Nil-safe: if Redis is unavailable, all operations are no-ops.
+// Lookups go to the in-process tier first and then to Redis. Writes and
+// invalidations are announced over Redis pub/sub so other instances drop
+// their local copies. If Redis is unavailable, only the in-process tier
+// is used (or nothing, when it is disabled).
 package service
 
 import (
@@ -11,21 +14,72 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 	"github.com/prakyathpnayak/roognis/internal/models"
 )
 
-// Cache wraps Redis for response caching.
+// cacheInvalidateChannel carries "<instance> <key>" for every key written
+// or invalidated, so other instances drop their local copy.
+const cacheInvalidateChannel = "cache:invalidate"
+
+// cacheWaitInterval is how often WaitJSON polls for a value being
+// generated by another instance.
+const cacheWaitInterval = 100 * time.Millisecond
+
+// releaseLock deletes a lock only if it still holds this holder's token, so
+// a holder whose lock expired cannot release the next one.
+var releaseLock = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// renewLock extends a lock only if it still holds this holder's token.
+var renewLock = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+// cacheEnvelope is how SetJSON stores a value: with the time it stops being
+// fresh. After that it is kept for the stale window, so it can be served
+// while it is regenerated.
+type cacheEnvelope struct {
+	Value      json.RawMessage `json:"value"`
+	FreshUntil time.Time       `json:"fresh_until"`
+}
+
+// Cache wraps Redis for response caching, with an in-process tier in front.
 type Cache struct {
-	rdb *redis.Client
-	ttl time.Duration
+	rdb        *redis.Client
+	ttl        time.Duration
+	stale      time.Duration // how long JSON values are kept past ttl
+	normalizer *Normalizer   // applied to key fingerprints; nil = raw text
+	local      *localCache   // nil = disabled
+	instance   string        // tags this instance's invalidation messages
+
+	localHits, localMisses atomic.Int64
+	redisHits, redisMisses atomic.Int64
 }
 
-// NewCache creates a cache service. rdb may be nil (no-op mode).
-func NewCache(rdb *redis.Client, ttl time.Duration) *Cache {
-	return &Cache{rdb: rdb, ttl: ttl}
+// NewCache creates a cache service. rdb may be nil (local tier only), and
+// so may norm (keys hash the raw text). JSON values stay fresh for ttl and
+// can be served stale for another stale. localMaxBytes bounds the
+// in-process tier; 0 disables it.
+func NewCache(rdb *redis.Client, ttl, stale time.Duration, norm *Normalizer, localMaxBytes int64) *Cache {
+	return &Cache{
+		rdb:        rdb,
+		ttl:        ttl,
+		stale:      stale,
+		normalizer: norm,
+		local:      newLocalCache(localMaxBytes),
+		instance:   uuid.NewString(),
+	}
 }
 
 type semanticCacheFingerprint struct {
@@ -57,6 +111,25 @@ func SemanticContextHash(messages []models.LLMMessage, model, userID string, tem
 	return fmt.Sprintf("cache:inference:%x", h), nil
 }
 
+// ContextKey is SemanticContextHash over the normalized messages. The
+// messages themselves are not modified. override, when set, replaces the
+// cache's normalizer.
+func (c *Cache) ContextKey(override *Normalizer, messages []models.LLMMessage, model, userID string, temperature float64, maxTokens int) (string, error) {
+	return SemanticContextHash(c.keyNormalizer(override).Messages(messages), model, userID, temperature, maxTokens)
+}
+
+// PromptKey is SemanticHash over the normalized prompt.
+func (c *Cache) PromptKey(override *Normalizer, prompt, model, userID string, temperature float64, maxTokens int) string {
+	return SemanticHash(c.keyNormalizer(override).Normalize(prompt), model, userID, temperature, maxTokens)
+}
+
+func (c *Cache) keyNormalizer(override *Normalizer) *Normalizer {
+	if override != nil {
+		return override
+	}
+	return c.normalizer
+}
+
 // SemanticHash returns a deterministic cache key for an inference request.
 // Kept for backward compatibility in tests and non-context use cases.
 func SemanticHash(prompt, model, userID string, temperature float64, maxTokens int) string {
@@ -65,61 +138,227 @@ func SemanticHash(prompt, model, userID string, temperature float64, maxTokens i
 	return fmt.Sprintf("cache:inference:%x", h)
 }
 
-// Get retrieves a cached string value.
+// Get retrieves a cached string value, from the in-process tier if it has
+// it and otherwise from Redis. Redis hits are copied to the in-process tier
+// for the rest of their TTL.
 func (c *Cache) Get(ctx context.Context, key string) (string, error) {
+	if val, ok := c.local.get(key); ok {
+		c.localHits.Add(1)
+		return string(val), nil
+	}
+	if c.local != nil {
+		c.localMisses.Add(1)
+	}
 	if c.rdb == nil {
 		return "", nil
 	}
-	val, err := c.rdb.Get(ctx, key).Result()
+
+	pipe := c.rdb.Pipeline()
+	get := pipe.Get(ctx, key)
+	pttl := pipe.PTTL(ctx, key)
+	pipe.Exec(ctx)
+	val, err := get.Result()
 	if err == redis.Nil {
+		c.redisMisses.Add(1)
 		return "", nil
 	}
 	if err != nil {
 		slog.Warn("cache.get_error", "key", key, "error", err)
 		return "", err
 	}
+	c.redisHits.Add(1)
+
+	ttl := c.ttl + c.stale
+	if d, err := pttl.Result(); err == nil && d > 0 && d < ttl {
+		ttl = d
+	}
+	c.local.set(key, []byte(val), ttl)
 	return val, nil
 }
 
 // Set stores a string value.
 func (c *Cache) Set(ctx context.Context, key, value string) error {
+	return c.set(ctx, key, []byte(value), c.ttl)
+}
+
+// set stores value in both tiers for ttl and tells other instances to drop
+// their copy of key.
+func (c *Cache) set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.local.set(key, value, ttl)
 	if c.rdb == nil {
 		return nil
 	}
-	return c.rdb.Set(ctx, key, value, c.ttl).Err()
+	pipe := c.rdb.Pipeline()
+	pipe.Set(ctx, key, value, ttl)
+	pipe.Publish(ctx, cacheInvalidateChannel, c.instance+" "+key)
+	_, err := pipe.Exec(ctx)
+	return err
 }
 
-// GetJSON retrieves and unmarshals a cached JSON value.
+// GetJSON retrieves and unmarshals a fresh cached JSON value.
 func (c *Cache) GetJSON(ctx context.Context, key string, dest any) (bool, error) {
+	found, stale, err := c.GetStaleJSON(ctx, key, dest)
+	return found && !stale, err
+}
+
+// GetStaleJSON retrieves and unmarshals a cached JSON value, reporting
+// whether it is past its TTL and only kept for the stale window.
+func (c *Cache) GetStaleJSON(ctx context.Context, key string, dest any) (found, stale bool, err error) {
 	raw, err := c.Get(ctx, key)
 	if err != nil || raw == "" {
-		return false, err
+		return false, false, err
 	}
-	if err := json.Unmarshal([]byte(raw), dest); err != nil {
+	var env cacheEnvelope
+	if err := json.Unmarshal([]byte(raw), &env); err != nil || env.FreshUntil.IsZero() {
+		// Stored before values were wrapped; always fresh.
+		env = cacheEnvelope{Value: json.RawMessage(raw)}
+	}
+	if err := json.Unmarshal(env.Value, dest); err != nil {
 		slog.Warn("cache.unmarshal_error", "key", key, "error", err)
-		return false, nil
+		return false, false, nil
 	}
-	return true, nil
+	return true, !env.FreshUntil.IsZero() && time.Now().After(env.FreshUntil), nil
 }
 
 // SetJSON marshals and stores a value as JSON.
 func (c *Cache) SetJSON(ctx context.Context, key string, value any) error {
-	if c.rdb == nil {
+	return c.SetJSONTTL(ctx, key, value, 0)
+}
+
+// SetJSONTTL is SetJSON with a TTL of its own; 0 means the cache's.
+func (c *Cache) SetJSONTTL(ctx context.Context, key string, value any, ttl time.Duration) error {
+	if c.rdb == nil && c.local == nil {
 		return nil
 	}
+	if ttl <= 0 {
+		ttl = c.ttl
+	}
 	data, err := json.Marshal(value)
 	if err != nil {
 		return fmt.Errorf("cache.marshal: %w", err)
 	}
-	return c.rdb.Set(ctx, key, data, c.ttl).Err()
+	data, err = json.Marshal(cacheEnvelope{Value: data, FreshUntil: time.Now().Add(ttl)})
+	if err != nil {
+		return fmt.Errorf("cache.marshal: %w", err)
+	}
+	return c.set(ctx, key, data, ttl+c.stale)
+}
+
+// WaitJSON polls for a fresh value of key, written by another instance,
+// until it appears or wait has passed.
+func (c *Cache) WaitJSON(ctx context.Context, key string, dest any, wait time.Duration) bool {
+	ctx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+	ticker := time.NewTicker(cacheWaitInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+			if found, _ := c.GetJSON(ctx, key, dest); found {
+				return true
+			}
+		}
+	}
 }
 
-// Invalidate removes a key from the cache.
+// Lock takes the lock on regenerating key across instances. The lock is
+// renewed every ttl/3 until released, so ttl only bounds how long it
+// outlives a holder that died. It returns the func that releases it, or
+// ok=false while another instance holds it. Without Redis there is no other
+// instance, so the lock is always taken.
+func (c *Cache) Lock(ctx context.Context, key string, ttl time.Duration) (release func(), ok bool, err error) {
+	if c.rdb == nil {
+		return func() {}, true, nil
+	}
+	lockKey, token := "lock:"+key, uuid.NewString()
+	ok, err = c.rdb.SetNX(ctx, lockKey, token, ttl).Result()
+	if err != nil || !ok {
+		return nil, false, err
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				n, err := renewLock.Run(ctx, c.rdb, []string{lockKey}, token, ttl.Milliseconds()).Int()
+				if err != nil {
+					slog.Warn("cache.lock_renew_error", "key", key, "error", err)
+				} else if n == 0 {
+					slog.Warn("cache.lock_lost", "key", key)
+					return
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-stopped
+		if err := releaseLock.Run(ctx, c.rdb, []string{lockKey}, token).Err(); err != nil {
+			slog.Warn("cache.unlock_error", "key", key, "error", err)
+		}
+	}, true, nil
+}
+
+// Invalidate removes a key from the cache on every instance.
 func (c *Cache) Invalidate(ctx context.Context, key string) error {
+	c.local.remove(key)
 	if c.rdb == nil {
 		return nil
 	}
-	return c.rdb.Del(ctx, key).Err()
+	pipe := c.rdb.Pipeline()
+	pipe.Del(ctx, key)
+	pipe.Publish(ctx, cacheInvalidateChannel, c.instance+" "+key)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Listen drops local copies of keys written or invalidated by other
+// instances, until ctx is cancelled. Without Redis or the in-process tier
+// there is nothing to do.
+func (c *Cache) Listen(ctx context.Context) {
+	if c.rdb == nil || c.local == nil {
+		return
+	}
+	sub := c.rdb.Subscribe(ctx, cacheInvalidateChannel)
+	go func() {
+		for msg := range sub.Channel() {
+			instance, key, ok := strings.Cut(msg.Payload, " ")
+			if !ok || instance == c.instance {
+				continue
+			}
+			c.local.remove(key)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		sub.Close() // ends sub.Channel()
+	}()
+}
+
+// Stats returns the hit and miss counts of both tiers since startup.
+func (c *Cache) Stats() models.CacheStats {
+	entries, bytes := c.local.stats()
+	return models.CacheStats{
+		Local: models.CacheTierStats{
+			Hits:    c.localHits.Load(),
+			Misses:  c.localMisses.Load(),
+			Entries: entries,
+			Bytes:   bytes,
+		},
+		Redis: models.CacheTierStats{
+			Hits:   c.redisHits.Load(),
+			Misses: c.redisMisses.Load(),
+		},
+	}
 }
 
 // Ping checks Redis connectivity.
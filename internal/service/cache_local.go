@@ -0,0 +1,115 @@
+// In-process cache tier — a byte-bounded LRU in front of Redis.
+// Maps to design.swift: Response Cache (Redis / In-Process)
+//
+// Hot answers are served without a network round-trip, and without Redis
+// (single-node dev) this tier is the whole cache. Entries expire with
+// their TTL and the least recently used ones are evicted once the values
+// exceed the configured size.
+package service
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// localCache is a size-bounded LRU of byte values with per-entry expiry.
+// Safe for concurrent use; a nil *localCache stores nothing.
+type localCache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	size     int64
+	order    *list.List // front = most recently used
+	entries  map[string]*list.Element
+}
+
+// localEntry is one value of the local cache.
+type localEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// newLocalCache creates a local cache holding at most maxBytes of keys and
+// values, or returns nil when maxBytes is not positive.
+func newLocalCache(maxBytes int64) *localCache {
+	if maxBytes <= 0 {
+		return nil
+	}
+	return &localCache{maxBytes: maxBytes, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+// get returns the live value of key.
+func (l *localCache) get(key string) ([]byte, bool) {
+	if l == nil {
+		return nil, false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	el, ok := l.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*localEntry)
+	if !time.Now().Before(e.expires) {
+		l.removeElement(el)
+		return nil, false
+	}
+	l.order.MoveToFront(el)
+	return e.value, true
+}
+
+// set stores value under key for ttl, evicting the least recently used
+// entries to make room. Values larger than the whole cache are not kept.
+func (l *localCache) set(key string, value []byte, ttl time.Duration) {
+	if l == nil || ttl <= 0 {
+		return
+	}
+	n := entrySize(key, value)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if el, ok := l.entries[key]; ok {
+		l.removeElement(el)
+	}
+	if n > l.maxBytes {
+		return
+	}
+	for l.size+n > l.maxBytes {
+		l.removeElement(l.order.Back())
+	}
+	l.entries[key] = l.order.PushFront(&localEntry{key: key, value: value, expires: time.Now().Add(ttl)})
+	l.size += n
+}
+
+// remove drops key.
+func (l *localCache) remove(key string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if el, ok := l.entries[key]; ok {
+		l.removeElement(el)
+	}
+}
+
+// stats returns the number of entries and their size in bytes.
+func (l *localCache) stats() (entries int, bytes int64) {
+	if l == nil {
+		return 0, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.entries), l.size
+}
+
+// removeElement drops el. Callers hold l.mu.
+func (l *localCache) removeElement(el *list.Element) {
+	e := l.order.Remove(el).(*localEntry)
+	delete(l.entries, e.key)
+	l.size -= entrySize(e.key, e.value)
+}
+
+func entrySize(key string, value []byte) int64 {
+	return int64(len(key) + len(value))
+}
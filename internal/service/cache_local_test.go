@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLocalCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	l := newLocalCache(30) // room for three 10-byte entries
+	l.set("k1", []byte("12345678"), time.Hour)
+	l.set("k2", []byte("12345678"), time.Hour)
+	l.set("k3", []byte("12345678"), time.Hour)
+	l.get("k1") // k2 is now the least recently used
+
+	l.set("k4", []byte("12345678"), time.Hour)
+	if _, ok := l.get("k2"); ok {
+		t.Fatal("expected k2 to be evicted")
+	}
+	for _, k := range []string{"k1", "k3", "k4"} {
+		if _, ok := l.get(k); !ok {
+			t.Fatalf("expected %s to be kept", k)
+		}
+	}
+	if n, size := l.stats(); n != 3 || size != 30 {
+		t.Fatalf("expected 3 entries of 30 bytes, got %d of %d", n, size)
+	}
+
+	l.set("k1", []byte("1"), time.Hour)
+	if _, size := l.stats(); size != 23 {
+		t.Fatalf("expected replaced entry to be resized to 23 bytes, got %d", size)
+	}
+
+	l.set("big", make([]byte, 64), time.Hour)
+	if _, ok := l.get("big"); ok {
+		t.Fatal("expected value larger than the cache not to be kept")
+	}
+	if n, _ := l.stats(); n != 3 {
+		t.Fatalf("expected oversized value to evict nothing, got %d entries", n)
+	}
+}
+
+func TestLocalCacheExpires(t *testing.T) {
+	l := newLocalCache(1 << 10)
+	l.set("k", []byte("v"), time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := l.get("k"); ok {
+		t.Fatal("expected expired entry to miss")
+	}
+	if n, size := l.stats(); n != 0 || size != 0 {
+		t.Fatalf("expected expired entry to be dropped, got %d entries of %d bytes", n, size)
+	}
+}
+
+func TestCacheWithoutRedisUsesLocalTier(t *testing.T) {
+	ctx := context.Background()
+	c := NewCache(nil, time.Hour, 0, nil, 1<<20)
+
+	var got map[string]string
+	if found, _ := c.GetJSON(ctx, "k", &got); found {
+		t.Fatal("expected miss on empty cache")
+	}
+	if err := c.SetJSON(ctx, "k", map[string]string{"a": "b"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if found, _ := c.GetJSON(ctx, "k", &got); !found || got["a"] != "b" {
+		t.Fatalf("expected local hit, got %v %v", found, got)
+	}
+
+	c.Invalidate(ctx, "k")
+	if found, _ := c.GetJSON(ctx, "k", &got); found {
+		t.Fatal("expected miss after invalidation")
+	}
+
+	stats := c.Stats()
+	if stats.Local.Hits != 1 || stats.Local.Misses != 2 || stats.Redis.Hits != 0 || stats.Redis.Misses != 0 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	off := NewCache(nil, time.Hour, 0, nil, 0)
+	off.SetJSON(ctx, "k", "v")
+	if found, _ := off.GetJSON(ctx, "k", &got); found {
+		t.Fatal("expected no caching with both tiers off")
+	}
+}
@@ -0,0 +1,162 @@
+// Query normalizer — canonical text for cache fingerprints.
+// Maps to design.swift: Response Cache → Query Normalizer → Semantic Hash Generator
+//
+// Prompts that differ only in Unicode form, spacing or repeated question
+// and exclamation marks should share a cache entry. Anything that can
+// change what is asked (symbols, case, tense) is kept, since a collision
+// serves the answer to a different question. The normalizer rewrites
+// message text for the fingerprint only; what is sent to the model is
+// never changed.
+package service
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/prakyathpnayak/roognis/internal/config"
+	"github.com/prakyathpnayak/roognis/internal/models"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalizer canonicalizes text before it is hashed into a cache key. It
+// always applies NFKC, maps typographic quotes and dashes to ASCII,
+// collapses whitespace and runs of "?" and "!" and drops a trailing "?".
+// Case folding, stopword removal and number canonicalization are optional.
+// A nil *Normalizer leaves text unchanged.
+type Normalizer struct {
+	Case      bool // case fold; "Polish" and "polish" then share a key
+	Stopwords bool // drop articles and politeness words
+	Numbers   bool // "1,000" → "1000", "three" → "3"
+}
+
+// NewNormalizer creates the normalizer configured by CACHE_NORMALIZE*, or
+// returns nil when normalization is off.
+func NewNormalizer(cfg *config.Config) *Normalizer {
+	if !cfg.CacheNormalize {
+		return nil
+	}
+	return &Normalizer{Case: cfg.CacheNormalizeCase, Stopwords: cfg.CacheNormalizeStopwords, Numbers: cfg.CacheNormalizeNumbers}
+}
+
+var typographic = strings.NewReplacer(
+	"‘", "'", "’", "'", "‚", "'", "′", "'",
+	"“", `"`, "”", `"`, "„", `"`, "″", `"`,
+	"–", "-", "—", "-", "−", "-",
+	"…", "...",
+)
+
+// stopwords are dropped with Normalizer.Stopwords. Verbs, modals,
+// pronouns, question words and negations are kept, since they change what
+// is asked ("who was X" is not "who is X"); so is "a", which is also a
+// name ("vitamin a").
+var stopwords = map[string]bool{
+	"an": true, "the": true,
+	"please": true, "kindly": true,
+}
+
+var numberWords = map[string]string{
+	"zero": "0", "one": "1", "two": "2", "three": "3", "four": "4", "five": "5",
+	"six": "6", "seven": "7", "eight": "8", "nine": "9", "ten": "10",
+	"eleven": "11", "twelve": "12", "thirteen": "13", "fourteen": "14", "fifteen": "15",
+	"sixteen": "16", "seventeen": "17", "eighteen": "18", "nineteen": "19", "twenty": "20",
+	"thirty": "30", "forty": "40", "fifty": "50", "sixty": "60", "seventy": "70",
+	"eighty": "80", "ninety": "90", "hundred": "100", "thousand": "1000",
+}
+
+var numeral = regexp.MustCompile(`^[+-]?(\d{1,3}(,\d{3})+|\d+)(\.\d+)?$`)
+
+// Normalize returns the canonical form of s.
+func (n *Normalizer) Normalize(s string) string {
+	if n == nil {
+		return s
+	}
+	s = norm.NFKC.String(s)
+	s = typographic.Replace(s)
+	if n.Case {
+		s = cases.Fold().String(s)
+	}
+	s = collapsePunctuation(s)
+
+	words := strings.Fields(s)
+	if n.Stopwords || n.Numbers {
+		kept := words[:0]
+		for _, w := range words {
+			// Word lists match regardless of case: "Please" starts a sentence.
+			core := strings.TrimFunc(w, unicode.IsPunct)
+			if n.Stopwords && stopwords[strings.ToLower(core)] {
+				continue
+			}
+			if n.Numbers && core != "" {
+				if c, ok := canonicalNumber(strings.ToLower(core)); ok {
+					w = strings.Replace(w, core, c, 1)
+				}
+			}
+			kept = append(kept, w)
+		}
+		words = kept
+	}
+	return strings.TrimSuffix(strings.Join(words, " "), "?")
+}
+
+// Messages returns copies of messages with normalized content, for hashing.
+func (n *Normalizer) Messages(messages []models.LLMMessage) []models.LLMMessage {
+	if n == nil {
+		return messages
+	}
+	out := make([]models.LLMMessage, len(messages))
+	for i, m := range messages {
+		m.Content = n.Normalize(m.Content)
+		out[i] = m
+	}
+	return out
+}
+
+// collapsePunctuation turns runs of "?" or "!" into one and drops
+// whitespace before closing punctuation ("why ??" → "why?"). Other symbols
+// are kept as written: "2**10" is not "2*10".
+func collapsePunctuation(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	var prev rune
+	pendingSpace := false
+	for _, r := range s {
+		switch {
+		case unicode.IsSpace(r):
+			pendingSpace = true
+			continue
+		case (r == '?' || r == '!') && r == prev:
+			pendingSpace = false
+			continue
+		}
+		if pendingSpace && !strings.ContainsRune(".,;:!?", r) {
+			b.WriteByte(' ')
+		}
+		pendingSpace = false
+		b.WriteRune(r)
+		prev = r
+	}
+	return b.String()
+}
+
+// canonicalNumber rewrites a number word or numeral to plain digits.
+func canonicalNumber(w string) (string, bool) {
+	if d, ok := numberWords[w]; ok {
+		return d, true
+	}
+	if !numeral.MatchString(w) {
+		return "", false
+	}
+	plain := strings.ReplaceAll(w, ",", "")
+	// Beyond float64 precision the digits are kept as written.
+	if len(plain) > 15 {
+		return plain, true
+	}
+	f, err := strconv.ParseFloat(plain, 64)
+	if err != nil {
+		return "", false
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64), true
+}
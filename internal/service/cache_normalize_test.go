@@ -0,0 +1,89 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prakyathpnayak/roognis/internal/models"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name string
+		n    *Normalizer
+		in   string
+		want string
+	}{
+		{"nil passthrough", nil, "  What IS this??  ", "  What IS this??  "},
+		{"whitespace", &Normalizer{}, "  what\tis \n this ", "what is this"},
+		{"case kept", &Normalizer{}, "What is Polish", "What is Polish"},
+		{"case fold", &Normalizer{Case: true}, "Straße", "strasse"},
+		{"nfkc fullwidth", &Normalizer{}, "ＡＢＣ １２３", "ABC 123"},
+		{"nfkc ligature", &Normalizer{}, "ﬁnd it", "find it"},
+		{"curly quote", &Normalizer{}, "What’s up", "What's up"},
+		{"repeated question marks", &Normalizer{}, "what???", "what"},
+		{"repeated exclamation marks", &Normalizer{}, "wow!!!", "wow!"},
+		{"space before punctuation", &Normalizer{}, "hello , world ?", "hello, world"},
+		{"stopwords off", &Normalizer{}, "what is the capital", "what is the capital"},
+		{"stopwords", &Normalizer{Stopwords: true}, "Please, what is the capital of France?", "what is capital of France"},
+		{"numbers", &Normalizer{Numbers: true}, "add 1,000 and three, then 3.50", "add 1000 and 3, then 3.5"},
+		{"numbers off", &Normalizer{}, "add 1,000 and three", "add 1,000 and three"},
+	}
+	for _, tt := range tests {
+		if got := tt.n.Normalize(tt.in); got != tt.want {
+			t.Errorf("%s: Normalize(%q) = %q, want %q", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeKeepsDistinctQuestions(t *testing.T) {
+	n := &Normalizer{Stopwords: true, Numbers: true}
+	pairs := [][2]string{
+		{"what is 2**10", "what is 2*10"},
+		{"a//b", "a/b"},
+		{"x == y", "x = y"},
+		{"--verbose", "-verbose"},
+		{"what is 5!", "what is 5"},
+		{"what is 5.", "what is 5"},
+		{"translate Polish", "translate polish"},
+		{"call getUser", "call getuser"},
+		{"who was Lincoln", "who is Lincoln"},
+		{"can I go", "will I go"},
+		{"who are you", "who am I"},
+		{"vitamin a deficiency", "vitamin deficiency"},
+	}
+	for _, p := range pairs {
+		if a, b := n.Normalize(p[0]), n.Normalize(p[1]); a == b {
+			t.Errorf("%q and %q both normalize to %q", p[0], p[1], a)
+		}
+	}
+}
+
+func TestCacheContextKeyNormalizes(t *testing.T) {
+	msgs := func(prompt string) []models.LLMMessage {
+		return []models.LLMMessage{{Role: "system", Content: "Be brief."}, {Role: "user", Content: prompt}}
+	}
+
+	c := NewCache(nil, time.Hour, 0, &Normalizer{}, 0)
+	a, err := c.ContextKey(nil, msgs("What’s   photosynthesis??"), "m", "u", 0.7, 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b, _ := c.ContextKey(nil, msgs("What's photosynthesis"), "m", "u", 0.7, 100)
+	if a != b {
+		t.Fatalf("expected equal keys for variant prompts, got %s and %s", a, b)
+	}
+
+	original := msgs("What’s   photosynthesis??")
+	c.ContextKey(nil, original, "m", "u", 0.7, 100)
+	if original[1].Content != "What’s   photosynthesis??" {
+		t.Fatalf("ContextKey modified the messages: %q", original[1].Content)
+	}
+
+	raw := NewCache(nil, time.Hour, 0, nil, 0)
+	a, _ = raw.ContextKey(nil, msgs("What’s   photosynthesis??"), "m", "u", 0.7, 100)
+	b, _ = raw.ContextKey(nil, msgs("what's photosynthesis"), "m", "u", 0.7, 100)
+	if a == b {
+		t.Fatal("expected distinct keys without a normalizer")
+	}
+}
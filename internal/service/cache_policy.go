@@ -0,0 +1,111 @@
+// Cache policy — which answers are cached, for how long, under which keys.
+// Maps to design.swift: Response Cache → Cache Policy Manager
+//
+// A high-temperature creative prompt should not be answered from the cache,
+// while a factual question to a small model can be kept for a day. The
+// policy is a list of rules loaded from CACHE_POLICY_PATH and matched on
+// the route, model, effective temperature, user role, prompt length and
+// conversation depth of each request; the first match decides.
+package service
+
+import (
+	"log/slog"
+	"slices"
+	"time"
+	"unicode/utf8"
+
+	"github.com/prakyathpnayak/roognis/internal/config"
+	"github.com/prakyathpnayak/roognis/internal/models"
+)
+
+// CachePolicy decides how requests are cached. A nil *CachePolicy caches
+// every request with the cache's defaults.
+type CachePolicy struct {
+	rules []config.CacheRule
+}
+
+// NewCachePolicy loads the policy from CACHE_POLICY_PATH, or returns nil
+// when none is configured.
+func NewCachePolicy(cfg *config.Config) (*CachePolicy, error) {
+	if cfg.CachePolicyPath == "" {
+		return nil, nil
+	}
+	loaded, err := config.LoadCachePolicy(cfg.CachePolicyPath)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("cache.policy_loaded", "rules", len(loaded.Rules))
+	return &CachePolicy{rules: loaded.Rules}, nil
+}
+
+// CacheRequest is what the policy knows about a request.
+type CacheRequest struct {
+	Route       string // config.CacheRoute*
+	Model       string
+	Temperature float64
+	Role        models.UserRole
+	Prompt      string
+	Depth       int // earlier user and assistant messages in the conversation
+}
+
+// CacheDecision is how a request is cached.
+type CacheDecision struct {
+	Rule       string        // matched rule; "" when none matched
+	Cache      bool          // look up and store the answer
+	TTL        time.Duration // 0 = CACHE_TTL_SECONDS
+	Normalizer *Normalizer   // for the cache key; nil = the cache's own
+}
+
+// Decide returns the decision of the first rule matching r.
+func (p *CachePolicy) Decide(r CacheRequest) CacheDecision {
+	if p == nil {
+		return CacheDecision{Cache: true}
+	}
+	promptChars := utf8.RuneCountInString(r.Prompt)
+	for _, rule := range p.rules {
+		if !matches(rule.Match, r, promptChars) {
+			continue
+		}
+		d := CacheDecision{
+			Rule:  rule.Name,
+			Cache: rule.Cache == nil || *rule.Cache,
+			TTL:   time.Duration(rule.TTLSeconds) * time.Second,
+		}
+		if rule.Normalize != nil {
+			d.Normalizer = &Normalizer{Case: rule.Normalize.Case, Stopwords: rule.Normalize.Stopwords, Numbers: rule.Normalize.Numbers}
+		}
+		slog.Debug("cache.policy_match", "rule", d.Rule, "cache", d.Cache, "ttl", d.TTL, "route", r.Route, "model", r.Model, "temperature", r.Temperature, "role", r.Role, "prompt_chars", promptChars, "depth", r.Depth)
+		return d
+	}
+	slog.Debug("cache.policy_default", "route", r.Route, "model", r.Model, "temperature", r.Temperature, "role", r.Role, "prompt_chars", promptChars, "depth", r.Depth)
+	return CacheDecision{Cache: true}
+}
+
+// matches reports whether r meets every condition of m.
+func matches(m config.CacheMatch, r CacheRequest, promptChars int) bool {
+	switch {
+	case len(m.Routes) > 0 && !slices.Contains(m.Routes, r.Route),
+		len(m.Models) > 0 && !slices.Contains(m.Models, r.Model),
+		len(m.Roles) > 0 && !slices.Contains(m.Roles, string(r.Role)),
+		m.MinTemperature != nil && r.Temperature < *m.MinTemperature,
+		m.MaxTemperature != nil && r.Temperature > *m.MaxTemperature,
+		promptChars < m.MinPromptChars,
+		m.MaxPromptChars > 0 && promptChars > m.MaxPromptChars,
+		r.Depth < m.MinDepth,
+		m.MaxDepth != nil && r.Depth > *m.MaxDepth:
+		return false
+	}
+	return true
+}
+
+// conversationDepth counts the user and assistant messages before the last
+// one, which is the prompt being answered.
+func conversationDepth(messages []models.LLMMessage) int {
+	depth := 0
+	for _, m := range messages {
+		if m.Role == string(models.RoleUserMsg) || m.Role == string(models.RoleAssistantMsg) {
+			depth++
+		}
+	}
+	return max(depth-1, 0)
+}
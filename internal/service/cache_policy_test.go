@@ -0,0 +1,81 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prakyathpnayak/roognis/internal/config"
+	"github.com/prakyathpnayak/roognis/internal/models"
+)
+
+const testCachePolicy = `{
+	"rules": [
+		{"name": "creative", "match": {"min_temperature": 1.0}, "cache": false},
+		{"name": "deep", "match": {"min_depth": 4}, "cache": false},
+		{"name": "facade", "match": {"routes": ["chat_completions"]}, "ttl_seconds": 600},
+		{"name": "staff", "match": {"roles": ["admin"]}, "ttl_seconds": 60},
+		{"name": "short", "match": {"models": ["small"], "max_prompt_chars": 10, "max_depth": 0}, "ttl_seconds": 86400, "normalize": {"stopwords": true}}
+	]
+}`
+
+func TestCachePolicyDecide(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache_policy.json")
+	if err := os.WriteFile(path, []byte(testCachePolicy), 0o600); err != nil {
+		t.Fatalf("write policy: %v", err)
+	}
+	p, err := NewCachePolicy(&config.Config{CachePolicyPath: path})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	base := CacheRequest{Route: config.CacheRouteComplete, Model: "small", Temperature: 0.2, Role: models.RoleStudent, Prompt: "what is pi", Depth: 0}
+	tests := []struct {
+		name  string
+		req   func(r *CacheRequest)
+		rule  string
+		cache bool
+		ttl   time.Duration
+	}{
+		{"first match wins", func(r *CacheRequest) { r.Temperature = 1.2; r.Role = models.RoleAdmin }, "creative", false, 0},
+		{"depth", func(r *CacheRequest) { r.Depth = 5 }, "deep", false, 0},
+		{"route", func(r *CacheRequest) { r.Route = config.CacheRouteChatCompletions }, "facade", true, 10 * time.Minute},
+		{"other route", func(r *CacheRequest) { r.Route = config.CacheRouteStream }, "short", true, 24 * time.Hour},
+		{"role", func(r *CacheRequest) { r.Role = models.RoleAdmin }, "staff", true, time.Minute},
+		{"all conditions", func(r *CacheRequest) {}, "short", true, 24 * time.Hour},
+		{"prompt too long", func(r *CacheRequest) { r.Prompt = "what is the value of pi" }, "", true, 0},
+		{"other model", func(r *CacheRequest) { r.Model = "large" }, "", true, 0},
+	}
+	for _, tt := range tests {
+		r := base
+		tt.req(&r)
+		d := p.Decide(r)
+		if d.Rule != tt.rule || d.Cache != tt.cache || d.TTL != tt.ttl {
+			t.Errorf("%s: got %+v, want rule %q cache %v ttl %v", tt.name, d, tt.rule, tt.cache, tt.ttl)
+		}
+	}
+
+	if d := p.Decide(base); d.Normalizer == nil || !d.Normalizer.Stopwords {
+		t.Fatalf("expected the rule's normalizer, got %+v", d.Normalizer)
+	}
+	if d := (*CachePolicy)(nil).Decide(base); !d.Cache || d.TTL != 0 || d.Normalizer != nil {
+		t.Fatalf("expected nil policy to cache with defaults, got %+v", d)
+	}
+}
+
+func TestConversationDepth(t *testing.T) {
+	msgs := []models.LLMMessage{
+		{Role: "system", Content: "s"},
+		{Role: "user", Content: "a"},
+		{Role: "assistant", Content: "b"},
+		{Role: "tool", Content: "t"},
+		{Role: "user", Content: "c"},
+	}
+	if got := conversationDepth(msgs); got != 2 {
+		t.Fatalf("expected depth 2, got %d", got)
+	}
+	if got := conversationDepth(msgs[:2]); got != 0 {
+		t.Fatalf("expected depth 0, got %d", got)
+	}
+}
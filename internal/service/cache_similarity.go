@@ -0,0 +1,355 @@
+// Similarity cache tier — serves cached answers to rephrased prompts.
+// Maps to design.swift: Response Cache → Semantic Hash Generator (embedding similarity)
+//
+// The exact tier keys answers by a hash of the whole context, so "what is
+// photosynthesis?" and "What's photosynthesis" miss each other. For
+// single-turn requests (system prompt and one user prompt, no history) this
+// tier embeds the normalized prompt and looks up the nearest prompt cached
+// in the same scope: model, sampling parameters, system prompt, response
+// format, embedding model and, unless SEMANTIC_CACHE_SCOPE=global, user.
+// At or above SEMANTIC_CACHE_THRESHOLD cosine similarity, the answer cached
+// for that prompt is served. Scores of hits and near misses are logged so
+// the threshold can be tuned.
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prakyathpnayak/roognis/internal/config"
+	"github.com/prakyathpnayak/roognis/internal/db"
+	"github.com/prakyathpnayak/roognis/internal/models"
+)
+
+// nearMissMargin is how far below the threshold a best match is still
+// logged at info level, as a near miss.
+const nearMissMargin = 0.1
+
+// Embedder computes embeddings; *LLM implements it.
+type Embedder interface {
+	Embed(ctx context.Context, model string, input []string) ([][]float32, error)
+}
+
+// SimilarityIndex stores prompt embeddings and finds the nearest one.
+type SimilarityIndex interface {
+	// Nearest returns the live entry of scope most similar to vec and its
+	// cosine similarity, or nil if the scope has none.
+	Nearest(ctx context.Context, scope string, vec []float32) (*models.SemanticCacheEntry, float64, error)
+	// Add stores an entry until its ExpiresAt.
+	Add(ctx context.Context, e *models.SemanticCacheEntry) error
+	// Remove drops an entry whose answer has left the cache.
+	Remove(ctx context.Context, e *models.SemanticCacheEntry) error
+}
+
+// SimilarityCache is the similarity tier in front of the response cache.
+// A nil *SimilarityCache is valid and never hits.
+type SimilarityCache struct {
+	embedder  Embedder
+	index     SimilarityIndex
+	cache     *Cache
+	model     string // embedding model; "" = LLM_EMBED_MODEL
+	threshold float64
+	global    bool
+	ttl       time.Duration
+	// logPrompts logs the compared prompts, which may be another user's
+	// under a global scope.
+	logPrompts bool
+}
+
+// NewSimilarityCache creates the similarity tier from config, or returns
+// nil when SEMANTIC_CACHE_ENABLED is off.
+func NewSimilarityCache(cfg *config.Config, embedder Embedder, pool *db.Pool, cache *Cache) *SimilarityCache {
+	if !cfg.SemanticCacheEnabled {
+		return nil
+	}
+	var index SimilarityIndex
+	switch cfg.SemanticCacheBackend {
+	case "pgvector":
+		index = &pgvectorSimilarityIndex{pool: pool}
+	default:
+		index = NewMemorySimilarityIndex(cfg.SemanticCacheMaxEntries)
+	}
+	model := cfg.SemanticCacheEmbedModel
+	if model == "" {
+		model = cfg.LLMEmbedModel
+	}
+	slog.Info("cache.similarity_enabled",
+		"backend", cfg.SemanticCacheBackend,
+		"scope", cfg.SemanticCacheScope,
+		"threshold", cfg.SemanticCacheThreshold,
+		"embed_model", model,
+	)
+	return &SimilarityCache{
+		embedder:   embedder,
+		index:      index,
+		cache:      cache,
+		model:      model,
+		threshold:  cfg.SemanticCacheThreshold,
+		global:     cfg.SemanticCacheScope == "global",
+		ttl:        cfg.CacheTTL,
+		logPrompts: cfg.SemanticCacheLogPrompts,
+	}
+}
+
+// SimilarQuery is a single-turn request as seen by the similarity tier.
+type SimilarQuery struct {
+	scope  string
+	prompt string    // normalized prompt
+	vector []float32 // embedding, once computed
+}
+
+// similarityScope is everything besides the prompt that must match for two
+// answers to be interchangeable.
+type similarityScope struct {
+	UserID         string                 `json:"user_id,omitempty"`
+	Model          string                 `json:"model"`
+	EmbedModel     string                 `json:"embed_model"`
+	Temperature    float64                `json:"temperature"`
+	MaxTokens      int                    `json:"max_tokens"`
+	System         []models.LLMMessage    `json:"system"`
+	ResponseFormat *models.ResponseFormat `json:"response_format,omitempty"`
+}
+
+// Query returns the similarity query for messages as sent to model, or nil
+// if the tier is off or the request is not single-turn.
+func (s *SimilarityCache) Query(messages []models.LLMMessage, rf *models.ResponseFormat, model string, userID uuid.UUID, temperature float64, maxTokens int) *SimilarQuery {
+	if s == nil || !singleTurn(messages) {
+		return nil
+	}
+	prompt := s.normalize(messages[len(messages)-1].Content)
+	if prompt == "" {
+		return nil
+	}
+	scope := similarityScope{
+		Model:          model,
+		EmbedModel:     s.model,
+		Temperature:    temperature,
+		MaxTokens:      maxTokens,
+		System:         messages[:len(messages)-1],
+		ResponseFormat: rf,
+	}
+	if !s.global {
+		scope.UserID = userID.String()
+	}
+	data, err := json.Marshal(scope)
+	if err != nil {
+		return nil
+	}
+	return &SimilarQuery{scope: fmt.Sprintf("%x", sha256.Sum256(data)), prompt: prompt}
+}
+
+// Lookup returns the answer cached for the prompt most similar to q, if it
+// is similar enough.
+func (s *SimilarityCache) Lookup(ctx context.Context, q *SimilarQuery) *models.InferenceResponse {
+	if s == nil || q == nil {
+		return nil
+	}
+	vec, err := s.embed(ctx, q)
+	if err != nil {
+		slog.Warn("cache.similar_embed_error", "error", err)
+		return nil
+	}
+	e, score, err := s.index.Nearest(ctx, q.scope, vec)
+	if err != nil {
+		slog.Warn("cache.similar_lookup_error", "error", err)
+		return nil
+	}
+	if e == nil {
+		slog.Debug("cache.similar_miss", "scope", q.scope[:12], "entries", 0)
+		return nil
+	}
+
+	attrs := []any{"score", score, "threshold", s.threshold, "scope", q.scope[:12], "cache_key", e.CacheKey}
+	if s.logPrompts {
+		slog.Debug("cache.similar_pair", append(attrs, "prompt", q.prompt, "matched_prompt", e.Prompt)...)
+	}
+	if score < s.threshold {
+		if score >= s.threshold-nearMissMargin {
+			slog.Info("cache.similar_near_miss", attrs...)
+		} else {
+			slog.Debug("cache.similar_miss", attrs...)
+		}
+		return nil
+	}
+
+	var resp models.InferenceResponse
+	if found, _ := s.cache.GetJSON(ctx, e.CacheKey, &resp); !found {
+		slog.Debug("cache.similar_stale", attrs...)
+		if err := s.index.Remove(ctx, e); err != nil {
+			slog.Warn("cache.similar_remove_error", "error", err)
+		}
+		return nil
+	}
+	slog.Info("cache.similar_hit", attrs...)
+	return &resp
+}
+
+// Store indexes q for the answer cached under cacheKey for ttl, or the
+// cache TTL when ttl is 0.
+func (s *SimilarityCache) Store(ctx context.Context, q *SimilarQuery, cacheKey string, ttl time.Duration) {
+	if s == nil || q == nil {
+		return
+	}
+	if ttl <= 0 {
+		ttl = s.ttl
+	}
+	ctx = context.WithoutCancel(ctx)
+	vec, err := s.embed(ctx, q)
+	if err != nil {
+		slog.Warn("cache.similar_embed_error", "error", err)
+		return
+	}
+	err = s.index.Add(ctx, &models.SemanticCacheEntry{
+		ID:        uuid.New(),
+		Scope:     q.scope,
+		Prompt:    q.prompt,
+		CacheKey:  cacheKey,
+		Embedding: vec,
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	if err != nil {
+		slog.Warn("cache.similar_store_error", "error", err)
+	}
+}
+
+// embed computes the embedding of q once.
+func (s *SimilarityCache) embed(ctx context.Context, q *SimilarQuery) ([]float32, error) {
+	if q.vector != nil {
+		return q.vector, nil
+	}
+	vecs, err := s.embedder.Embed(ctx, s.model, []string{q.prompt})
+	if err != nil {
+		return nil, err
+	}
+	if len(vecs) != 1 || len(vecs[0]) == 0 {
+		return nil, fmt.Errorf("cache: embedding model returned %d vectors", len(vecs))
+	}
+	q.vector = vecs[0]
+	return q.vector, nil
+}
+
+// singleTurn reports whether messages are system messages followed by a
+// single user prompt.
+func singleTurn(messages []models.LLMMessage) bool {
+	n := len(messages)
+	if n == 0 || messages[n-1].Role != string(models.RoleUserMsg) {
+		return false
+	}
+	for _, m := range messages[:n-1] {
+		if m.Role != string(models.RoleSystemMsg) {
+			return false
+		}
+	}
+	return true
+}
+
+// normalize prepares a prompt for embedding with the cache's normalizer, or
+// just lowercases it and collapses its whitespace when there is none.
+func (s *SimilarityCache) normalize(prompt string) string {
+	if s.cache.normalizer != nil {
+		return s.cache.normalizer.Normalize(prompt)
+	}
+	return strings.Join(strings.Fields(strings.ToLower(prompt)), " ")
+}
+
+// cosine returns the cosine similarity of a and b, which must have the same
+// length.
+func cosine(a, b []float32) float64 {
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+// MemorySimilarityIndex is an in-process SimilarityIndex searched by brute
+// force. It holds at most max entries, evicting the oldest.
+type MemorySimilarityIndex struct {
+	mu      sync.Mutex
+	max     int
+	entries []models.SemanticCacheEntry // oldest first
+}
+
+// NewMemorySimilarityIndex creates an in-process index of at most max
+// entries.
+func NewMemorySimilarityIndex(max int) *MemorySimilarityIndex {
+	return &MemorySimilarityIndex{max: max}
+}
+
+// Nearest implements SimilarityIndex.
+func (ix *MemorySimilarityIndex) Nearest(ctx context.Context, scope string, vec []float32) (*models.SemanticCacheEntry, float64, error) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	now := time.Now()
+	var best *models.SemanticCacheEntry
+	bestScore := math.Inf(-1)
+	for i := range ix.entries {
+		e := &ix.entries[i]
+		if e.Scope != scope || len(e.Embedding) != len(vec) || !now.Before(e.ExpiresAt) {
+			continue
+		}
+		if score := cosine(vec, e.Embedding); score > bestScore {
+			best, bestScore = e, score
+		}
+	}
+	if best == nil {
+		return nil, 0, nil
+	}
+	found := *best
+	return &found, bestScore, nil
+}
+
+// Add implements SimilarityIndex.
+func (ix *MemorySimilarityIndex) Add(ctx context.Context, e *models.SemanticCacheEntry) error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	now := time.Now()
+	ix.entries = slices.DeleteFunc(ix.entries, func(x models.SemanticCacheEntry) bool { return !now.Before(x.ExpiresAt) })
+	if ix.max > 0 && len(ix.entries) >= ix.max {
+		ix.entries = slices.Delete(ix.entries, 0, len(ix.entries)-ix.max+1)
+	}
+	ix.entries = append(ix.entries, *e)
+	return nil
+}
+
+// Remove implements SimilarityIndex.
+func (ix *MemorySimilarityIndex) Remove(ctx context.Context, e *models.SemanticCacheEntry) error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.entries = slices.DeleteFunc(ix.entries, func(x models.SemanticCacheEntry) bool { return x.ID == e.ID })
+	return nil
+}
+
+// pgvectorSimilarityIndex keeps the index in Postgres, shared by all
+// instances.
+type pgvectorSimilarityIndex struct {
+	pool *db.Pool
+}
+
+func (ix *pgvectorSimilarityIndex) Nearest(ctx context.Context, scope string, vec []float32) (*models.SemanticCacheEntry, float64, error) {
+	return ix.pool.NearestSemanticCacheEntry(ctx, scope, vec)
+}
+
+func (ix *pgvectorSimilarityIndex) Add(ctx context.Context, e *models.SemanticCacheEntry) error {
+	return ix.pool.InsertSemanticCacheEntry(ctx, e)
+}
+
+func (ix *pgvectorSimilarityIndex) Remove(ctx context.Context, e *models.SemanticCacheEntry) error {
+	return ix.pool.DeleteSemanticCacheEntry(ctx, e.ID)
+}
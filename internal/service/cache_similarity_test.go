@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prakyathpnayak/roognis/internal/config"
+	"github.com/prakyathpnayak/roognis/internal/models"
+)
+
+// fakeEmbedder embeds each known prompt as a fixed vector.
+type fakeEmbedder map[string][]float32
+
+func (f fakeEmbedder) Embed(ctx context.Context, model string, input []string) ([][]float32, error) {
+	out := make([][]float32, len(input))
+	for i, s := range input {
+		out[i] = f[s]
+	}
+	return out, nil
+}
+
+func TestMemorySimilarityIndexNearest(t *testing.T) {
+	ix := NewMemorySimilarityIndex(10)
+	ctx := context.Background()
+	exp := time.Now().Add(time.Hour)
+	ix.Add(ctx, &models.SemanticCacheEntry{ID: uuid.New(), Scope: "a", CacheKey: "x", Embedding: []float32{1, 0}, ExpiresAt: exp})
+	ix.Add(ctx, &models.SemanticCacheEntry{ID: uuid.New(), Scope: "a", CacheKey: "y", Embedding: []float32{0, 1}, ExpiresAt: exp})
+	ix.Add(ctx, &models.SemanticCacheEntry{ID: uuid.New(), Scope: "b", CacheKey: "z", Embedding: []float32{1, 1}, ExpiresAt: exp})
+	ix.Add(ctx, &models.SemanticCacheEntry{ID: uuid.New(), Scope: "a", CacheKey: "old", Embedding: []float32{1, 1}, ExpiresAt: time.Now().Add(-time.Second)})
+
+	e, score, err := ix.Nearest(ctx, "a", []float32{2, 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e == nil || e.CacheKey != "x" {
+		t.Fatalf("expected entry x, got %+v", e)
+	}
+	if want := 2 / math.Sqrt(5); math.Abs(score-want) > 1e-6 {
+		t.Fatalf("expected score %f, got %f", want, score)
+	}
+
+	if e, _, _ := ix.Nearest(ctx, "c", []float32{1, 0}); e != nil {
+		t.Fatalf("expected no entry in an empty scope, got %+v", e)
+	}
+	if e, _, _ := ix.Nearest(ctx, "a", []float32{1, 0, 0}); e != nil {
+		t.Fatalf("expected vectors of another dimension to be skipped, got %+v", e)
+	}
+}
+
+func TestMemorySimilarityIndexEvictsOldest(t *testing.T) {
+	ix := NewMemorySimilarityIndex(2)
+	ctx := context.Background()
+	exp := time.Now().Add(time.Hour)
+	for _, key := range []string{"1", "2", "3"} {
+		ix.Add(ctx, &models.SemanticCacheEntry{ID: uuid.New(), Scope: "a", CacheKey: key, Embedding: []float32{1, 0}, ExpiresAt: exp})
+	}
+	if len(ix.entries) != 2 || ix.entries[0].CacheKey != "2" {
+		t.Fatalf("expected the oldest entry to be evicted, got %+v", ix.entries)
+	}
+}
+
+func TestSimilarityCacheQuery(t *testing.T) {
+	cfg := &config.Config{SemanticCacheEnabled: true, SemanticCacheThreshold: 0.9, SemanticCacheScope: "user"}
+	s := NewSimilarityCache(cfg, fakeEmbedder{}, nil, NewCache(nil, time.Hour, 0, nil, 0))
+	u1, u2 := uuid.New(), uuid.New()
+	single := []models.LLMMessage{{Role: "system", Content: "sys"}, {Role: "user", Content: "  What IS  photosynthesis? "}}
+
+	q := s.Query(single, nil, "m", u1, 0.7, 100)
+	if q == nil || q.prompt != "what is photosynthesis?" {
+		t.Fatalf("expected a normalized query, got %+v", q)
+	}
+	if s.Query(single, nil, "m", u2, 0.7, 100).scope == q.scope {
+		t.Fatal("expected user-scoped queries to differ between users")
+	}
+	if s.Query(single, nil, "m", u1, 0.2, 100).scope == q.scope {
+		t.Fatal("expected the temperature to change the scope")
+	}
+	multi := []models.LLMMessage{{Role: "user", Content: "hi"}, {Role: "assistant", Content: "hello"}, {Role: "user", Content: "again"}}
+	if s.Query(multi, nil, "m", u1, 0.7, 100) != nil {
+		t.Fatal("expected no query for a multi-turn request")
+	}
+
+	cfg.SemanticCacheScope = "global"
+	g := NewSimilarityCache(cfg, fakeEmbedder{}, nil, NewCache(nil, time.Hour, 0, nil, 0))
+	if g.Query(single, nil, "m", u1, 0.7, 100).scope != g.Query(single, nil, "m", u2, 0.7, 100).scope {
+		t.Fatal("expected global queries to share a scope")
+	}
+
+	var off *SimilarityCache
+	if off.Query(single, nil, "m", u1, 0.7, 100) != nil || off.Lookup(context.Background(), q) != nil {
+		t.Fatal("expected a nil similarity cache to do nothing")
+	}
+}
+
+func TestSimilarityCacheDropsStaleEntries(t *testing.T) {
+	cfg := &config.Config{SemanticCacheEnabled: true, SemanticCacheThreshold: 0.9, CacheTTL: time.Hour}
+	emb := fakeEmbedder{"what is photosynthesis?": {1, 0}, "what's photosynthesis": {0.99, 0.1}}
+	s := NewSimilarityCache(cfg, emb, nil, NewCache(nil, time.Hour, 0, nil, 0))
+	ctx := context.Background()
+
+	msgs := func(p string) []models.LLMMessage { return []models.LLMMessage{{Role: "user", Content: p}} }
+	u := uuid.New()
+	s.Store(ctx, s.Query(msgs("What is photosynthesis?"), nil, "m", u, 0.7, 100), "cache:inference:1", 0)
+
+	// Similar enough, but the answer is gone from the response cache.
+	if resp := s.Lookup(ctx, s.Query(msgs("What's photosynthesis"), nil, "m", u, 0.7, 100)); resp != nil {
+		t.Fatalf("expected a miss, got %+v", resp)
+	}
+	if n := len(s.index.(*MemorySimilarityIndex).entries); n != 0 {
+		t.Fatalf("expected the stale entry to be removed, %d left", n)
+	}
+}
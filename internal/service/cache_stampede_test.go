@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prakyathpnayak/roognis/internal/config"
+	"github.com/prakyathpnayak/roognis/internal/models"
+)
+
+func TestCacheServesStaleJSON(t *testing.T) {
+	ctx := context.Background()
+	c := NewCache(nil, 10*time.Millisecond, time.Hour, nil, 1<<20)
+	c.SetJSON(ctx, "k", models.InferenceResponse{Content: "answer"})
+
+	var resp models.InferenceResponse
+	if found, stale, _ := c.GetStaleJSON(ctx, "k", &resp); !found || stale || resp.Content != "answer" {
+		t.Fatalf("expected fresh hit, got found=%v stale=%v %+v", found, stale, resp)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if found, stale, _ := c.GetStaleJSON(ctx, "k", &resp); !found || !stale {
+		t.Fatalf("expected stale hit, got found=%v stale=%v", found, stale)
+	}
+	if found, _ := c.GetJSON(ctx, "k", &resp); found {
+		t.Fatal("expected GetJSON to miss a stale value")
+	}
+
+	// Values stored before they were wrapped are read as fresh.
+	c.Set(ctx, "legacy", `{"content":"old"}`)
+	if found, stale, _ := c.GetStaleJSON(ctx, "legacy", &resp); !found || stale || resp.Content != "old" {
+		t.Fatalf("expected fresh legacy hit, got found=%v stale=%v %+v", found, stale, resp)
+	}
+}
+
+func TestGenerateOnceCoalesces(t *testing.T) {
+	o := &Orchestrator{
+		cache: NewCache(nil, time.Hour, 0, nil, 0),
+		llm:   &LLM{cfg: &config.Config{CacheLockTimeout: time.Second}},
+	}
+	var calls atomic.Int32
+	release := make(chan struct{})
+	leader := &turn{}
+	fn := func(ctx context.Context) (*sharedAnswer, error) {
+		calls.Add(1)
+		<-release
+		return &sharedAnswer{turn: leader, resp: &models.InferenceResponse{Content: "answer"}}, nil
+	}
+
+	const n = 5
+	var wg sync.WaitGroup
+	results := make([]*sharedAnswer, n)
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _ = o.generateOnce(context.Background(), "k", false, fn)
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("expected one generation, got %d", got)
+	}
+	for i, g := range results {
+		if g == nil || g.turn != leader || g.resp.Content != "answer" {
+			t.Fatalf("result %d: expected the shared answer, got %+v", i, g)
+		}
+	}
+
+	// A caller that goes away stops waiting without failing the others.
+	block := make(chan struct{})
+	defer close(block)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := o.generateOnce(ctx, "k2", false, func(ctx context.Context) (*sharedAnswer, error) {
+		<-block
+		return nil, nil
+	})
+	if err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
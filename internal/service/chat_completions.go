@@ -0,0 +1,398 @@
+// Chat completions — the OpenAI-compatible facade over the pipeline.
+// Maps to design.swift: API Gateway → Prompt Orchestrator → LLM Inference Node → Token Streamer
+//
+// The caller sends the whole conversation, so there is no server-side
+// history, summary or persistence: the messages are checked, given the
+// default system prompt when they have none, fitted against the model's
+// context window and answered through the same allow-list, response cache
+// and fallback chain as /api/v1/inference/complete. Server-side tools are
+// not offered; tool calls and results in the caller's messages are passed
+// through as history.
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prakyathpnayak/roognis/internal/config"
+	"github.com/prakyathpnayak/roognis/internal/models"
+)
+
+// ErrInvalidChatRequest is returned for chat completion requests that are
+// malformed or use unsupported options.
+var ErrInvalidChatRequest = errors.New("invalid chat completion request")
+
+// chatCall is a chat completion request resolved against the pipeline.
+type chatCall struct {
+	id       string
+	created  int64
+	req      *models.InferenceRequest // model options in orchestrator terms
+	primary  config.ModelConfig
+	messages []models.LLMMessage
+	cacheKey string        // "" when not cached or the messages cannot be hashed
+	cacheTTL time.Duration // 0 = CACHE_TTL_SECONDS
+	similar  *SimilarQuery
+	tok      Tokenizer
+	start    time.Time
+}
+
+// ChatComplete answers an OpenAI chat completion request.
+func (o *Orchestrator) ChatComplete(ctx context.Context, req *models.ChatCompletionRequest, user *models.User) (*models.ChatCompletion, error) {
+	call, err := o.prepareChat(ctx, req, user)
+	if err != nil {
+		return nil, err
+	}
+
+	if cached := o.cachedChat(ctx, call); cached != nil {
+		usage := countUsage(call.tok, call.messages, cached.Content, nil)
+		o.logChat(call, user, cached.Model, usage, true, false)
+		return call.completion(cached.Model, cached.Content, "stop", usage), nil
+	}
+
+	resp, err := o.completeWithFallback(ctx, call.req, user.Role, call.primary, call.messages, false)
+	if err != nil {
+		return nil, fmt.Errorf("orchestrator: llm: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("orchestrator: llm returned no choices")
+	}
+
+	content := resp.Choices[0].Message.Content
+	usage := resp.Usage
+	if usage.TotalTokens == 0 {
+		usage = countUsage(call.tok, call.messages, content, nil)
+	}
+	reason := finishReason(resp)
+	if reason == "" {
+		reason = "stop"
+	}
+	o.cacheChat(ctx, call, resp.Model, content, usage, reason)
+	o.logChat(call, user, resp.Model, usage, false, false)
+	return call.completion(resp.Model, content, reason, usage), nil
+}
+
+// ChatCompleteStream answers an OpenAI chat completion request as a stream
+// of "chat.completion.chunk" objects: one announcing the assistant role,
+// content deltas, one with the finish reason and, when the request asks for
+// stream_options.include_usage, a last one with the usage and no choices.
+// An error returned before the first chunk means nothing was sent.
+func (o *Orchestrator) ChatCompleteStream(ctx context.Context, req *models.ChatCompletionRequest, user *models.User, onChunk func(models.ChatCompletion) error) error {
+	call, err := o.prepareChat(ctx, req, user)
+	if err != nil {
+		return err
+	}
+	includeUsage := req.StreamOptions != nil && req.StreamOptions.IncludeUsage
+
+	model := call.primary.Name
+	started := false
+	send := func(delta models.ChatCompletionMessage, reason *string) error {
+		if !started {
+			started = true
+			role := call.chunk(model, models.ChatCompletionMessage{Role: string(models.RoleAssistantMsg), Content: new(string)}, nil)
+			if err := onChunk(role); err != nil {
+				return err
+			}
+		}
+		return onChunk(call.chunk(model, delta, reason))
+	}
+	finish := func(reason string, usage models.LLMUsage) error {
+		if err := send(models.ChatCompletionMessage{}, &reason); err != nil {
+			return err
+		}
+		if !includeUsage {
+			return nil
+		}
+		last := call.chunk(model, models.ChatCompletionMessage{}, nil)
+		last.Choices = []models.ChatCompletionChoice{}
+		last.Usage = &usage
+		return onChunk(last)
+	}
+
+	if cached := o.cachedChat(ctx, call); cached != nil {
+		model = cached.Model
+		for _, delta := range replayChunks(cached.Content, replayChunkRunes) {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := send(models.ChatCompletionMessage{Content: &delta}, nil); err != nil {
+				return err
+			}
+		}
+		usage := countUsage(call.tok, call.messages, cached.Content, nil)
+		o.logChat(call, user, model, usage, true, true)
+		return finish("stop", usage)
+	}
+
+	round, err := o.streamWithFallback(ctx, call.req, user.Role, call.primary, call.messages, false, func(chunk models.LLMResponse) error {
+		if chunk.Model != "" {
+			model = chunk.Model
+		}
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			return nil
+		}
+		content := chunk.Choices[0].Delta.Content
+		return send(models.ChatCompletionMessage{Content: &content}, nil)
+	})
+	if err != nil {
+		return fmt.Errorf("orchestrator: stream: %w", err)
+	}
+
+	usage := round.usage
+	if usage.TotalTokens == 0 {
+		usage = countUsage(call.tok, call.messages, round.content, nil)
+	}
+	reason := round.finishReason
+	if reason == "" {
+		reason = "stop"
+	}
+	o.cacheChat(ctx, call, model, round.content, usage, reason)
+	o.logChat(call, user, model, usage, false, true)
+	return finish(reason, usage)
+}
+
+// prepareChat checks req, resolves its model and fits its messages into the
+// model's context window. Unlike conversations, the caller's history is
+// never trimmed: if it does not fit, the request fails.
+func (o *Orchestrator) prepareChat(ctx context.Context, req *models.ChatCompletionRequest, user *models.User) (*chatCall, error) {
+	if req.N != nil && *req.N != 1 {
+		return nil, fmt.Errorf("%w: only n=1 is supported", ErrInvalidChatRequest)
+	}
+	if err := checkChatParams(req); err != nil {
+		return nil, err
+	}
+	messages, err := ChatMessages(req.Messages)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckResponseFormat(req.ResponseFormat); err != nil {
+		return nil, err
+	}
+	// Structured output is validated on the complete answer.
+	if req.Stream && req.ResponseFormat != nil && req.ResponseFormat.Type != models.ResponseFormatText {
+		return nil, fmt.Errorf("%w: response_format is not supported with stream", ErrInvalidChatRequest)
+	}
+
+	inf := &models.InferenceRequest{
+		Model:          req.Model,
+		Stream:         req.Stream,
+		Temperature:    req.Temperature,
+		MaxTokens:      req.MaxTokens,
+		ResponseFormat: req.ResponseFormat,
+	}
+	if req.MaxCompletionTokens != nil {
+		inf.MaxTokens = req.MaxCompletionTokens
+	}
+
+	primary, err := o.models.Resolve(inf.Model, user.Role)
+	if err != nil {
+		return nil, err
+	}
+	temp, maxTok := o.requestParams(inf, primary)
+
+	messages = o.ctxInj.Inject(ctx, messages)
+	tok := o.tokens.For(primary)
+	fitted, budget, err := FitContext(tok, messages, primary.ContextWindow, maxTok)
+	if err != nil {
+		return nil, err
+	}
+	if budget.DroppedMessages > 0 {
+		return nil, fmt.Errorf("%w: %d of %d messages do not fit after reserving %d tokens for the answer",
+			ErrContextOverflow, budget.DroppedMessages, len(messages), maxTok)
+	}
+
+	var key string
+	decision := o.policy.Decide(CacheRequest{
+		Route:       config.CacheRouteChatCompletions,
+		Model:       primary.Name,
+		Temperature: temp,
+		Role:        user.Role,
+		Prompt:      fitted[len(fitted)-1].Content,
+		Depth:       conversationDepth(fitted),
+	})
+	if decision.Cache {
+		key, err = o.cache.ContextKey(decision.Normalizer, applyResponseFormat(fitted, inf.ResponseFormat), primary.Name, user.ID.String(), temp, maxTok)
+		if err != nil {
+			slog.Warn("orchestrator.cache_hash_error", "error", err, "chat", true)
+			key = ""
+		}
+	}
+
+	return &chatCall{
+		id:       "chatcmpl-" + strings.ReplaceAll(uuid.NewString(), "-", ""),
+		created:  time.Now().Unix(),
+		req:      inf,
+		primary:  primary,
+		messages: fitted,
+		cacheKey: key,
+		cacheTTL: decision.TTL,
+		similar:  o.similar.Query(fitted, inf.ResponseFormat, primary.Name, user.ID, temp, maxTok),
+		tok:      tok,
+		start:    time.Now(),
+	}, nil
+}
+
+// cachedChat looks up a cached answer for call. Answers are cached in the
+// shape Complete uses, so the conversation endpoints and the facade share
+// hits for the same context.
+func (o *Orchestrator) cachedChat(ctx context.Context, call *chatCall) *models.InferenceResponse {
+	if call.cacheKey == "" {
+		return nil
+	}
+	// A stale answer is regenerated, which refreshes it.
+	if resp, stale := o.cachedResponse(ctx, call.cacheKey, call.similar, call.req); !stale {
+		return resp
+	}
+	return nil
+}
+
+// cacheChat caches a complete answer. Truncated answers are not cached.
+func (o *Orchestrator) cacheChat(ctx context.Context, call *chatCall, model, content string, usage models.LLMUsage, reason string) {
+	if call.cacheKey == "" || reason != "stop" {
+		return
+	}
+	resp := &models.InferenceResponse{
+		Content:    content,
+		Model:      model,
+		TokenCount: positive(usage.TotalTokens),
+		LatencyMs:  float64(time.Since(call.start).Milliseconds()),
+	}
+	if err := o.cache.SetJSONTTL(context.WithoutCancel(ctx), call.cacheKey, resp, call.cacheTTL); err != nil {
+		slog.Warn("orchestrator.cache_set_error", "error", err, "chat", true)
+	} else {
+		o.similar.Store(ctx, call.similar, call.cacheKey, call.cacheTTL)
+	}
+}
+
+func (o *Orchestrator) logChat(call *chatCall, user *models.User, model string, usage models.LLMUsage, cached, stream bool) {
+	slog.Info("orchestrator.chat_completion",
+		"id", call.id,
+		"user_id", user.ID,
+		"model", model,
+		"cached", cached,
+		"stream", stream,
+		"messages", len(call.messages),
+		"prompt_tokens", usage.PromptTokens,
+		"completion_tokens", usage.CompletionTokens,
+		"latency_ms", time.Since(call.start).Milliseconds(),
+	)
+}
+
+// completion builds the non-streaming response.
+func (call *chatCall) completion(model, content, reason string, usage models.LLMUsage) *models.ChatCompletion {
+	return &models.ChatCompletion{
+		ID:      call.id,
+		Object:  "chat.completion",
+		Created: call.created,
+		Model:   model,
+		Choices: []models.ChatCompletionChoice{{
+			Message:      &models.ChatCompletionMessage{Role: string(models.RoleAssistantMsg), Content: &content},
+			FinishReason: &reason,
+		}},
+		Usage: &usage,
+	}
+}
+
+// chunk builds one streamed chunk.
+func (call *chatCall) chunk(model string, delta models.ChatCompletionMessage, reason *string) models.ChatCompletion {
+	return models.ChatCompletion{
+		ID:      call.id,
+		Object:  "chat.completion.chunk",
+		Created: call.created,
+		Model:   model,
+		Choices: []models.ChatCompletionChoice{{Delta: &delta, FinishReason: reason}},
+	}
+}
+
+// checkChatParams rejects requests that set OpenAI parameters the facade
+// cannot honour, e.g. tools, which would otherwise get a plain answer.
+// Parameters left null, false or empty are accepted.
+func checkChatParams(req *models.ChatCompletionRequest) error {
+	for _, p := range []struct {
+		name  string
+		value json.RawMessage
+	}{
+		{"tools", req.Tools},
+		{"tool_choice", req.ToolChoice},
+		{"parallel_tool_calls", req.ParallelToolCalls},
+		{"functions", req.Functions},
+		{"function_call", req.FunctionCall},
+		{"stop", req.Stop},
+		{"top_p", req.TopP},
+		{"presence_penalty", req.PresencePenalty},
+		{"frequency_penalty", req.FrequencyPenalty},
+		{"logit_bias", req.LogitBias},
+		{"logprobs", req.Logprobs},
+		{"top_logprobs", req.TopLogprobs},
+		{"seed", req.Seed},
+	} {
+		switch strings.TrimSpace(string(p.value)) {
+		case "", "null", "false", `""`, "[]", "{}":
+		default:
+			return fmt.Errorf("%w: %s is not supported", ErrInvalidChatRequest, p.name)
+		}
+	}
+	return nil
+}
+
+// ChatMessages converts the messages of a chat completion request. Content
+// parts are joined into plain text; "developer" messages are treated as
+// system messages.
+func ChatMessages(in []models.ChatMessage) ([]models.LLMMessage, error) {
+	if len(in) == 0 {
+		return nil, fmt.Errorf("%w: messages must not be empty", ErrInvalidChatRequest)
+	}
+	out := make([]models.LLMMessage, 0, len(in))
+	for i, m := range in {
+		content, err := chatContent(m.Content)
+		if err != nil {
+			return nil, fmt.Errorf("%w: messages[%d]: %v", ErrInvalidChatRequest, i, err)
+		}
+		msg := models.LLMMessage{Role: m.Role, Content: content, Name: m.Name}
+		switch models.MessageRole(m.Role) {
+		case models.RoleSystemMsg, models.RoleUserMsg:
+		case "developer":
+			msg.Role = string(models.RoleSystemMsg)
+		case models.RoleAssistantMsg:
+			msg.ToolCalls = m.ToolCalls
+		case models.RoleToolMsg:
+			if m.ToolCallID == "" {
+				return nil, fmt.Errorf("%w: messages[%d]: tool_call_id is required", ErrInvalidChatRequest, i)
+			}
+			msg.ToolCallID = m.ToolCallID
+		default:
+			return nil, fmt.Errorf("%w: messages[%d]: unsupported role %q", ErrInvalidChatRequest, i, m.Role)
+		}
+		out = append(out, msg)
+	}
+	return out, nil
+}
+
+// chatContent flattens a message content, a string or an array of content
+// parts, to text. Missing and null content are empty.
+func chatContent(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s, nil
+	}
+	var parts []models.ChatContentPart
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return "", errors.New("content must be a string or an array of content parts")
+	}
+	texts := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p.Type != "text" {
+			return "", fmt.Errorf("content part type %q is not supported", p.Type)
+		}
+		texts = append(texts, p.Text)
+	}
+	return strings.Join(texts, "\n"), nil
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/prakyathpnayak/roognis/internal/models"
+)
+
+func TestChatMessages(t *testing.T) {
+	var in []models.ChatMessage
+	err := json.Unmarshal([]byte(`[
+		{"role": "developer", "content": "be brief"},
+		{"role": "user", "content": [{"type": "text", "text": "hello"}, {"type": "text", "text": "world"}]},
+		{"role": "assistant", "content": null, "tool_calls": [{"id": "call_1", "type": "function", "function": {"name": "calc", "arguments": "{}"}}]},
+		{"role": "tool", "tool_call_id": "call_1", "content": "2"}
+	]`), &in)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := ChatMessages(in)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 4 {
+		t.Fatalf("expected 4 messages, got %d", len(got))
+	}
+	if got[0].Role != "system" || got[0].Content != "be brief" {
+		t.Fatalf("developer message should become a system message, got %+v", got[0])
+	}
+	if got[1].Content != "hello\nworld" {
+		t.Fatalf("expected text parts to be joined, got %q", got[1].Content)
+	}
+	if got[2].Content != "" || len(got[2].ToolCalls) != 1 {
+		t.Fatalf("expected the assistant tool call to pass through, got %+v", got[2])
+	}
+	if got[3].ToolCallID != "call_1" || got[3].Content != "2" {
+		t.Fatalf("unexpected tool message %+v", got[3])
+	}
+}
+
+func TestChatMessagesRejects(t *testing.T) {
+	tests := map[string]string{
+		"empty":           `[]`,
+		"unknown role":    `[{"role": "robot", "content": "hi"}]`,
+		"image part":      `[{"role": "user", "content": [{"type": "image_url", "image_url": {"url": "x"}}]}]`,
+		"object content":  `[{"role": "user", "content": {"text": "hi"}}]`,
+		"tool without id": `[{"role": "tool", "content": "2"}]`,
+	}
+	for name, body := range tests {
+		var in []models.ChatMessage
+		if err := json.Unmarshal([]byte(body), &in); err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		if _, err := ChatMessages(in); !errors.Is(err, ErrInvalidChatRequest) {
+			t.Fatalf("%s: expected ErrInvalidChatRequest, got %v", name, err)
+		}
+	}
+}
+
+func TestCheckChatParams(t *testing.T) {
+	accepted := []string{
+		`{"model": "m"}`,
+		`{"model": "m", "tools": null, "tool_choice": null, "stop": [], "logprobs": false, "logit_bias": {}}`,
+	}
+	for _, body := range accepted {
+		var req models.ChatCompletionRequest
+		if err := json.Unmarshal([]byte(body), &req); err != nil {
+			t.Fatalf("%s: unexpected error: %v", body, err)
+		}
+		if err := checkChatParams(&req); err != nil {
+			t.Fatalf("%s: unexpected error: %v", body, err)
+		}
+	}
+
+	rejected := []string{
+		`{"tools": [{"type": "function", "function": {"name": "calc"}}]}`,
+		`{"tool_choice": "auto"}`,
+		`{"functions": [{"name": "calc"}]}`,
+		`{"stop": ["\n"]}`,
+		`{"stop": "END"}`,
+		`{"top_p": 0.9}`,
+		`{"logprobs": true}`,
+		`{"seed": 42}`,
+	}
+	for _, body := range rejected {
+		var req models.ChatCompletionRequest
+		if err := json.Unmarshal([]byte(body), &req); err != nil {
+			t.Fatalf("%s: unexpected error: %v", body, err)
+		}
+		if err := checkChatParams(&req); !errors.Is(err, ErrInvalidChatRequest) {
+			t.Fatalf("%s: expected ErrInvalidChatRequest, got %v", body, err)
+		}
+	}
+}
+
+func TestChatCompletionWireFormat(t *testing.T) {
+	call := &chatCall{id: "chatcmpl-1", created: 1700000000}
+
+	content := "hi"
+	chunk := call.chunk("m", models.ChatCompletionMessage{Content: &content}, nil)
+	b, _ := json.Marshal(chunk)
+	want := `{"id":"chatcmpl-1","object":"chat.completion.chunk","created":1700000000,"model":"m","choices":[{"index":0,"delta":{"content":"hi"},"logprobs":null,"finish_reason":null}]}`
+	if string(b) != want {
+		t.Fatalf("unexpected chunk:\n%s\nwant:\n%s", b, want)
+	}
+
+	resp := call.completion("m", "hi", "stop", models.LLMUsage{PromptTokens: 3, CompletionTokens: 1, TotalTokens: 4})
+	b, _ = json.Marshal(resp)
+	want = `{"id":"chatcmpl-1","object":"chat.completion","created":1700000000,"model":"m","choices":[{"index":0,"message":{"role":"assistant","content":"hi"},"logprobs":null,"finish_reason":"stop"}],"usage":{"prompt_tokens":3,"completion_tokens":1,"total_tokens":4}}`
+	if string(b) != want {
+		t.Fatalf("unexpected completion:\n%s\nwant:\n%s", b, want)
+	}
+}
@@ -0,0 +1,164 @@
+// Conversation management — list cursors, history pages and the purge of
+// deleted conversations.
+// Maps to design.swift: Interaction Logger (conversation lifecycle)
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prakyathpnayak/roognis/internal/config"
+	"github.com/prakyathpnayak/roognis/internal/db"
+	"github.com/prakyathpnayak/roognis/internal/models"
+)
+
+const (
+	DefaultConversationPageSize = 50
+	MaxConversationPageSize     = 100
+
+	DefaultMessagePageSize = 100
+	MaxMessagePageSize     = 500
+)
+
+var (
+	ErrInvalidCursor  = errors.New("invalid cursor")
+	ErrRestoreExpired = errors.New("conversation can no longer be restored")
+)
+
+// EncodeConversationCursor returns the opaque cursor for the page after c.
+func EncodeConversationCursor(c models.ConversationCursor) string {
+	return encodeCursor(c)
+}
+
+// DecodeConversationCursor parses a cursor from EncodeConversationCursor.
+func DecodeConversationCursor(s string) (*models.ConversationCursor, error) {
+	var c models.ConversationCursor
+	if err := decodeCursor(s, &c); err != nil || c.UpdatedAt.IsZero() {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// EncodeMessageCursor returns the opaque cursor for the position of c.
+func EncodeMessageCursor(c models.MessageCursor) string {
+	return encodeCursor(c)
+}
+
+// DecodeMessageCursor parses a cursor from EncodeMessageCursor.
+func DecodeMessageCursor(s string) (*models.MessageCursor, error) {
+	var c models.MessageCursor
+	if err := decodeCursor(s, &c); err != nil || c.CreatedAt.IsZero() {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// Cursors are base64url-encoded JSON, opaque to clients.
+func encodeCursor(v any) string {
+	b, _ := json.Marshal(v)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// MessagePage is one page of a conversation's history. PrevCursor and
+// NextCursor are set when older or newer messages exist outside the page.
+type MessagePage struct {
+	Messages    []models.Message
+	PrevCursor  string
+	NextCursor  string
+	ETag        string
+	NotModified bool // ETag matched If-None-Match; Messages is not loaded
+}
+
+// messagesETag identifies a page of history: the active branch, given by
+// its leaf since stored messages never change, plus the parameters
+// selecting the page.
+func messagesETag(convID uuid.UUID, leaf *uuid.UUID, params models.MessageListParams) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s|%d", convID, params.Limit)
+	if leaf != nil {
+		fmt.Fprintf(h, "|l%s", leaf)
+	}
+	if params.Before != nil {
+		fmt.Fprintf(h, "|b%d/%s", params.Before.CreatedAt.UnixNano(), params.Before.ID)
+	}
+	if params.After != nil {
+		fmt.Fprintf(h, "|a%d/%s", params.After.CreatedAt.UnixNano(), params.After.ID)
+	}
+	if params.Since != nil {
+		fmt.Fprintf(h, "|s%d", params.Since.UnixNano())
+	}
+	return `W/"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header value matches etag,
+// using the weak comparison RFC 9110 prescribes for If-None-Match.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// ConversationJanitor permanently removes conversations whose restore
+// window has passed.
+type ConversationJanitor struct {
+	pool *db.Pool
+	cfg  *config.Config
+}
+
+// NewConversationJanitor creates the janitor. Call Start to run it.
+func NewConversationJanitor(pool *db.Pool, cfg *config.Config) *ConversationJanitor {
+	return &ConversationJanitor{pool: pool, cfg: cfg}
+}
+
+// Start purges expired conversations every CONVERSATION_PURGE_INTERVAL_MINUTES
+// until ctx is cancelled.
+func (j *ConversationJanitor) Start(ctx context.Context) {
+	if j.cfg.ConversationPurgeInterval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(j.cfg.ConversationPurgeInterval)
+		defer ticker.Stop()
+		for {
+			j.Purge(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Purge deletes conversations soft-deleted before the restore window.
+func (j *ConversationJanitor) Purge(ctx context.Context) {
+	n, err := j.pool.PurgeDeletedConversations(ctx, time.Now().Add(-j.cfg.ConversationRestoreWindow))
+	if err != nil {
+		slog.Warn("conversations.purge_error", "error", err)
+		return
+	}
+	if n > 0 {
+		slog.Info("conversations.purged", "count", n)
+	}
+}
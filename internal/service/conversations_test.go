@@ -0,0 +1,66 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prakyathpnayak/roognis/internal/models"
+)
+
+func TestConversationCursorRoundTrip(t *testing.T) {
+	want := models.ConversationCursor{
+		Pinned:    true,
+		UpdatedAt: time.Date(2026, 3, 4, 5, 6, 7, 890123000, time.UTC),
+		ID:        uuid.New(),
+	}
+
+	got, err := DecodeConversationCursor(EncodeConversationCursor(want))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Pinned != want.Pinned || !got.UpdatedAt.Equal(want.UpdatedAt) || got.ID != want.ID {
+		t.Fatalf("cursor changed in round trip: got %+v, want %+v", got, want)
+	}
+}
+
+func TestDecodeConversationCursorRejectsGarbage(t *testing.T) {
+	for _, s := range []string{"not base64!", "bm90IGpzb24", "e30"} { // "not json", "{}"
+		if _, err := DecodeConversationCursor(s); !errors.Is(err, ErrInvalidCursor) {
+			t.Fatalf("expected ErrInvalidCursor for %q, got %v", s, err)
+		}
+	}
+}
+
+func TestMessagesETag(t *testing.T) {
+	convID := uuid.New()
+	leaf := uuid.New()
+	params := models.MessageListParams{Limit: 100}
+
+	tag := messagesETag(convID, &leaf, params)
+	if tag != messagesETag(convID, &leaf, params) {
+		t.Fatal("expected a stable ETag")
+	}
+	other := uuid.New()
+	if tag == messagesETag(convID, &other, params) || tag == messagesETag(convID, nil, params) {
+		t.Fatal("expected the ETag to change with the active branch")
+	}
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	if tag == messagesETag(convID, &leaf, models.MessageListParams{Limit: 100, Since: &since}) {
+		t.Fatal("expected the ETag to change with the page")
+	}
+
+	for header, want := range map[string]bool{
+		tag:                           true,
+		strings.TrimPrefix(tag, "W/"): true,
+		`"other", ` + tag:             true,
+		"*":                           true,
+		`W/"other"`:                   false,
+	} {
+		if got := etagMatches(header, tag); got != want {
+			t.Fatalf("etagMatches(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
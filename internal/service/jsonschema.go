@@ -0,0 +1,245 @@
+// Minimal JSON Schema validator for structured model output.
+//
+// Covers the subset models are asked to follow in practice: type, enum,
+// const, properties/required/additionalProperties, items, min/max bounds on
+// strings, numbers and arrays, pattern, and allOf/anyOf/oneOf. Unknown
+// keywords are ignored.
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"unicode/utf8"
+)
+
+// ValidateJSONSchema checks value (as produced by json.Unmarshal into any)
+// against schema and returns one message per violation, each prefixed with
+// the JSON path of the offending value.
+func ValidateJSONSchema(schema json.RawMessage, value any) ([]string, error) {
+	var s any
+	if err := json.Unmarshal(schema, &s); err != nil {
+		return nil, fmt.Errorf("jsonschema: invalid schema: %w", err)
+	}
+
+	var errs []string
+	validateSchema(s, value, "$", &errs)
+	return errs, nil
+}
+
+func validateSchema(schema, v any, path string, errs *[]string) {
+	s, ok := schema.(map[string]any)
+	if !ok {
+		// true / false schemas
+		if b, isBool := schema.(bool); isBool && !b {
+			*errs = append(*errs, path+": no value is allowed here")
+		}
+		return
+	}
+
+	if t, ok := s["type"]; ok && !matchesType(t, v) {
+		*errs = append(*errs, fmt.Sprintf("%s: expected %s, got %s", path, typeString(t), jsonType(v)))
+		return
+	}
+
+	if enum, ok := s["enum"].([]any); ok {
+		found := false
+		for _, e := range enum {
+			if reflect.DeepEqual(e, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			*errs = append(*errs, fmt.Sprintf("%s: must be one of %s", path, compactJSON(enum)))
+		}
+	}
+	if c, ok := s["const"]; ok && !reflect.DeepEqual(c, v) {
+		*errs = append(*errs, fmt.Sprintf("%s: must equal %s", path, compactJSON(c)))
+	}
+
+	switch val := v.(type) {
+	case map[string]any:
+		validateObject(s, val, path, errs)
+	case []any:
+		validateArray(s, val, path, errs)
+	case string:
+		validateString(s, val, path, errs)
+	case float64:
+		validateNumber(s, val, path, errs)
+	}
+
+	if all, ok := s["allOf"].([]any); ok {
+		for _, sub := range all {
+			validateSchema(sub, v, path, errs)
+		}
+	}
+	if anyOf, ok := s["anyOf"].([]any); ok && countMatches(anyOf, v, path) == 0 {
+		*errs = append(*errs, path+": does not match any of the allowed schemas (anyOf)")
+	}
+	if oneOf, ok := s["oneOf"].([]any); ok {
+		if n := countMatches(oneOf, v, path); n != 1 {
+			*errs = append(*errs, fmt.Sprintf("%s: must match exactly one schema (oneOf), matched %d", path, n))
+		}
+	}
+}
+
+func validateObject(s map[string]any, obj map[string]any, path string, errs *[]string) {
+	props, _ := s["properties"].(map[string]any)
+
+	if req, ok := s["required"].([]any); ok {
+		for _, r := range req {
+			name, _ := r.(string)
+			if _, present := obj[name]; !present {
+				*errs = append(*errs, fmt.Sprintf("%s: missing required property %q", path, name))
+			}
+		}
+	}
+
+	// Iterate in key order so error messages are deterministic.
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		child := path + "." + k
+		if sub, ok := props[k]; ok {
+			validateSchema(sub, obj[k], child, errs)
+			continue
+		}
+		switch ap := s["additionalProperties"].(type) {
+		case bool:
+			if !ap {
+				*errs = append(*errs, fmt.Sprintf("%s: unexpected property %q", path, k))
+			}
+		case map[string]any:
+			validateSchema(ap, obj[k], child, errs)
+		}
+	}
+}
+
+func validateArray(s map[string]any, arr []any, path string, errs *[]string) {
+	if n, ok := number(s["minItems"]); ok && float64(len(arr)) < n {
+		*errs = append(*errs, fmt.Sprintf("%s: must have at least %v items, got %d", path, n, len(arr)))
+	}
+	if n, ok := number(s["maxItems"]); ok && float64(len(arr)) > n {
+		*errs = append(*errs, fmt.Sprintf("%s: must have at most %v items, got %d", path, n, len(arr)))
+	}
+	if items, ok := s["items"]; ok {
+		for i, item := range arr {
+			validateSchema(items, item, fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	}
+}
+
+func validateString(s map[string]any, str string, path string, errs *[]string) {
+	length := float64(utf8.RuneCountInString(str))
+	if n, ok := number(s["minLength"]); ok && length < n {
+		*errs = append(*errs, fmt.Sprintf("%s: must be at least %v characters", path, n))
+	}
+	if n, ok := number(s["maxLength"]); ok && length > n {
+		*errs = append(*errs, fmt.Sprintf("%s: must be at most %v characters", path, n))
+	}
+	if p, ok := s["pattern"].(string); ok {
+		re, err := regexp.Compile(p)
+		if err == nil && !re.MatchString(str) {
+			*errs = append(*errs, fmt.Sprintf("%s: must match pattern %q", path, p))
+		}
+	}
+}
+
+func validateNumber(s map[string]any, n float64, path string, errs *[]string) {
+	if m, ok := number(s["minimum"]); ok && n < m {
+		*errs = append(*errs, fmt.Sprintf("%s: must be >= %v", path, m))
+	}
+	if m, ok := number(s["maximum"]); ok && n > m {
+		*errs = append(*errs, fmt.Sprintf("%s: must be <= %v", path, m))
+	}
+	if m, ok := number(s["exclusiveMinimum"]); ok && n <= m {
+		*errs = append(*errs, fmt.Sprintf("%s: must be > %v", path, m))
+	}
+	if m, ok := number(s["exclusiveMaximum"]); ok && n >= m {
+		*errs = append(*errs, fmt.Sprintf("%s: must be < %v", path, m))
+	}
+}
+
+func countMatches(schemas []any, v any, path string) int {
+	n := 0
+	for _, sub := range schemas {
+		var subErrs []string
+		validateSchema(sub, v, path, &subErrs)
+		if len(subErrs) == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// matchesType reports whether v has the JSON type t ("string" or a list).
+func matchesType(t any, v any) bool {
+	switch tt := t.(type) {
+	case string:
+		return typeMatches(tt, v)
+	case []any:
+		for _, x := range tt {
+			if name, ok := x.(string); ok && typeMatches(name, v) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func typeMatches(name string, v any) bool {
+	switch name {
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == math.Trunc(f)
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	default:
+		return jsonType(v) == name
+	}
+}
+
+func jsonType(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func typeString(t any) string {
+	if s, ok := t.(string); ok {
+		return s
+	}
+	return compactJSON(t)
+}
+
+func number(v any) (float64, bool) {
+	f, ok := v.(float64)
+	return f, ok
+}
+
+func compactJSON(v any) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestValidateJSONSchema(t *testing.T) {
+	schema := json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"question": {"type": "string", "minLength": 5},
+			"difficulty": {"enum": ["easy", "medium", "hard"]},
+			"points": {"type": "integer", "minimum": 1, "maximum": 10},
+			"choices": {"type": "array", "minItems": 2, "items": {"type": "string"}},
+			"tag": {"anyOf": [{"type": "string"}, {"type": "null"}]}
+		},
+		"required": ["question", "choices"],
+		"additionalProperties": false
+	}`)
+
+	tests := []struct {
+		name string
+		doc  string
+		want []string // substrings expected in the violations; nil = valid
+	}{
+		{"valid", `{"question":"What is 2+2?","difficulty":"easy","points":3,"choices":["3","4"],"tag":null}`, nil},
+		{"missing required", `{"question":"What is 2+2?"}`, []string{`missing required property "choices"`}},
+		{"wrong type", `{"question":42,"choices":["a","b"]}`, []string{"$.question: expected string, got number"}},
+		{"enum", `{"question":"What is 2+2?","choices":["a","b"],"difficulty":"extreme"}`, []string{"$.difficulty: must be one of"}},
+		{"integer bounds", `{"question":"What is 2+2?","choices":["a","b"],"points":11.5}`, []string{"$.points: expected integer"}},
+		{"array items", `{"question":"What is 2+2?","choices":["a",2]}`, []string{"$.choices[1]: expected string"}},
+		{"min items", `{"question":"What is 2+2?","choices":["a"]}`, []string{"at least 2 items"}},
+		{"additional property", `{"question":"What is 2+2?","choices":["a","b"],"extra":true}`, []string{`unexpected property "extra"`}},
+		{"anyOf", `{"question":"What is 2+2?","choices":["a","b"],"tag":1}`, []string{"$.tag: does not match any"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var doc any
+			if err := json.Unmarshal([]byte(tt.doc), &doc); err != nil {
+				t.Fatalf("bad test document: %v", err)
+			}
+			errs, err := ValidateJSONSchema(schema, doc)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.want == nil {
+				if len(errs) != 0 {
+					t.Fatalf("expected no violations, got %v", errs)
+				}
+				return
+			}
+			joined := strings.Join(errs, "\n")
+			for _, w := range tt.want {
+				if !strings.Contains(joined, w) {
+					t.Fatalf("expected violation containing %q, got %v", w, errs)
+				}
+			}
+		})
+	}
+}
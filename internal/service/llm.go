@@ -1,20 +1,15 @@
-// LLM inference service — HTTP client to OpenAI-compatible API.
+// LLM inference service — routes chat completions to pluggable providers.
 // Maps to design.swift: Text LLM Inference Node
 //
 // Uses standard net/http for streaming, no external LLM SDK dependency.
-// Compatible with OpenAI, Azure OpenAI, Ollama, LiteLLM proxy, vLLM, etc.
+// Providers (OpenAI-compatible, native Ollama, Anthropic Messages) are
+// selected per model name through a ProviderRegistry.
 package service
 
 import (
-	"bufio"
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
 	"log/slog"
-	"net/http"
-	"strings"
 	"time"
 
 	"github.com/prakyathpnayak/roognis/internal/config"
@@ -23,76 +18,96 @@ import (
 
 // LLM is the inference client.
 type LLM struct {
-	cfg    *config.Config
-	client *http.Client
+	cfg       *config.Config
+	providers *ProviderRegistry
 }
 
-// NewLLM creates a new LLM inference service.
+// NewLLM creates a new LLM inference service with the providers enabled in cfg.
 func NewLLM(cfg *config.Config) *LLM {
 	return &LLM{
-		cfg: cfg,
-		client: &http.Client{
-			Timeout: time.Duration(cfg.LLMTimeoutSeconds) * time.Second,
-		},
+		cfg:       cfg,
+		providers: newProviderRegistryFromConfig(cfg),
 	}
 }
 
-// Complete sends a non-streaming chat completion request.
-func (l *LLM) Complete(ctx context.Context, messages []models.LLMMessage, opts ...RequestOption) (*models.LLMResponse, error) {
-	o := l.defaultOpts()
-	for _, fn := range opts {
-		fn(&o)
+// newProviderRegistryFromConfig registers the OpenAI-compatible provider
+// unconditionally, Ollama when OLLAMA_API_BASE is set and Anthropic when
+// ANTHROPIC_API_KEY is set, then applies LLM_MODEL_PROVIDERS routes. All
+// providers share one pooled transport; each is wrapped with retries and its
+// own circuit breaker.
+func newProviderRegistryFromConfig(cfg *config.Config) *ProviderRegistry {
+	clients := NewHTTPClients(NewLLMTransport(cfg), time.Duration(cfg.LLMTimeoutSeconds)*time.Second)
+	policy := RetryPolicy{
+		MaxRetries: cfg.LLMMaxRetries,
+		BaseDelay:  cfg.LLMRetryBaseDelay,
+		MaxDelay:   cfg.LLMRetryMaxDelay,
 	}
 
-	req := models.LLMRequest{
-		Model:       o.Model,
-		Messages:    messages,
-		Temperature: o.Temperature,
-		MaxTokens:   o.MaxTokens,
-		Stream:      false,
+	reg := NewProviderRegistry(cfg.LLMProvider)
+	register := func(p LLMProvider) {
+		breaker := NewCircuitBreaker(p.Name(), cfg.LLMBreakerThreshold, cfg.LLMBreakerCooldown)
+		reg.Register(withResilience(p, policy, breaker))
 	}
-
-	body, err := json.Marshal(req)
-	if err != nil {
-		return nil, fmt.Errorf("llm: marshal: %w", err)
+	register(NewOpenAIProvider("openai", cfg.LLMAPIBase, cfg.LLMAPIKey, clients))
+	if cfg.OllamaAPIBase != "" {
+		register(NewOllamaProvider(cfg.OllamaAPIBase, clients))
+	}
+	if cfg.AnthropicAPIKey != "" {
+		register(NewAnthropicProvider(cfg.AnthropicAPIBase, cfg.AnthropicAPIKey, cfg.AnthropicVersion, clients))
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost,
-		l.cfg.LLMAPIBase+"/chat/completions", bytes.NewReader(body))
-	if err != nil {
-		return nil, fmt.Errorf("llm: create request: %w", err)
+	for _, rt := range cfg.LLMProviderRoutes {
+		if err := reg.Route(rt.Pattern, rt.Provider); err != nil {
+			slog.Warn("llm.route_invalid", "pattern", rt.Pattern, "provider", rt.Provider, "error", err)
+		}
 	}
+	return reg
+}
+
+// Providers exposes the provider registry.
+func (l *LLM) Providers() *ProviderRegistry {
+	return l.providers
+}
 
-	httpReq.Header.Set("Content-Type", "application/json")
-	if l.cfg.LLMAPIKey != "" {
-		httpReq.Header.Set("Authorization", "Bearer "+l.cfg.LLMAPIKey)
+// BreakerStates returns the circuit breaker state of every provider.
+func (l *LLM) BreakerStates() map[string]string {
+	out := make(map[string]string)
+	for _, p := range l.providers.Providers() {
+		if rp, ok := p.(interface{ BreakerState() string }); ok {
+			out[p.Name()] = rp.BreakerState()
+		}
 	}
+	return out
+}
 
-	start := time.Now()
-	resp, err := l.client.Do(httpReq)
+// Complete sends a non-streaming chat completion request.
+func (l *LLM) Complete(ctx context.Context, messages []models.LLMMessage, opts ...RequestOption) (*models.LLMResponse, error) {
+	req := l.buildRequest(messages, false, opts)
+
+	provider, err := l.providers.Resolve(req.Model)
 	if err != nil {
-		return nil, fmt.Errorf("llm: request: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		// M3 fix: Limit error response body to 64 KB
-		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
-		return nil, fmt.Errorf("llm: status %d: %s", resp.StatusCode, string(respBody))
+	start := time.Now()
+	var llmResp *models.LLMResponse
+	if req.ResponseFormat != nil {
+		llmResp, err = l.completeStructured(ctx, provider, req)
+	} else {
+		llmResp, err = provider.Complete(ctx, req)
 	}
-
-	var llmResp models.LLMResponse
-	if err := json.NewDecoder(resp.Body).Decode(&llmResp); err != nil {
-		return nil, fmt.Errorf("llm: decode: %w", err)
+	if err != nil {
+		return nil, err
 	}
 
 	slog.Info("llm.complete",
+		"provider", provider.Name(),
 		"model", llmResp.Model,
 		"tokens", llmResp.Usage.TotalTokens,
 		"latency_ms", time.Since(start).Milliseconds(),
 	)
 
-	return &llmResp, nil
+	return llmResp, nil
 }
 
 // StreamCallback is called for each chunk during streaming.
@@ -100,91 +115,79 @@ type StreamCallback func(chunk models.LLMResponse) error
 
 // CompleteStream sends a streaming chat completion request, calling cb for each chunk.
 func (l *LLM) CompleteStream(ctx context.Context, messages []models.LLMMessage, cb StreamCallback, opts ...RequestOption) error {
-	o := l.defaultOpts()
-	for _, fn := range opts {
-		fn(&o)
-	}
+	req := l.buildRequest(messages, true, opts)
 
-	req := models.LLMRequest{
-		Model:       o.Model,
-		Messages:    messages,
-		Temperature: o.Temperature,
-		MaxTokens:   o.MaxTokens,
-		Stream:      true,
-	}
-
-	body, err := json.Marshal(req)
+	provider, err := l.providers.Resolve(req.Model)
 	if err != nil {
-		return fmt.Errorf("llm: marshal: %w", err)
+		return err
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost,
-		l.cfg.LLMAPIBase+"/chat/completions", bytes.NewReader(body))
-	if err != nil {
-		return fmt.Errorf("llm: create request: %w", err)
-	}
+	return provider.CompleteStream(ctx, req, cb)
+}
 
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Accept", "text/event-stream")
-	if l.cfg.LLMAPIKey != "" {
-		httpReq.Header.Set("Authorization", "Bearer "+l.cfg.LLMAPIKey)
+// Embed returns embedding vectors for input using model (LLM_EMBED_MODEL when empty).
+func (l *LLM) Embed(ctx context.Context, model string, input []string) ([][]float32, error) {
+	if model == "" {
+		model = l.cfg.LLMEmbedModel
 	}
 
-	// Use a client without global timeout for streaming (context handles cancellation).
-	// H7 fix: Set transport-level timeouts to avoid leaked connections.
-	streamClient := &http.Client{
-		Transport: &http.Transport{
-			ResponseHeaderTimeout: 30 * time.Second,
-			IdleConnTimeout:       90 * time.Second,
-		},
-	}
-	resp, err := streamClient.Do(httpReq)
+	provider, err := l.providers.Resolve(model)
 	if err != nil {
-		return fmt.Errorf("llm: stream request: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
-		return fmt.Errorf("llm: stream status %d: %s", resp.StatusCode, string(respBody))
+	vectors, err := provider.Embed(ctx, model, input)
+	if err != nil {
+		return nil, err
 	}
+	if len(vectors) != len(input) {
+		return nil, fmt.Errorf("llm: %s: expected %d embeddings, got %d", provider.Name(), len(input), len(vectors))
+	}
+	return vectors, nil
+}
 
-	// M8 fix: Increase scanner buffer for long SSE lines
-	scanner := bufio.NewScanner(resp.Body)
-	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024) // Up to 1 MB per line
-	for scanner.Scan() {
-		line := scanner.Text()
-
-		if !strings.HasPrefix(line, "data: ") {
-			continue
-		}
-
-		data := strings.TrimPrefix(line, "data: ")
-		if data == "[DONE]" {
-			break
-		}
-
-		var chunk models.LLMResponse
-		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
-			slog.Warn("llm.stream.parse_error", "data", data, "error", err)
+// ListModels returns the models advertised by every registered provider,
+// keyed by provider name. Providers that fail to answer are logged and skipped.
+func (l *LLM) ListModels(ctx context.Context) map[string][]string {
+	out := make(map[string][]string)
+	for _, p := range l.providers.Providers() {
+		ids, err := p.ListModels(ctx)
+		if err != nil {
+			slog.Warn("llm.list_models_error", "provider", p.Name(), "error", err)
 			continue
 		}
+		out[p.Name()] = ids
+	}
+	return out
+}
 
-		if err := cb(chunk); err != nil {
-			return fmt.Errorf("llm: callback: %w", err)
-		}
+func (l *LLM) buildRequest(messages []models.LLMMessage, stream bool, opts []RequestOption) *models.LLMRequest {
+	o := l.defaultOpts()
+	for _, fn := range opts {
+		fn(&o)
 	}
 
-	return scanner.Err()
+	rf := structuredFormat(o.ResponseFormat)
+	return &models.LLMRequest{
+		Model:          o.Model,
+		Messages:       applyResponseFormat(messages, rf),
+		Temperature:    o.Temperature,
+		MaxTokens:      o.MaxTokens,
+		Stream:         stream,
+		Tools:          o.Tools,
+		ResponseFormat: rf,
+	}
 }
 
 // RequestOption modifies the default request parameters.
 type RequestOption func(*requestOpts)
 
 type requestOpts struct {
-	Model       string
-	Temperature float64
-	MaxTokens   int
+	Model          string
+	Temperature    float64
+	MaxTokens      int
+	Tools          []models.LLMTool
+	ResponseFormat *models.ResponseFormat
 }
 
 func (l *LLM) defaultOpts() requestOpts {
@@ -209,3 +212,14 @@ func WithTemperature(t float64) RequestOption {
 func WithMaxTokens(n int) RequestOption {
 	return func(o *requestOpts) { o.MaxTokens = n }
 }
+
+// WithResponseFormat requests JSON output. Non-streaming completions are
+// validated against it and re-prompted on mismatch.
+func WithResponseFormat(rf *models.ResponseFormat) RequestOption {
+	return func(o *requestOpts) { o.ResponseFormat = rf }
+}
+
+// WithTools declares the functions the model may call.
+func WithTools(tools []models.LLMTool) RequestOption {
+	return func(o *requestOpts) { o.Tools = tools }
+}
@@ -0,0 +1,370 @@
+// Anthropic Messages-style provider adapter (/messages, /models).
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/prakyathpnayak/roognis/internal/models"
+)
+
+// anthropicDefaultMaxTokens is sent when the caller leaves max_tokens unset;
+// the Messages API requires the field.
+const anthropicDefaultMaxTokens = 1024
+
+// AnthropicProvider talks to an Anthropic Messages-compatible HTTP API.
+type AnthropicProvider struct {
+	baseURL string
+	apiKey  string
+	version string
+	clients HTTPClients
+}
+
+// NewAnthropicProvider creates a Messages API adapter. baseURL includes the
+// version prefix (e.g. https://api.anthropic.com/v1).
+func NewAnthropicProvider(baseURL, apiKey, version string, clients HTTPClients) *AnthropicProvider {
+	return &AnthropicProvider{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		version: version,
+		clients: clients,
+	}
+}
+
+// Name implements LLMProvider.
+func (p *AnthropicProvider) Name() string { return "anthropic" }
+
+func (p *AnthropicProvider) headers() map[string]string {
+	return map[string]string{
+		"x-api-key":         p.apiKey,
+		"anthropic-version": p.version,
+	}
+}
+
+type anthropicMessage struct {
+	Role    string                  `json:"role"`
+	Content []anthropicContentBlock `json:"content"`
+}
+
+// anthropicContentBlock is a text, tool_use or tool_result block.
+type anthropicContentBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
+}
+
+type anthropicTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+type anthropicRequest struct {
+	Model       string             `json:"model"`
+	System      string             `json:"system,omitempty"`
+	Messages    []anthropicMessage `json:"messages"`
+	MaxTokens   int                `json:"max_tokens"`
+	Temperature float64            `json:"temperature"`
+	Stream      bool               `json:"stream,omitempty"`
+	Tools       []anthropicTool    `json:"tools,omitempty"`
+}
+
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+type anthropicResponse struct {
+	ID         string                  `json:"id"`
+	Model      string                  `json:"model"`
+	Content    []anthropicContentBlock `json:"content"`
+	StopReason string                  `json:"stop_reason"`
+	Usage      anthropicUsage          `json:"usage"`
+}
+
+// anthropicStreamEvent covers the fields we read from the SSE event types
+// message_start, content_block_start, content_block_delta and message_delta.
+type anthropicStreamEvent struct {
+	Type         string                 `json:"type"`
+	Index        int                    `json:"index"`
+	Message      *anthropicResponse     `json:"message,omitempty"`
+	ContentBlock *anthropicContentBlock `json:"content_block,omitempty"`
+	Delta        struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage anthropicUsage `json:"usage"`
+}
+
+func (p *AnthropicProvider) messagesRequest(req *models.LLMRequest, stream bool) anthropicRequest {
+	system, rest := splitSystem(req.Messages)
+
+	// The Messages API requires alternating user/assistant turns, so
+	// consecutive messages with the same role are merged. Tool results are
+	// sent as tool_result blocks in a user turn.
+	msgs := make([]anthropicMessage, 0, len(rest))
+	for _, m := range rest {
+		role, blocks := anthropicBlocks(m)
+		if len(blocks) == 0 {
+			continue
+		}
+		if n := len(msgs); n > 0 && msgs[n-1].Role == role {
+			msgs[n-1].Content = append(msgs[n-1].Content, blocks...)
+			continue
+		}
+		msgs = append(msgs, anthropicMessage{Role: role, Content: blocks})
+	}
+
+	maxTokens := req.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = anthropicDefaultMaxTokens
+	}
+
+	var tools []anthropicTool
+	for _, t := range req.Tools {
+		tools = append(tools, anthropicTool{
+			Name:        t.Function.Name,
+			Description: t.Function.Description,
+			InputSchema: t.Function.Parameters,
+		})
+	}
+
+	return anthropicRequest{
+		Model:       req.Model,
+		System:      system,
+		Messages:    msgs,
+		MaxTokens:   maxTokens,
+		Temperature: req.Temperature,
+		Stream:      stream,
+		Tools:       tools,
+	}
+}
+
+// anthropicBlocks converts one OpenAI-shaped message into a role and content
+// blocks. Empty text blocks are dropped; the API rejects them.
+func anthropicBlocks(m models.LLMMessage) (string, []anthropicContentBlock) {
+	if m.Role == string(models.RoleToolMsg) {
+		return "user", []anthropicContentBlock{{Type: "tool_result", ToolUseID: m.ToolCallID, Content: m.Content}}
+	}
+
+	var blocks []anthropicContentBlock
+	if m.Content != "" {
+		blocks = append(blocks, anthropicContentBlock{Type: "text", Text: m.Content})
+	}
+	for _, tc := range m.ToolCalls {
+		input := json.RawMessage(tc.Function.Arguments)
+		if !json.Valid(input) {
+			input = json.RawMessage("{}")
+		}
+		blocks = append(blocks, anthropicContentBlock{Type: "tool_use", ID: tc.ID, Name: tc.Function.Name, Input: input})
+	}
+	return m.Role, blocks
+}
+
+// anthropicFinishReason maps stop_reason values to OpenAI finish_reason values.
+func anthropicFinishReason(stop string) string {
+	switch stop {
+	case "end_turn", "stop_sequence":
+		return "stop"
+	case "max_tokens":
+		return "length"
+	case "tool_use":
+		return "tool_calls"
+	default:
+		return stop
+	}
+}
+
+// Complete implements LLMProvider.
+func (p *AnthropicProvider) Complete(ctx context.Context, req *models.LLMRequest) (*models.LLMResponse, error) {
+	resp, err := doJSON(ctx, p.clients.Unary, p.Name(), http.MethodPost, p.baseURL+"/messages", p.messagesRequest(req, false), p.headers())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var ar anthropicResponse
+	if err := json.NewDecoder(resp.Body).Decode(&ar); err != nil {
+		return nil, fmt.Errorf("llm: anthropic: decode: %w", err)
+	}
+
+	var text strings.Builder
+	var calls []models.LLMToolCall
+	for _, block := range ar.Content {
+		switch block.Type {
+		case "text":
+			text.WriteString(block.Text)
+		case "tool_use":
+			calls = append(calls, models.LLMToolCall{
+				ID:       block.ID,
+				Type:     "function",
+				Function: models.LLMFunctionCall{Name: block.Name, Arguments: string(block.Input)},
+			})
+		}
+	}
+	reason := anthropicFinishReason(ar.StopReason)
+
+	return &models.LLMResponse{
+		ID:    ar.ID,
+		Model: ar.Model,
+		Choices: []models.LLMChoice{{
+			Message:      models.LLMMessage{Role: "assistant", Content: text.String(), ToolCalls: calls},
+			FinishReason: &reason,
+		}},
+		Usage: models.LLMUsage{
+			PromptTokens:     ar.Usage.InputTokens,
+			CompletionTokens: ar.Usage.OutputTokens,
+			TotalTokens:      ar.Usage.InputTokens + ar.Usage.OutputTokens,
+		},
+	}, nil
+}
+
+// CompleteStream implements LLMProvider. Text deltas are forwarded as they
+// arrive; the final chunk carries the finish reason and token usage. An
+// error event fails with an *UpstreamError, and a stream that ends before
+// message_stop with io.ErrUnexpectedEOF, so the partial answer is not taken
+// as complete.
+func (p *AnthropicProvider) CompleteStream(ctx context.Context, req *models.LLMRequest, cb StreamCallback) error {
+	headers := p.headers()
+	headers["Accept"] = "text/event-stream"
+
+	resp, err := doJSON(ctx, p.clients.Stream, p.Name(), http.MethodPost, p.baseURL+"/messages", p.messagesRequest(req, true), headers)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var id, model string
+	var usage anthropicUsage
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		data := strings.TrimPrefix(line, "data: ")
+
+		var ev anthropicStreamEvent
+		if err := json.Unmarshal([]byte(data), &ev); err != nil {
+			slog.Warn("llm.stream.parse_error", "provider", p.Name(), "data", data, "error", err)
+			continue
+		}
+
+		var chunk *models.LLMResponse
+		switch ev.Type {
+		case "message_start":
+			if ev.Message != nil {
+				id, model = ev.Message.ID, ev.Message.Model
+				usage.InputTokens = ev.Message.Usage.InputTokens
+			}
+		case "content_block_start":
+			// A tool_use block opens with its id and name; the input
+			// follows as input_json_delta fragments.
+			if ev.ContentBlock == nil || ev.ContentBlock.Type != "tool_use" {
+				continue
+			}
+			chunk = anthropicToolDelta(id, model, models.LLMToolCall{
+				Index:    &ev.Index,
+				ID:       ev.ContentBlock.ID,
+				Type:     "function",
+				Function: models.LLMFunctionCall{Name: ev.ContentBlock.Name},
+			})
+		case "content_block_delta":
+			switch ev.Delta.Type {
+			case "text_delta":
+				chunk = &models.LLMResponse{
+					ID:      id,
+					Model:   model,
+					Choices: []models.LLMChoice{{Delta: models.LLMMessage{Role: "assistant", Content: ev.Delta.Text}}},
+				}
+			case "input_json_delta":
+				chunk = anthropicToolDelta(id, model, models.LLMToolCall{
+					Index:    &ev.Index,
+					Function: models.LLMFunctionCall{Arguments: ev.Delta.PartialJSON},
+				})
+			default:
+				continue
+			}
+		case "message_delta":
+			usage.OutputTokens = ev.Usage.OutputTokens
+			reason := anthropicFinishReason(ev.Delta.StopReason)
+			chunk = &models.LLMResponse{
+				ID:      id,
+				Model:   model,
+				Choices: []models.LLMChoice{{FinishReason: &reason}},
+				Usage: models.LLMUsage{
+					PromptTokens:     usage.InputTokens,
+					CompletionTokens: usage.OutputTokens,
+					TotalTokens:      usage.InputTokens + usage.OutputTokens,
+				},
+			}
+		case "message_stop":
+			return nil
+		case "error":
+			// Sent with a 200 once the response has started.
+			return &UpstreamError{Provider: p.Name(), StatusCode: http.StatusBadGateway, Body: data}
+		}
+
+		if chunk != nil {
+			if err := cb(*chunk); err != nil {
+				return &callbackError{err: err}
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("llm: anthropic: stream ended before message_stop: %w", io.ErrUnexpectedEOF)
+}
+
+func anthropicToolDelta(id, model string, call models.LLMToolCall) *models.LLMResponse {
+	return &models.LLMResponse{
+		ID:      id,
+		Model:   model,
+		Choices: []models.LLMChoice{{Delta: models.LLMMessage{Role: "assistant", ToolCalls: []models.LLMToolCall{call}}}},
+	}
+}
+
+// Embed implements LLMProvider. The Messages API has no embeddings endpoint.
+func (p *AnthropicProvider) Embed(ctx context.Context, model string, input []string) ([][]float32, error) {
+	return nil, ErrNotSupported
+}
+
+// ListModels implements LLMProvider via GET /models.
+func (p *AnthropicProvider) ListModels(ctx context.Context) ([]string, error) {
+	resp, err := doJSON(ctx, p.clients.Unary, p.Name(), http.MethodGet, p.baseURL+"/models", nil, p.headers())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("llm: anthropic: decode models: %w", err)
+	}
+
+	ids := make([]string, 0, len(out.Data))
+	for _, m := range out.Data {
+		ids = append(ids, m.ID)
+	}
+	return ids, nil
+}
@@ -0,0 +1,282 @@
+// Native Ollama provider adapter (/api/chat NDJSON streaming, /api/embed, /api/tags).
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prakyathpnayak/roognis/internal/models"
+)
+
+// OllamaProvider talks to Ollama's native REST API.
+type OllamaProvider struct {
+	baseURL string
+	clients HTTPClients
+}
+
+// NewOllamaProvider creates a native Ollama adapter. baseURL is the server
+// root (e.g. http://localhost:11434), without the /api prefix.
+func NewOllamaProvider(baseURL string, clients HTTPClients) *OllamaProvider {
+	return &OllamaProvider{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		clients: clients,
+	}
+}
+
+// Name implements LLMProvider.
+func (p *OllamaProvider) Name() string { return "ollama" }
+
+type ollamaOptions struct {
+	Temperature float64 `json:"temperature"`
+	NumPredict  int     `json:"num_predict,omitempty"`
+}
+
+type ollamaChatRequest struct {
+	Model    string           `json:"model"`
+	Messages []ollamaMessage  `json:"messages"`
+	Stream   bool             `json:"stream"`
+	Options  ollamaOptions    `json:"options"`
+	Tools    []models.LLMTool `json:"tools,omitempty"`
+	Format   json.RawMessage  `json:"format,omitempty"` // "json" or a JSON Schema
+}
+
+// ollamaMessage differs from the OpenAI shape in tool calls: arguments are a
+// JSON object rather than a string, calls carry no id, and tool results are
+// matched by tool_name.
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"`
+}
+
+type ollamaToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+type ollamaChatResponse struct {
+	Model           string        `json:"model"`
+	CreatedAt       time.Time     `json:"created_at"`
+	Message         ollamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	DoneReason      string        `json:"done_reason"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+	Error           string        `json:"error"` // set instead of the rest on failure
+}
+
+// upstreamError reports an error frame. Ollama sends these with a 200 once
+// the response has started, so they count as a bad gateway.
+func (r *ollamaChatResponse) upstreamError(provider string) error {
+	return &UpstreamError{Provider: provider, StatusCode: http.StatusBadGateway, Body: r.Error}
+}
+
+func (p *OllamaProvider) chatRequest(req *models.LLMRequest, stream bool) ollamaChatRequest {
+	// Tool results reference the call by id; Ollama wants the function name.
+	callNames := make(map[string]string)
+	msgs := make([]ollamaMessage, 0, len(req.Messages))
+	for _, m := range req.Messages {
+		om := ollamaMessage{Role: m.Role, Content: m.Content}
+		for _, tc := range m.ToolCalls {
+			callNames[tc.ID] = tc.Function.Name
+			var oc ollamaToolCall
+			oc.Function.Name = tc.Function.Name
+			oc.Function.Arguments = json.RawMessage(tc.Function.Arguments)
+			if !json.Valid(oc.Function.Arguments) {
+				oc.Function.Arguments = json.RawMessage("{}")
+			}
+			om.ToolCalls = append(om.ToolCalls, oc)
+		}
+		if m.Role == string(models.RoleToolMsg) {
+			om.ToolName = callNames[m.ToolCallID]
+		}
+		msgs = append(msgs, om)
+	}
+
+	var format json.RawMessage
+	if rf := req.ResponseFormat; rf != nil {
+		format = json.RawMessage(`"json"`)
+		if rf.Type == models.ResponseFormatJSONSchema && rf.JSONSchema != nil {
+			format = rf.JSONSchema.Schema
+		}
+	}
+
+	return ollamaChatRequest{
+		Model:    req.Model,
+		Messages: msgs,
+		Stream:   stream,
+		Options: ollamaOptions{
+			Temperature: req.Temperature,
+			NumPredict:  req.MaxTokens,
+		},
+		Tools:  req.Tools,
+		Format: format,
+	}
+}
+
+// toLLMResponse converts an Ollama chat frame into the OpenAI shape. For
+// streamed frames the content is placed in Delta, otherwise in Message.
+// Ollama emits each tool call whole, so calls get synthetic ids and indexes
+// counted from firstCall; a stream numbers its calls across frames so calls
+// sent in separate frames are not merged.
+func (r *ollamaChatResponse) toLLMResponse(stream bool, firstCall int) models.LLMResponse {
+	msg := models.LLMMessage{Role: r.Message.Role, Content: r.Message.Content}
+	for i, tc := range r.Message.ToolCalls {
+		idx := firstCall + i
+		msg.ToolCalls = append(msg.ToolCalls, models.LLMToolCall{
+			Index: &idx,
+			ID:    "call_" + strings.ReplaceAll(uuid.NewString(), "-", ""),
+			Type:  "function",
+			Function: models.LLMFunctionCall{
+				Name:      tc.Function.Name,
+				Arguments: string(tc.Function.Arguments),
+			},
+		})
+	}
+
+	choice := models.LLMChoice{Index: 0}
+	if stream {
+		choice.Delta = msg
+	} else {
+		choice.Message = msg
+	}
+
+	out := models.LLMResponse{Model: r.Model, Choices: []models.LLMChoice{choice}}
+	if len(msg.ToolCalls) > 0 {
+		reason := "tool_calls"
+		out.Choices[0].FinishReason = &reason
+	}
+	if r.Done {
+		reason := r.DoneReason
+		if reason == "" {
+			reason = "stop"
+		}
+		if out.Choices[0].FinishReason == nil {
+			out.Choices[0].FinishReason = &reason
+		}
+		out.Usage = models.LLMUsage{
+			PromptTokens:     r.PromptEvalCount,
+			CompletionTokens: r.EvalCount,
+			TotalTokens:      r.PromptEvalCount + r.EvalCount,
+		}
+	}
+	return out
+}
+
+// Complete implements LLMProvider.
+func (p *OllamaProvider) Complete(ctx context.Context, req *models.LLMRequest) (*models.LLMResponse, error) {
+	resp, err := doJSON(ctx, p.clients.Unary, p.Name(), http.MethodPost, p.baseURL+"/api/chat", p.chatRequest(req, false), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var frame ollamaChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&frame); err != nil {
+		return nil, fmt.Errorf("llm: ollama: decode: %w", err)
+	}
+	if frame.Error != "" {
+		return nil, frame.upstreamError(p.Name())
+	}
+
+	out := frame.toLLMResponse(false, 0)
+	return &out, nil
+}
+
+// CompleteStream implements LLMProvider. Ollama streams newline-delimited
+// JSON objects; the last one carries done=true and the token counts. A
+// stream that ends without it was cut short and fails with
+// io.ErrUnexpectedEOF, so the partial answer is not taken as complete.
+func (p *OllamaProvider) CompleteStream(ctx context.Context, req *models.LLMRequest, cb StreamCallback) error {
+	resp, err := doJSON(ctx, p.clients.Stream, p.Name(), http.MethodPost, p.baseURL+"/api/chat", p.chatRequest(req, true), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	calls := 0 // tool calls received so far
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var frame ollamaChatResponse
+		if err := json.Unmarshal([]byte(line), &frame); err != nil {
+			slog.Warn("llm.stream.parse_error", "provider", p.Name(), "data", line, "error", err)
+			continue
+		}
+		if frame.Error != "" {
+			return frame.upstreamError(p.Name())
+		}
+
+		if err := cb(frame.toLLMResponse(true, calls)); err != nil {
+			return &callbackError{err: err}
+		}
+		calls += len(frame.Message.ToolCalls)
+		if frame.Done {
+			return nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("llm: ollama: stream ended before done: %w", io.ErrUnexpectedEOF)
+}
+
+// Embed implements LLMProvider via POST /api/embed.
+func (p *OllamaProvider) Embed(ctx context.Context, model string, input []string) ([][]float32, error) {
+	body := map[string]any{"model": model, "input": input}
+
+	resp, err := doJSON(ctx, p.clients.Unary, p.Name(), http.MethodPost, p.baseURL+"/api/embed", body, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("llm: ollama: decode embeddings: %w", err)
+	}
+	return out.Embeddings, nil
+}
+
+// ListModels implements LLMProvider via GET /api/tags.
+func (p *OllamaProvider) ListModels(ctx context.Context) ([]string, error) {
+	resp, err := doJSON(ctx, p.clients.Unary, p.Name(), http.MethodGet, p.baseURL+"/api/tags", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("llm: ollama: decode models: %w", err)
+	}
+
+	names := make([]string, 0, len(out.Models))
+	for _, m := range out.Models {
+		names = append(names, m.Name)
+	}
+	return names, nil
+}
@@ -0,0 +1,190 @@
+// OpenAI-compatible provider adapter (/chat/completions, /embeddings, /models).
+// Compatible with OpenAI, Azure OpenAI, Ollama's /v1 shim, LiteLLM proxy, vLLM, etc.
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/prakyathpnayak/roognis/internal/models"
+)
+
+// OpenAIProvider talks to an OpenAI-compatible HTTP API.
+type OpenAIProvider struct {
+	name    string
+	baseURL string
+	apiKey  string
+	clients HTTPClients
+}
+
+// NewOpenAIProvider creates an OpenAI-compatible adapter. baseURL includes the
+// version prefix (e.g. https://api.openai.com/v1).
+func NewOpenAIProvider(name, baseURL, apiKey string, clients HTTPClients) *OpenAIProvider {
+	return &OpenAIProvider{
+		name:    name,
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		clients: clients,
+	}
+}
+
+// Name implements LLMProvider.
+func (p *OpenAIProvider) Name() string { return p.name }
+
+func (p *OpenAIProvider) headers() map[string]string {
+	h := map[string]string{}
+	if p.apiKey != "" {
+		h["Authorization"] = "Bearer " + p.apiKey
+	}
+	return h
+}
+
+// openAIBody returns the request as sent on the wire. The API requires a
+// name on json_schema response formats, so one is filled in when missing.
+// Streams ask for a final usage chunk.
+func openAIBody(req *models.LLMRequest, stream bool) models.LLMRequest {
+	body := *req
+	body.Stream = stream
+	if stream {
+		body.StreamOptions = &models.StreamOptions{IncludeUsage: true}
+	}
+	if rf := body.ResponseFormat; rf != nil && rf.JSONSchema != nil && rf.JSONSchema.Name == "" {
+		schema := *rf.JSONSchema
+		schema.Name = "response"
+		body.ResponseFormat = &models.ResponseFormat{Type: rf.Type, JSONSchema: &schema}
+	}
+	return body
+}
+
+// Complete implements LLMProvider.
+func (p *OpenAIProvider) Complete(ctx context.Context, req *models.LLMRequest) (*models.LLMResponse, error) {
+	body := openAIBody(req, false)
+
+	resp, err := doJSON(ctx, p.clients.Unary, p.name, http.MethodPost, p.baseURL+"/chat/completions", body, p.headers())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var llmResp models.LLMResponse
+	if err := json.NewDecoder(resp.Body).Decode(&llmResp); err != nil {
+		return nil, fmt.Errorf("llm: %s: decode: %w", p.name, err)
+	}
+	return &llmResp, nil
+}
+
+// openAIStreamChunk is one data line of a stream: a chunk, or an error
+// object when the upstream fails after the response has started.
+type openAIStreamChunk struct {
+	models.LLMResponse
+	Error json.RawMessage `json:"error,omitempty"`
+}
+
+// CompleteStream implements LLMProvider. An error object fails with an
+// *UpstreamError, and a stream that ends before [DONE] with
+// io.ErrUnexpectedEOF, so the partial answer is not taken as complete.
+func (p *OpenAIProvider) CompleteStream(ctx context.Context, req *models.LLMRequest, cb StreamCallback) error {
+	body := openAIBody(req, true)
+
+	headers := p.headers()
+	headers["Accept"] = "text/event-stream"
+
+	resp, err := doJSON(ctx, p.clients.Stream, p.name, http.MethodPost, p.baseURL+"/chat/completions", body, headers)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// M8 fix: Increase scanner buffer for long SSE lines
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024) // Up to 1 MB per line
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+
+		data := strings.TrimPrefix(line, "data: ")
+		if data == "[DONE]" {
+			return nil
+		}
+
+		var chunk openAIStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			slog.Warn("llm.stream.parse_error", "provider", p.name, "data", data, "error", err)
+			continue
+		}
+		if len(chunk.Error) > 0 && string(chunk.Error) != "null" {
+			return &UpstreamError{Provider: p.name, StatusCode: http.StatusBadGateway, Body: string(chunk.Error)}
+		}
+
+		if err := cb(chunk.LLMResponse); err != nil {
+			return &callbackError{err: err}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("llm: %s: stream ended before [DONE]: %w", p.name, io.ErrUnexpectedEOF)
+}
+
+// Embed implements LLMProvider via POST /embeddings.
+func (p *OpenAIProvider) Embed(ctx context.Context, model string, input []string) ([][]float32, error) {
+	body := map[string]any{"model": model, "input": input}
+
+	resp, err := doJSON(ctx, p.clients.Unary, p.name, http.MethodPost, p.baseURL+"/embeddings", body, p.headers())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("llm: %s: decode embeddings: %w", p.name, err)
+	}
+
+	vectors := make([][]float32, len(input))
+	for _, d := range out.Data {
+		if d.Index >= 0 && d.Index < len(vectors) {
+			vectors[d.Index] = d.Embedding
+		}
+	}
+	return vectors, nil
+}
+
+// ListModels implements LLMProvider via GET /models.
+func (p *OpenAIProvider) ListModels(ctx context.Context) ([]string, error) {
+	resp, err := doJSON(ctx, p.clients.Unary, p.name, http.MethodGet, p.baseURL+"/models", nil, p.headers())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("llm: %s: decode models: %w", p.name, err)
+	}
+
+	ids := make([]string, 0, len(out.Data))
+	for _, m := range out.Data {
+		ids = append(ids, m.ID)
+	}
+	return ids, nil
+}
@@ -0,0 +1,219 @@
+// LLM provider abstraction — one adapter per upstream API dialect.
+// Maps to design.swift: Text LLM Inference Node (model serving backends)
+//
+// Every adapter speaks the OpenAI chat-completion shape (models.LLMRequest /
+// models.LLMResponse) towards the orchestrator and translates to its native
+// wire format internally. The ProviderRegistry picks an adapter per model name.
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prakyathpnayak/roognis/internal/models"
+)
+
+// ErrNotSupported is returned by providers for capabilities their API lacks
+// (e.g. embeddings on the Anthropic Messages API).
+var ErrNotSupported = errors.New("llm: operation not supported by provider")
+
+// LLMProvider is implemented by every upstream inference backend.
+type LLMProvider interface {
+	// Name is the registry key of the provider (e.g. "openai", "ollama").
+	Name() string
+	// Complete sends a non-streaming chat completion request.
+	Complete(ctx context.Context, req *models.LLMRequest) (*models.LLMResponse, error)
+	// CompleteStream sends a streaming chat completion request, calling cb per chunk.
+	CompleteStream(ctx context.Context, req *models.LLMRequest, cb StreamCallback) error
+	// Embed returns one embedding vector per input string.
+	Embed(ctx context.Context, model string, input []string) ([][]float32, error)
+	// ListModels returns the model identifiers served by the provider.
+	ListModels(ctx context.Context) ([]string, error)
+}
+
+// UpstreamError is returned when a provider answers with a non-success status.
+type UpstreamError struct {
+	Provider   string
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration // parsed from the Retry-After header, 0 if absent
+}
+
+func (e *UpstreamError) Error() string {
+	return fmt.Sprintf("llm: %s: status %d: %s", e.Provider, e.StatusCode, e.Body)
+}
+
+// providerRoute maps a model-name glob (path.Match syntax) to a provider.
+type providerRoute struct {
+	pattern  string
+	provider string
+}
+
+// ProviderRegistry resolves model names to providers.
+type ProviderRegistry struct {
+	mu        sync.RWMutex
+	providers map[string]LLMProvider
+	pinned    map[string]string // exact model name → provider
+	routes    []providerRoute
+	fallback  string
+}
+
+// NewProviderRegistry creates an empty registry. Models that match no route
+// are served by the provider named defaultProvider.
+func NewProviderRegistry(defaultProvider string) *ProviderRegistry {
+	return &ProviderRegistry{
+		providers: make(map[string]LLMProvider),
+		pinned:    make(map[string]string),
+		fallback:  defaultProvider,
+	}
+}
+
+// Register adds (or replaces) a provider under its Name.
+func (r *ProviderRegistry) Register(p LLMProvider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.providers[p.Name()] = p
+}
+
+// Route sends models matching pattern to the named provider. Routes are
+// evaluated in registration order; the first match wins.
+func (r *ProviderRegistry) Route(pattern, provider string) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("llm: invalid route pattern %q: %w", pattern, err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.routes = append(r.routes, providerRoute{pattern: pattern, provider: provider})
+	return nil
+}
+
+// Pin sends exactly the named model to provider, taking precedence over routes.
+func (r *ProviderRegistry) Pin(model, provider string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pinned[model] = provider
+}
+
+// Resolve returns the provider responsible for model.
+func (r *ProviderRegistry) Resolve(model string) (LLMProvider, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	name, ok := r.pinned[model]
+	if !ok {
+		name = r.fallback
+		for _, rt := range r.routes {
+			if ok, _ := path.Match(rt.pattern, model); ok {
+				name = rt.provider
+				break
+			}
+		}
+	}
+
+	p, ok := r.providers[name]
+	if !ok {
+		return nil, fmt.Errorf("llm: no provider %q registered for model %q", name, model)
+	}
+	return p, nil
+}
+
+// Providers returns all registered providers sorted by name.
+func (r *ProviderRegistry) Providers() []LLMProvider {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	out := make([]LLMProvider, 0, len(r.providers))
+	for _, p := range r.providers {
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name() < out[j].Name() })
+	return out
+}
+
+// ── Shared HTTP helpers for adapters ────────────────────────────────
+
+// doJSON issues an HTTP request with an optional JSON body and returns the
+// response when the status is 2xx. Any other status is drained (bounded to
+// 64 KB) into an *UpstreamError.
+func doJSON(ctx context.Context, client *http.Client, provider, method, url string, body any, headers map[string]string) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("llm: %s: marshal: %w", provider, err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return nil, fmt.Errorf("llm: %s: create request: %w", provider, err)
+	}
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range headers {
+		httpReq.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("llm: %s: request: %w", provider, err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		// M3 fix: Limit error response body to 64 KB
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		return nil, &UpstreamError{
+			Provider:   provider,
+			StatusCode: resp.StatusCode,
+			Body:       strings.TrimSpace(string(respBody)),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+	return resp, nil
+}
+
+// parseRetryAfter accepts both forms of the Retry-After header: delay in
+// seconds or an HTTP date.
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(strings.TrimSpace(v)); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// splitSystem separates system messages (joined by blank lines) from the rest
+// of the conversation, for APIs that take the system prompt out-of-band.
+func splitSystem(messages []models.LLMMessage) (string, []models.LLMMessage) {
+	var system []string
+	rest := make([]models.LLMMessage, 0, len(messages))
+	for _, m := range messages {
+		if m.Role == "system" {
+			system = append(system, m.Content)
+			continue
+		}
+		rest = append(rest, m)
+	}
+	return strings.Join(system, "\n\n"), rest
+}
@@ -0,0 +1,251 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prakyathpnayak/roognis/internal/models"
+)
+
+func TestProviderRegistryResolvesByRouteThenDefault(t *testing.T) {
+	reg := NewProviderRegistry("openai")
+	reg.Register(NewOpenAIProvider("openai", "http://unused", "", testClients()))
+	reg.Register(NewOllamaProvider("http://unused", testClients()))
+	if err := reg.Route("llama3*", "ollama"); err != nil {
+		t.Fatalf("unexpected route error: %v", err)
+	}
+
+	cases := map[string]string{
+		"llama3.1:8b": "ollama",
+		"gpt-4o-mini": "openai",
+	}
+	for model, want := range cases {
+		p, err := reg.Resolve(model)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", model, err)
+		}
+		if p.Name() != want {
+			t.Fatalf("%s: expected provider %q, got %q", model, want, p.Name())
+		}
+	}
+
+	if err := reg.Route("claude-*", "anthropic"); err != nil {
+		t.Fatalf("unexpected route error: %v", err)
+	}
+	if _, err := reg.Resolve("claude-3-haiku"); err == nil {
+		t.Fatal("expected error for route to unregistered provider")
+	}
+}
+
+func TestOllamaProviderStreamsNDJSON(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			t.Errorf("unexpected path %q", r.URL.Path)
+		}
+		fmt.Fprintln(w, `{"model":"llama3","message":{"role":"assistant","content":"Hel"},"done":false}`)
+		fmt.Fprintln(w, `{"model":"llama3","message":{"role":"assistant","content":"lo"},"done":false}`)
+		fmt.Fprintln(w, `{"model":"llama3","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":5,"eval_count":2}`)
+	}))
+	defer srv.Close()
+
+	p := NewOllamaProvider(srv.URL, testClients())
+	var content strings.Builder
+	var last models.LLMResponse
+	err := p.CompleteStream(context.Background(), &models.LLMRequest{Model: "llama3"}, func(chunk models.LLMResponse) error {
+		content.WriteString(chunk.Choices[0].Delta.Content)
+		last = chunk
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if content.String() != "Hello" {
+		t.Fatalf("expected streamed content %q, got %q", "Hello", content.String())
+	}
+	if last.Choices[0].FinishReason == nil || *last.Choices[0].FinishReason != "stop" {
+		t.Fatal("expected final chunk to carry finish reason")
+	}
+	if last.Usage.TotalTokens != 7 {
+		t.Fatalf("expected total tokens 7, got %d", last.Usage.TotalTokens)
+	}
+}
+
+func TestOllamaProviderStreamsToolCallsInSeparateFrames(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"model":"llama3","message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"add","arguments":{"a":1}}}]},"done":false}`)
+		fmt.Fprintln(w, `{"model":"llama3","message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"mul","arguments":{"b":2}}}]},"done":false}`)
+		fmt.Fprintln(w, `{"model":"llama3","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop"}`)
+	}))
+	defer srv.Close()
+
+	p := NewOllamaProvider(srv.URL, testClients())
+	var acc toolCallAccumulator
+	err := p.CompleteStream(context.Background(), &models.LLMRequest{Model: "llama3"}, func(chunk models.LLMResponse) error {
+		acc.Add(chunk.Choices[0].Delta.ToolCalls)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	calls := acc.Calls()
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 tool calls, got %+v", calls)
+	}
+	if calls[0].Function.Name != "add" || calls[0].Function.Arguments != `{"a":1}` ||
+		calls[1].Function.Name != "mul" || calls[1].Function.Arguments != `{"b":2}` {
+		t.Fatalf("expected add and mul with their own arguments, got %+v", calls)
+	}
+	if calls[0].ID == calls[1].ID {
+		t.Fatalf("expected distinct call ids, got %q twice", calls[0].ID)
+	}
+}
+
+func TestOllamaProviderStreamFailures(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		check func(err error) bool
+	}{
+		{
+			name: "error frame",
+			body: `{"model":"llama3","message":{"role":"assistant","content":"Hel"},"done":false}` + "\n" + `{"error":"model runner has unexpectedly stopped"}` + "\n",
+			check: func(err error) bool {
+				var upErr *UpstreamError
+				return errors.As(err, &upErr) && strings.Contains(upErr.Body, "unexpectedly stopped")
+			},
+		},
+		{
+			name:  "no done frame",
+			body:  `{"model":"llama3","message":{"role":"assistant","content":"Hel"},"done":false}` + "\n",
+			check: func(err error) bool { return errors.Is(err, io.ErrUnexpectedEOF) },
+		},
+	}
+	for _, tt := range tests {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, tt.body)
+		}))
+		p := NewOllamaProvider(srv.URL, testClients())
+		err := p.CompleteStream(context.Background(), &models.LLMRequest{Model: "llama3"}, func(models.LLMResponse) error { return nil })
+		srv.Close()
+		if !tt.check(err) {
+			t.Fatalf("%s: unexpected error %v", tt.name, err)
+		}
+	}
+}
+
+func TestSSEProviderStreamFailures(t *testing.T) {
+	isUpstream := func(err error) bool {
+		var upErr *UpstreamError
+		return errors.As(err, &upErr) && strings.Contains(upErr.Body, "overloaded")
+	}
+	isEOF := func(err error) bool { return errors.Is(err, io.ErrUnexpectedEOF) }
+	anthropic := func(url string) LLMProvider { return NewAnthropicProvider(url, "", "2023-06-01", testClients()) }
+	openai := func(url string) LLMProvider { return NewOpenAIProvider("openai", url, "", testClients()) }
+
+	const (
+		anthropicStart = "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\",\"model\":\"claude-x\"}}\n\n" +
+			"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Hel\"}}\n\n"
+		openaiStart = `data: {"id":"c1","model":"gpt","choices":[{"index":0,"delta":{"content":"Hel"}}]}` + "\n\n"
+	)
+	tests := []struct {
+		name     string
+		provider func(url string) LLMProvider
+		body     string
+		check    func(err error) bool
+	}{
+		{"anthropic error event", anthropic, anthropicStart + "event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n", isUpstream},
+		{"anthropic no message_stop", anthropic, anthropicStart, isEOF},
+		{"openai error object", openai, openaiStart + `data: {"error":{"message":"model overloaded","type":"server_error"}}` + "\n\n", isUpstream},
+		{"openai no done", openai, openaiStart, isEOF},
+	}
+	for _, tt := range tests {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, tt.body)
+		}))
+		var chunks int
+		err := tt.provider(srv.URL).CompleteStream(context.Background(), &models.LLMRequest{Model: "m"}, func(models.LLMResponse) error {
+			chunks++
+			return nil
+		})
+		srv.Close()
+		if !tt.check(err) {
+			t.Fatalf("%s: unexpected error %v", tt.name, err)
+		}
+		if chunks != 1 {
+			t.Fatalf("%s: expected the chunk before the failure only, got %d", tt.name, chunks)
+		}
+	}
+}
+
+func TestAnthropicProviderCompleteMapsSystemAndUsage(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-api-key") != "secret" {
+			t.Errorf("expected api key header")
+		}
+		var body anthropicRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		if body.System != "be nice" || len(body.Messages) != 1 {
+			t.Errorf("expected system prompt out-of-band, got %+v", body)
+		}
+		fmt.Fprint(w, `{"id":"msg_1","model":"claude-x","content":[{"type":"text","text":"hi"}],"stop_reason":"end_turn","usage":{"input_tokens":3,"output_tokens":1}}`)
+	}))
+	defer srv.Close()
+
+	p := NewAnthropicProvider(srv.URL, "secret", "2023-06-01", testClients())
+	resp, err := p.Complete(context.Background(), &models.LLMRequest{
+		Model: "claude-x",
+		Messages: []models.LLMMessage{
+			{Role: "system", Content: "be nice"},
+			{Role: "user", Content: "hello"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Choices[0].Message.Content != "hi" {
+		t.Fatalf("expected content %q, got %q", "hi", resp.Choices[0].Message.Content)
+	}
+	if *resp.Choices[0].FinishReason != "stop" || resp.Usage.TotalTokens != 4 {
+		t.Fatalf("unexpected finish/usage mapping: %+v", resp)
+	}
+}
+
+func TestProviderReturnsUpstreamErrorOnNonSuccess(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "overloaded", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	p := NewOpenAIProvider("openai", srv.URL, "", testClients())
+	_, err := p.Complete(context.Background(), &models.LLMRequest{Model: "gpt-4o-mini"})
+
+	var upErr *UpstreamError
+	if !errors.As(err, &upErr) {
+		t.Fatalf("expected *UpstreamError, got %v", err)
+	}
+	if upErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected status 503, got %d", upErr.StatusCode)
+	}
+}
+
+func TestOpenAIBodyRequestsStreamUsage(t *testing.T) {
+	req := &models.LLMRequest{Model: "gpt-4o-mini"}
+	if body := openAIBody(req, true); body.StreamOptions == nil || !body.StreamOptions.IncludeUsage {
+		t.Fatalf("expected stream_options.include_usage on streams, got %+v", body.StreamOptions)
+	}
+	if body := openAIBody(req, false); body.StreamOptions != nil {
+		t.Fatalf("expected no stream_options without streaming, got %+v", body.StreamOptions)
+	}
+}
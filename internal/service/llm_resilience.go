@@ -0,0 +1,296 @@
+// Upstream resilience — retries with jittered backoff and a circuit breaker
+// per LLM provider.
+// Maps to design.swift: Text LLM Inference Node (fault tolerance)
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prakyathpnayak/roognis/internal/models"
+)
+
+// ErrCircuitOpen is returned without contacting the upstream while a
+// provider's circuit breaker is open.
+var ErrCircuitOpen = errors.New("llm: circuit breaker open")
+
+// Circuit breaker states.
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half_open"
+)
+
+// RetryPolicy configures retries of failed upstream calls.
+type RetryPolicy struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+}
+
+// backoff returns the delay before retry number attempt (0-based) using full
+// jitter. A Retry-After hint from the upstream is honoured as a lower bound;
+// ok is false when the hint exceeds MaxDelay and the retry should be skipped.
+func (p RetryPolicy) backoff(attempt int, retryAfter time.Duration) (d time.Duration, ok bool) {
+	if retryAfter > 0 {
+		return retryAfter, retryAfter <= p.MaxDelay
+	}
+	ceiling := p.BaseDelay << attempt
+	if ceiling <= 0 || ceiling > p.MaxDelay {
+		ceiling = p.MaxDelay
+	}
+	if ceiling <= 0 {
+		return 0, true
+	}
+	return rand.N(ceiling) + 1, true
+}
+
+// CircuitBreaker trips after a run of consecutive failures and lets a single
+// probe through once the cooldown has elapsed.
+type CircuitBreaker struct {
+	name      string
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool
+	now      func() time.Time
+}
+
+// NewCircuitBreaker creates a closed breaker. A threshold <= 0 disables it.
+func NewCircuitBreaker(name string, threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		name:      name,
+		threshold: threshold,
+		cooldown:  cooldown,
+		state:     BreakerClosed,
+		now:       time.Now,
+	}
+}
+
+// Allow reports whether a call may proceed.
+func (b *CircuitBreaker) Allow() error {
+	if b.threshold <= 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.transition(BreakerHalfOpen)
+		b.probing = true
+		return nil
+	case BreakerHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+		return nil
+	}
+	return nil
+}
+
+// Success records a healthy upstream response.
+func (b *CircuitBreaker) Success() {
+	if b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.probing = false
+	if b.state != BreakerClosed {
+		b.transition(BreakerClosed)
+	}
+}
+
+// Failure records an upstream failure.
+func (b *CircuitBreaker) Failure() {
+	if b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+	if b.state == BreakerHalfOpen || (b.state == BreakerClosed && b.failures >= b.threshold) {
+		b.openedAt = b.now()
+		b.transition(BreakerOpen)
+	}
+}
+
+// Release ends a half-open probe without recording an outcome.
+func (b *CircuitBreaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// State returns the current breaker state.
+func (b *CircuitBreaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerOpen && b.now().Sub(b.openedAt) >= b.cooldown {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+// transition must be called with b.mu held.
+func (b *CircuitBreaker) transition(to string) {
+	slog.Warn("llm.breaker_state", "provider", b.name, "from", b.state, "to", to, "failures", b.failures)
+	b.state = to
+}
+
+// resilientProvider decorates an LLMProvider with retries and a breaker.
+type resilientProvider struct {
+	LLMProvider
+	policy  RetryPolicy
+	breaker *CircuitBreaker
+	sleep   func(ctx context.Context, d time.Duration) error
+}
+
+// withResilience wraps p with the given retry policy and circuit breaker.
+func withResilience(p LLMProvider, policy RetryPolicy, breaker *CircuitBreaker) *resilientProvider {
+	return &resilientProvider{LLMProvider: p, policy: policy, breaker: breaker, sleep: sleepCtx}
+}
+
+// BreakerState returns the provider's circuit breaker state.
+func (p *resilientProvider) BreakerState() string {
+	return p.breaker.State()
+}
+
+// Complete implements LLMProvider.
+func (p *resilientProvider) Complete(ctx context.Context, req *models.LLMRequest) (*models.LLMResponse, error) {
+	var resp *models.LLMResponse
+	err := p.do(ctx, "complete", func() error {
+		var err error
+		resp, err = p.LLMProvider.Complete(ctx, req)
+		return err
+	}, nil)
+	return resp, err
+}
+
+// CompleteStream implements LLMProvider. Retries only happen while no chunk
+// has been handed to cb, so the client never sees duplicated output.
+func (p *resilientProvider) CompleteStream(ctx context.Context, req *models.LLMRequest, cb StreamCallback) error {
+	var forwarded bool
+	return p.do(ctx, "stream", func() error {
+		return p.LLMProvider.CompleteStream(ctx, req, func(chunk models.LLMResponse) error {
+			forwarded = true
+			return cb(chunk)
+		})
+	}, func() bool { return !forwarded })
+}
+
+// Embed implements LLMProvider.
+func (p *resilientProvider) Embed(ctx context.Context, model string, input []string) ([][]float32, error) {
+	var vectors [][]float32
+	err := p.do(ctx, "embed", func() error {
+		var err error
+		vectors, err = p.LLMProvider.Embed(ctx, model, input)
+		return err
+	}, nil)
+	return vectors, err
+}
+
+// do runs call under the breaker, retrying retryable failures. canRetry, when
+// non-nil, can veto further attempts (used for partially streamed responses).
+func (p *resilientProvider) do(ctx context.Context, op string, call func() error, canRetry func() bool) error {
+	for attempt := 0; ; attempt++ {
+		if err := p.breaker.Allow(); err != nil {
+			return fmt.Errorf("llm: %s: %w", p.Name(), err)
+		}
+
+		err := call()
+		switch {
+		case err == nil:
+			p.breaker.Success()
+			return nil
+		case ctx.Err() != nil || errors.Is(err, ErrNotSupported) || isCallbackError(err):
+			// Caller went away or gave up; says nothing about upstream health.
+			p.breaker.Release()
+			return err
+		case !isUpstreamFailure(err):
+			p.breaker.Success()
+			return err
+		}
+		p.breaker.Failure()
+
+		if attempt >= p.policy.MaxRetries || (canRetry != nil && !canRetry()) {
+			return err
+		}
+		var retryAfter time.Duration
+		var upErr *UpstreamError
+		if errors.As(err, &upErr) {
+			retryAfter = upErr.RetryAfter
+		}
+		delay, ok := p.policy.backoff(attempt, retryAfter)
+		if !ok {
+			return err
+		}
+
+		slog.Warn("llm.retry",
+			"provider", p.Name(),
+			"op", op,
+			"attempt", attempt+1,
+			"delay_ms", delay.Milliseconds(),
+			"error", err,
+		)
+		if sleepErr := p.sleep(ctx, delay); sleepErr != nil {
+			return err
+		}
+	}
+}
+
+// isUpstreamFailure reports whether err indicates an unhealthy upstream:
+// transport errors, timeouts, 408, 429 and 5xx statuses.
+func isUpstreamFailure(err error) bool {
+	var upErr *UpstreamError
+	if errors.As(err, &upErr) {
+		switch upErr.StatusCode {
+		case http.StatusRequestTimeout, http.StatusTooManyRequests:
+			return true
+		}
+		return upErr.StatusCode >= 500
+	}
+	return true
+}
+
+// callbackError marks errors returned by the caller's stream callback so they
+// are not mistaken for upstream failures.
+type callbackError struct{ err error }
+
+func (e *callbackError) Error() string { return "llm: callback: " + e.err.Error() }
+func (e *callbackError) Unwrap() error { return e.err }
+
+func isCallbackError(err error) bool {
+	var cbErr *callbackError
+	return errors.As(err, &cbErr)
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
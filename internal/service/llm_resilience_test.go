@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prakyathpnayak/roognis/internal/models"
+)
+
+func noSleep(context.Context, time.Duration) error { return nil }
+
+func TestResilientProviderRetriesTransientStatus(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+			return
+		}
+		fmt.Fprint(w, `{"model":"m","choices":[{"message":{"role":"assistant","content":"ok"}}]}`)
+	}))
+	defer srv.Close()
+
+	p := withResilience(NewOpenAIProvider("openai", srv.URL, "", testClients()),
+		RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Second},
+		NewCircuitBreaker("openai", 5, time.Minute))
+	p.sleep = noSleep
+
+	resp, err := p.Complete(context.Background(), &models.LLMRequest{Model: "m"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Choices[0].Message.Content != "ok" || calls.Load() != 3 {
+		t.Fatalf("expected success on third attempt, got %d calls", calls.Load())
+	}
+}
+
+func TestResilientProviderDoesNotRetryStreamAfterFirstChunk(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"par\"}}]}\n\n")
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler) // drop the connection mid-stream
+	}))
+	defer srv.Close()
+
+	p := withResilience(NewOpenAIProvider("openai", srv.URL, "", testClients()),
+		RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: time.Second},
+		NewCircuitBreaker("openai", 5, time.Minute))
+	p.sleep = noSleep
+
+	err := p.CompleteStream(context.Background(), &models.LLMRequest{Model: "m"}, func(models.LLMResponse) error { return nil })
+	if err == nil {
+		t.Fatal("expected stream error after dropped connection")
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("expected no retry after a forwarded chunk, got %d calls", calls.Load())
+	}
+}
+
+func TestCircuitBreakerOpensAndRecovers(t *testing.T) {
+	now := time.Now()
+	b := NewCircuitBreaker("test", 2, 10*time.Second)
+	b.now = func() time.Time { return now }
+
+	b.Failure()
+	if b.State() != BreakerClosed {
+		t.Fatalf("expected closed after one failure, got %s", b.State())
+	}
+	b.Failure()
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected open breaker to reject, got %v", err)
+	}
+
+	now = now.Add(11 * time.Second)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("expected half-open probe to be allowed, got %v", err)
+	}
+	if err := b.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatal("expected only one concurrent half-open probe")
+	}
+	b.Success()
+	if b.State() != BreakerClosed {
+		t.Fatalf("expected closed after successful probe, got %s", b.State())
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	for attempt := 0; attempt < 6; attempt++ {
+		d, ok := p.backoff(attempt, 0)
+		if !ok || d <= 0 || d > time.Second {
+			t.Fatalf("attempt %d: delay %v out of range", attempt, d)
+		}
+	}
+	if d, ok := p.backoff(0, 500*time.Millisecond); !ok || d != 500*time.Millisecond {
+		t.Fatalf("expected Retry-After to be honoured, got %v", d)
+	}
+	if _, ok := p.backoff(0, 30*time.Second); ok {
+		t.Fatal("expected retry to be skipped when Retry-After exceeds max delay")
+	}
+}
@@ -0,0 +1,166 @@
+// Structured JSON output — response_format handling, validation and re-prompting.
+// Maps to design.swift: Text LLM Inference Node
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/prakyathpnayak/roognis/internal/models"
+)
+
+// ErrInvalidResponseFormat is returned for a malformed response_format.
+var ErrInvalidResponseFormat = errors.New("invalid response_format")
+
+// SchemaValidationError is returned when the model's output is still not
+// valid JSON for the requested format after every re-prompt.
+type SchemaValidationError struct {
+	Attempts int
+	Errors   []string // violations found in the last attempt
+	Content  string   // last model output
+}
+
+func (e *SchemaValidationError) Error() string {
+	return fmt.Sprintf("llm: output does not match response_format after %d attempts: %s",
+		e.Attempts, strings.Join(e.Errors, "; "))
+}
+
+// CheckResponseFormat validates a client-supplied response_format. A nil
+// format or type "text" is accepted.
+func CheckResponseFormat(rf *models.ResponseFormat) error {
+	if rf == nil {
+		return nil
+	}
+	switch rf.Type {
+	case models.ResponseFormatText, models.ResponseFormatJSONObject:
+		return nil
+	case models.ResponseFormatJSONSchema:
+		if rf.JSONSchema == nil || len(rf.JSONSchema.Schema) == 0 {
+			return fmt.Errorf("%w: json_schema.schema is required", ErrInvalidResponseFormat)
+		}
+		var schema map[string]any
+		if err := json.Unmarshal(rf.JSONSchema.Schema, &schema); err != nil {
+			return fmt.Errorf("%w: json_schema.schema must be a JSON object", ErrInvalidResponseFormat)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: unsupported type %q", ErrInvalidResponseFormat, rf.Type)
+	}
+}
+
+// structuredFormat returns rf when it asks for JSON output, otherwise nil.
+func structuredFormat(rf *models.ResponseFormat) *models.ResponseFormat {
+	if rf == nil || rf.Type == "" || rf.Type == models.ResponseFormatText {
+		return nil
+	}
+	return rf
+}
+
+// applyResponseFormat appends an instruction describing the expected JSON
+// after the leading system messages. Providers without a native JSON mode
+// rely on it, and OpenAI's json_object mode requires the prompt to mention
+// JSON. Being part of the messages, it is also part of the cache key.
+func applyResponseFormat(messages []models.LLMMessage, rf *models.ResponseFormat) []models.LLMMessage {
+	rf = structuredFormat(rf)
+	if rf == nil {
+		return messages
+	}
+
+	instruction := "Respond with a single JSON object only, without any prose or code fences."
+	if rf.Type == models.ResponseFormatJSONSchema && rf.JSONSchema != nil {
+		instruction += "\nThe JSON must conform to this JSON Schema:\n" + string(rf.JSONSchema.Schema)
+	}
+
+	return insertAfterSystem(messages, models.LLMMessage{Role: "system", Content: instruction})
+}
+
+// checkStructuredOutput parses content and validates it against rf. It
+// returns the JSON with surrounding whitespace and code fences removed.
+func checkStructuredOutput(content string, rf *models.ResponseFormat) (string, []string) {
+	content = stripCodeFence(content)
+
+	var v any
+	if err := json.Unmarshal([]byte(content), &v); err != nil {
+		return content, []string{"output is not valid JSON: " + err.Error()}
+	}
+	if _, ok := v.(map[string]any); !ok {
+		return content, []string{"output must be a JSON object, got " + jsonType(v)}
+	}
+
+	if rf.Type == models.ResponseFormatJSONSchema && rf.JSONSchema != nil {
+		errs, err := ValidateJSONSchema(rf.JSONSchema.Schema, v)
+		if err != nil {
+			return content, []string{err.Error()}
+		}
+		return content, errs
+	}
+	return content, nil
+}
+
+func stripCodeFence(s string) string {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "```") {
+		return s
+	}
+	s = strings.TrimPrefix(s, "```")
+	if nl := strings.IndexByte(s, '\n'); nl >= 0 {
+		s = s[nl+1:] // drop the language tag, e.g. ```json
+	}
+	s = strings.TrimSuffix(strings.TrimSpace(s), "```")
+	return strings.TrimSpace(s)
+}
+
+// completeStructured calls provider and validates the answer against
+// req.ResponseFormat. Invalid output is sent back to the model together with
+// the violations, up to LLM_JSON_MAX_RETRIES times. Responses that request
+// tool calls are returned unvalidated; the final answer is checked later.
+func (l *LLM) completeStructured(ctx context.Context, provider LLMProvider, req *models.LLMRequest) (*models.LLMResponse, error) {
+	var usage models.LLMUsage
+	messages := slices.Clip(req.Messages)
+
+	for attempt := 1; ; attempt++ {
+		attemptReq := *req
+		attemptReq.Messages = messages
+
+		resp, err := provider.Complete(ctx, &attemptReq)
+		if err != nil {
+			return nil, err
+		}
+		usage.PromptTokens += resp.Usage.PromptTokens
+		usage.CompletionTokens += resp.Usage.CompletionTokens
+		usage.TotalTokens += resp.Usage.TotalTokens
+		resp.Usage = usage
+
+		if len(resp.Choices) == 0 || len(resp.Choices[0].Message.ToolCalls) > 0 {
+			return resp, nil
+		}
+
+		content, violations := checkStructuredOutput(resp.Choices[0].Message.Content, req.ResponseFormat)
+		if len(violations) == 0 {
+			resp.Choices[0].Message.Content = content
+			return resp, nil
+		}
+
+		slog.Warn("llm.structured_output_invalid",
+			"provider", provider.Name(),
+			"model", req.Model,
+			"attempt", attempt,
+			"violations", len(violations),
+		)
+		if attempt > l.cfg.LLMJSONMaxRetries {
+			return nil, &SchemaValidationError{Attempts: attempt, Errors: violations, Content: content}
+		}
+
+		messages = append(messages,
+			models.LLMMessage{Role: "assistant", Content: resp.Choices[0].Message.Content},
+			models.LLMMessage{Role: "user", Content: "Your previous reply did not match the required JSON format:\n- " +
+				strings.Join(violations, "\n- ") +
+				"\nReply again with only the corrected JSON object."},
+		)
+	}
+}
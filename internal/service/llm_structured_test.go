@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/prakyathpnayak/roognis/internal/config"
+	"github.com/prakyathpnayak/roognis/internal/models"
+)
+
+var quizFormat = &models.ResponseFormat{
+	Type: models.ResponseFormatJSONSchema,
+	JSONSchema: &models.JSONSchemaFormat{
+		Name:   "quiz",
+		Schema: json.RawMessage(`{"type":"object","properties":{"answer":{"type":"integer"}},"required":["answer"]}`),
+	},
+}
+
+// newStructuredTestLLM serves replies in order and records each request.
+func newStructuredTestLLM(t *testing.T, maxRetries int, replies ...string) (*LLM, *[]models.LLMRequest) {
+	t.Helper()
+	var calls atomic.Int32
+	var seen []models.LLMRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req models.LLMRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		seen = append(seen, req)
+		reply, _ := json.Marshal(replies[int(calls.Add(1))-1])
+		fmt.Fprintf(w, `{"model":"m","choices":[{"message":{"role":"assistant","content":%s}}],"usage":{"total_tokens":10}}`, reply)
+	}))
+	t.Cleanup(srv.Close)
+
+	reg := NewProviderRegistry("openai")
+	reg.Register(NewOpenAIProvider("openai", srv.URL, "", testClients()))
+	return &LLM{cfg: &config.Config{LLMModel: "m", LLMJSONMaxRetries: maxRetries}, providers: reg}, &seen
+}
+
+func TestCompleteStructuredRepromptsWithViolations(t *testing.T) {
+	llm, seen := newStructuredTestLLM(t, 2, `{"answer":"four"}`, "```json\n{\"answer\": 4}\n```")
+
+	resp, err := llm.Complete(context.Background(), []models.LLMMessage{{Role: "user", Content: "2+2?"}}, WithResponseFormat(quizFormat))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := resp.Choices[0].Message.Content; got != `{"answer": 4}` {
+		t.Fatalf("expected fence-stripped JSON, got %q", got)
+	}
+	if resp.Usage.TotalTokens != 20 {
+		t.Fatalf("expected usage summed over attempts, got %d", resp.Usage.TotalTokens)
+	}
+
+	if len(*seen) != 2 {
+		t.Fatalf("expected 2 upstream calls, got %d", len(*seen))
+	}
+	first, second := (*seen)[0], (*seen)[1]
+	if first.ResponseFormat == nil || first.ResponseFormat.JSONSchema.Name != "quiz" {
+		t.Fatalf("response_format not forwarded: %+v", first.ResponseFormat)
+	}
+	if first.Messages[0].Role != "system" || !strings.Contains(first.Messages[0].Content, "JSON Schema") {
+		t.Fatalf("expected format instruction, got %+v", first.Messages[0])
+	}
+	last := second.Messages[len(second.Messages)-1]
+	if last.Role != "user" || !strings.Contains(last.Content, "$.answer: expected integer") {
+		t.Fatalf("expected re-prompt with violations, got %+v", last)
+	}
+}
+
+func TestCompleteStructuredFailsWithTypedError(t *testing.T) {
+	llm, seen := newStructuredTestLLM(t, 1, "not json", `{"wrong":true}`)
+
+	_, err := llm.Complete(context.Background(), []models.LLMMessage{{Role: "user", Content: "2+2?"}}, WithResponseFormat(quizFormat))
+	var schemaErr *SchemaValidationError
+	if !errors.As(err, &schemaErr) {
+		t.Fatalf("expected SchemaValidationError, got %v", err)
+	}
+	if schemaErr.Attempts != 2 || len(*seen) != 2 {
+		t.Fatalf("expected 2 attempts, got %d (%d calls)", schemaErr.Attempts, len(*seen))
+	}
+	if !strings.Contains(strings.Join(schemaErr.Errors, ";"), `missing required property "answer"`) {
+		t.Fatalf("unexpected violations: %v", schemaErr.Errors)
+	}
+}
+
+func TestCheckResponseFormat(t *testing.T) {
+	valid := []*models.ResponseFormat{nil, {Type: "text"}, {Type: "json_object"}, quizFormat}
+	for _, rf := range valid {
+		if err := CheckResponseFormat(rf); err != nil {
+			t.Fatalf("CheckResponseFormat(%+v): %v", rf, err)
+		}
+	}
+
+	invalid := []*models.ResponseFormat{
+		{Type: "xml"},
+		{Type: "json_schema"},
+		{Type: "json_schema", JSONSchema: &models.JSONSchemaFormat{Schema: json.RawMessage(`[1]`)}},
+	}
+	for _, rf := range invalid {
+		if err := CheckResponseFormat(rf); !errors.Is(err, ErrInvalidResponseFormat) {
+			t.Fatalf("CheckResponseFormat(%+v): expected ErrInvalidResponseFormat, got %v", rf, err)
+		}
+	}
+}
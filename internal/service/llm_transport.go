@@ -0,0 +1,50 @@
+// Pooled HTTP transport for upstream LLM calls.
+// Maps to design.swift: Text LLM Inference Node (connection management)
+package service
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prakyathpnayak/roognis/internal/config"
+)
+
+// HTTPClients are the two clients every provider uses. Both share a single
+// tuned transport so connections to an upstream are pooled and reused across
+// streaming and non-streaming requests.
+type HTTPClients struct {
+	// Unary is bounded by LLM_TIMEOUT_SECONDS end to end.
+	Unary *http.Client
+	// Stream has no overall timeout; the request context handles
+	// cancellation and the transport bounds the wait for response headers.
+	Stream *http.Client
+}
+
+// NewLLMTransport builds the shared transport from cfg. HTTP/2 is negotiated
+// via ALPN when the upstream supports it.
+func NewLLMTransport(cfg *config.Config) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   cfg.LLMDialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          cfg.LLMMaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.LLMMaxIdleConnsPerHost,
+		IdleConnTimeout:       cfg.LLMIdleConnTimeout,
+		TLSHandshakeTimeout:   cfg.LLMTLSHandshakeTimeout,
+		ResponseHeaderTimeout: cfg.LLMResponseHeaderTimeout,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+// NewHTTPClients creates the unary and streaming clients over transport.
+func NewHTTPClients(transport http.RoundTripper, timeout time.Duration) HTTPClients {
+	return HTTPClients{
+		Unary:  &http.Client{Transport: transport, Timeout: timeout},
+		Stream: &http.Client{Transport: transport},
+	}
+}
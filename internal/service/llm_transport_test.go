@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prakyathpnayak/roognis/internal/config"
+	"github.com/prakyathpnayak/roognis/internal/models"
+)
+
+func testClients() HTTPClients {
+	return NewHTTPClients(NewLLMTransport(&config.Config{
+		LLMMaxIdleConns:          10,
+		LLMMaxIdleConnsPerHost:   10,
+		LLMIdleConnTimeout:       time.Minute,
+		LLMDialTimeout:           time.Second,
+		LLMTLSHandshakeTimeout:   time.Second,
+		LLMResponseHeaderTimeout: time.Second,
+	}), time.Second)
+}
+
+// newFakeStreamUpstream serves a short OpenAI-style SSE stream (or a JSON
+// completion when stream=false) and counts the TCP connections it accepts.
+func newFakeStreamUpstream(tb testing.TB) (*httptest.Server, *atomic.Int64) {
+	tb.Helper()
+	var conns atomic.Int64
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req models.LLMRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if !req.Stream {
+			fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"abc"}}]}`)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, tok := range []string{"a", "b", "c"} {
+			fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":%q}}]}\n\n", tok)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.Start()
+	tb.Cleanup(srv.Close)
+	return srv, &conns
+}
+
+func drainStream(tb testing.TB, p LLMProvider) {
+	tb.Helper()
+	err := p.CompleteStream(context.Background(), &models.LLMRequest{Model: "m"}, func(models.LLMResponse) error { return nil })
+	if err != nil {
+		tb.Fatalf("unexpected stream error: %v", err)
+	}
+}
+
+func TestSharedTransportReusesConnections(t *testing.T) {
+	srv, conns := newFakeStreamUpstream(t)
+	p := NewOpenAIProvider("openai", srv.URL, "", testClients())
+
+	for i := 0; i < 10; i++ {
+		drainStream(t, p)
+	}
+	if _, err := p.Complete(context.Background(), &models.LLMRequest{Model: "m"}); err != nil {
+		t.Fatalf("unexpected complete error: %v", err)
+	}
+
+	if got := conns.Load(); got != 1 {
+		t.Fatalf("expected streaming and unary calls to share 1 connection, got %d", got)
+	}
+}
+
+// BenchmarkCompleteStreamSharedTransport measures streams over the pooled
+// transport; new_conns/op should approach 0.
+func BenchmarkCompleteStreamSharedTransport(b *testing.B) {
+	srv, conns := newFakeStreamUpstream(b)
+	p := NewOpenAIProvider("openai", srv.URL, "", testClients())
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		drainStream(b, p)
+	}
+	b.ReportMetric(float64(conns.Load())/float64(b.N), "new_conns/op")
+}
+
+// BenchmarkCompleteStreamFreshTransport reproduces the previous behaviour of
+// building a transport per stream; new_conns/op stays at 1.
+func BenchmarkCompleteStreamFreshTransport(b *testing.B) {
+	srv, conns := newFakeStreamUpstream(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		transport := &http.Transport{ResponseHeaderTimeout: 30 * time.Second}
+		p := NewOpenAIProvider("openai", srv.URL, "", NewHTTPClients(transport, time.Second))
+		drainStream(b, p)
+		transport.CloseIdleConnections()
+	}
+	b.ReportMetric(float64(conns.Load())/float64(b.N), "new_conns/op")
+}
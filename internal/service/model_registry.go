@@ -0,0 +1,148 @@
+// Model routing table — allow-list, per-role access, defaults and fallbacks.
+// Maps to design.swift: Request Router (model selection)
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"slices"
+
+	"github.com/prakyathpnayak/roognis/internal/config"
+	"github.com/prakyathpnayak/roognis/internal/models"
+)
+
+// ErrModelNotAllowed is returned when the requested model is not declared or
+// the caller's role may not use it.
+var ErrModelNotAllowed = errors.New("model not allowed")
+
+// ModelRegistry holds the declared models in table order.
+type ModelRegistry struct {
+	models       []config.ModelConfig
+	byName       map[string]int
+	defaultModel string
+}
+
+// NewModelRegistry builds the registry from MODELS_CONFIG_PATH, or a single
+// entry for LLM_MODEL when no table is configured. Models that declare a
+// provider are pinned to it in providers.
+func NewModelRegistry(cfg *config.Config, providers *ProviderRegistry) (*ModelRegistry, error) {
+	table := &config.ModelTable{
+		Default: cfg.LLMModel,
+		Models:  []config.ModelConfig{{Name: cfg.LLMModel, ContextWindow: cfg.LLMContextWindow}},
+	}
+	if cfg.ModelsConfigPath != "" {
+		loaded, err := config.LoadModelTable(cfg.ModelsConfigPath)
+		if err != nil {
+			return nil, err
+		}
+		table = loaded
+	}
+
+	r := &ModelRegistry{
+		models:       table.Models,
+		byName:       make(map[string]int, len(table.Models)),
+		defaultModel: table.Default,
+	}
+	for i := range r.models {
+		m := &r.models[i]
+		if m.ContextWindow <= 0 {
+			m.ContextWindow = cfg.LLMContextWindow
+		}
+		r.byName[m.Name] = i
+		if m.Provider != "" && providers != nil {
+			providers.Pin(m.Name, m.Provider)
+		}
+	}
+
+	slog.Info("models.registry_loaded", "count", len(r.models), "default", r.defaultModel)
+	return r, nil
+}
+
+// Default returns the name of the default model.
+func (r *ModelRegistry) Default() string {
+	return r.defaultModel
+}
+
+// Get returns the declared model by name.
+func (r *ModelRegistry) Get(name string) (config.ModelConfig, bool) {
+	i, ok := r.byName[name]
+	if !ok {
+		return config.ModelConfig{}, false
+	}
+	return r.models[i], true
+}
+
+// Resolve returns the model to use for a request. An empty name selects the
+// default model.
+func (r *ModelRegistry) Resolve(name string, role models.UserRole) (config.ModelConfig, error) {
+	if name == "" {
+		name = r.defaultModel
+	}
+	m, ok := r.Get(name)
+	if !ok || !roleAllowed(m, role) {
+		return config.ModelConfig{}, fmt.Errorf("%w: %q", ErrModelNotAllowed, name)
+	}
+	return m, nil
+}
+
+// Chain returns the primary model followed by its fallbacks that role may use.
+func (r *ModelRegistry) Chain(primary config.ModelConfig, role models.UserRole) []config.ModelConfig {
+	chain := []config.ModelConfig{primary}
+	for _, name := range primary.Fallbacks {
+		m, ok := r.Get(name)
+		if !ok || !roleAllowed(m, role) {
+			slog.Debug("models.fallback_skipped", "model", primary.Name, "fallback", name, "role", role)
+			continue
+		}
+		chain = append(chain, m)
+	}
+	return chain
+}
+
+// Allowed lists the models role may use, in table order.
+func (r *ModelRegistry) Allowed(role models.UserRole) []models.ModelInfo {
+	out := make([]models.ModelInfo, 0, len(r.models))
+	for _, m := range r.models {
+		if !roleAllowed(m, role) {
+			continue
+		}
+		out = append(out, models.ModelInfo{
+			ID:            m.Name,
+			Description:   m.Description,
+			ContextWindow: m.ContextWindow,
+			Temperature:   m.Temperature,
+			MaxTokens:     m.MaxTokens,
+			Default:       m.Name == r.defaultModel,
+		})
+	}
+	return out
+}
+
+func roleAllowed(m config.ModelConfig, role models.UserRole) bool {
+	return len(m.Roles) == 0 || slices.Contains(m.Roles, string(role))
+}
+
+// shouldFallback reports whether err from an upstream call warrants trying
+// the next model in the chain: a 5xx status, a timeout or an open circuit
+// breaker. Cancellation of the caller's own context never falls back.
+func shouldFallback(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+
+	if errors.Is(err, ErrCircuitOpen) {
+		return true
+	}
+	var upErr *UpstreamError
+	if errors.As(err, &upErr) {
+		return upErr.StatusCode >= 500
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prakyathpnayak/roognis/internal/config"
+	"github.com/prakyathpnayak/roognis/internal/models"
+)
+
+func newTestModelRegistry(t *testing.T, table string) *ModelRegistry {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "models.json")
+	if err := os.WriteFile(path, []byte(table), 0o600); err != nil {
+		t.Fatalf("write table: %v", err)
+	}
+	reg, err := NewModelRegistry(&config.Config{ModelsConfigPath: path, LLMContextWindow: 4096}, NewProviderRegistry("openai"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return reg
+}
+
+const testModelTable = `{
+	"default": "small",
+	"models": [
+		{"name": "small", "context_window": 8192, "fallbacks": ["large", "backup"]},
+		{"name": "large", "roles": ["teacher", "admin"]},
+		{"name": "backup"}
+	]
+}`
+
+func TestModelRegistryResolveEnforcesRoles(t *testing.T) {
+	reg := newTestModelRegistry(t, testModelTable)
+
+	m, err := reg.Resolve("", models.RoleStudent)
+	if err != nil || m.Name != "small" {
+		t.Fatalf("expected default model for empty name, got %q (%v)", m.Name, err)
+	}
+	if _, err := reg.Resolve("large", models.RoleStudent); !errors.Is(err, ErrModelNotAllowed) {
+		t.Fatalf("expected ErrModelNotAllowed for student on large, got %v", err)
+	}
+	if _, err := reg.Resolve("large", models.RoleTeacher); err != nil {
+		t.Fatalf("expected teacher to use large, got %v", err)
+	}
+	if _, err := reg.Resolve("gpt-4-32k", models.RoleAdmin); !errors.Is(err, ErrModelNotAllowed) {
+		t.Fatalf("expected ErrModelNotAllowed for undeclared model, got %v", err)
+	}
+}
+
+func TestModelRegistryChainSkipsFallbacksRoleCannotUse(t *testing.T) {
+	reg := newTestModelRegistry(t, testModelTable)
+	primary, _ := reg.Get("small")
+
+	var names []string
+	for _, m := range reg.Chain(primary, models.RoleStudent) {
+		names = append(names, m.Name)
+	}
+	if fmt.Sprint(names) != "[small backup]" {
+		t.Fatalf("unexpected student chain: %v", names)
+	}
+	if got := len(reg.Chain(primary, models.RoleAdmin)); got != 3 {
+		t.Fatalf("expected full chain for admin, got %d models", got)
+	}
+
+	backup, _ := reg.Get("backup")
+	if backup.ContextWindow != 4096 {
+		t.Fatalf("expected context window to default from config, got %d", backup.ContextWindow)
+	}
+}
+
+func TestShouldFallback(t *testing.T) {
+	ctx := context.Background()
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	cases := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want bool
+	}{
+		{"5xx", ctx, &UpstreamError{StatusCode: 502}, true},
+		{"4xx", ctx, &UpstreamError{StatusCode: 400}, false},
+		{"timeout", ctx, fmt.Errorf("llm: %w", context.DeadlineExceeded), true},
+		{"caller cancelled", cancelled, &UpstreamError{StatusCode: 503}, false},
+	}
+	for _, tc := range cases {
+		if got := shouldFallback(tc.ctx, tc.err); got != tc.want {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, got)
+		}
+	}
+}
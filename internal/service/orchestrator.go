@@ -4,7 +4,8 @@
 //	→ LLM Inference Node → Token Streamer (SSE)
 //
 // Coordinates: conversation management, message history, cache lookup,
-// RAG context injection, LLM invocation, cache write-back, and persistence.
+// RAG context injection, LLM invocation (including the model → tool → model
+// loop), cache write-back, and persistence.
 package service
 
 import (
@@ -12,11 +13,15 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/google/uuid"
+	"github.com/prakyathpnayak/roognis/internal/config"
 	"github.com/prakyathpnayak/roognis/internal/db"
 	"github.com/prakyathpnayak/roognis/internal/models"
+	"golang.org/x/sync/singleflight"
 )
 
 var (
@@ -26,178 +31,902 @@ var (
 
 // Orchestrator is the inference pipeline conductor.
 type Orchestrator struct {
-	llm    *LLM
-	cache  *Cache
-	ctxInj *ContextInjector
-	pool   *db.Pool
+	llm     *LLM
+	models  *ModelRegistry
+	tools   *ToolRegistry
+	tokens  *Tokenizers
+	cache   *Cache
+	similar *SimilarityCache // nil when the similarity tier is off
+	policy  *CachePolicy     // nil = cache every request
+	ctxInj  *ContextInjector
+	sum     *Summarizer
+	titles  *Titler
+	pool    *db.Pool
+	flights singleflight.Group // generations in progress, by cache key
 }
 
 // NewOrchestrator creates a new orchestrator wiring together the pipeline stages.
-func NewOrchestrator(llm *LLM, cache *Cache, ctxInj *ContextInjector, pool *db.Pool) *Orchestrator {
+func NewOrchestrator(llm *LLM, registry *ModelRegistry, tools *ToolRegistry, tokens *Tokenizers, cache *Cache, similar *SimilarityCache, policy *CachePolicy, ctxInj *ContextInjector, sum *Summarizer, titles *Titler, pool *db.Pool) *Orchestrator {
 	return &Orchestrator{
-		llm:    llm,
-		cache:  cache,
-		ctxInj: ctxInj,
-		pool:   pool,
+		llm:     llm,
+		models:  registry,
+		tools:   tools,
+		tokens:  tokens,
+		cache:   cache,
+		similar: similar,
+		policy:  policy,
+		ctxInj:  ctxInj,
+		sum:     sum,
+		titles:  titles,
+		pool:    pool,
 	}
 }
 
 // Complete runs the full non-streaming inference pipeline.
-func (o *Orchestrator) Complete(ctx context.Context, req *models.InferenceRequest, userID uuid.UUID) (*models.InferenceResponse, error) {
+func (o *Orchestrator) Complete(ctx context.Context, req *models.InferenceRequest, user *models.User) (*models.InferenceResponse, error) {
 	start := time.Now()
 
-	// 1. Resolve or create conversation
-	conversationID, err := o.resolveConversation(ctx, req.ConversationID, userID)
+	// 1. Resolve model against the allow-list
+	primary, err := o.models.Resolve(req.Model, user.Role)
 	if err != nil {
 		return nil, err
 	}
 
-	// 2. Build message history
-	messages, err := o.buildMessages(ctx, conversationID, req.Prompt)
+	// 2. Resolve or create conversation
+	conv, err := o.resolveConversation(ctx, req.ConversationID, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	conversationID := conv.ID
+	t, err := o.resolveTurn(ctx, conv, req)
 	if err != nil {
 		return nil, err
 	}
 
-	// 3. Inject RAG context
-	messages = o.ctxInj.Inject(ctx, messages)
+	// 3. Build message history, inject RAG context and fit the context window
+	temp, maxTok := o.requestParams(req, primary)
+	messages, budget, err := o.buildMessages(ctx, conversationID, t.history, t.prompt, primary, maxTok)
+	if err != nil {
+		return nil, err
+	}
 
-	// 4. Check cache
-	model := req.Model
-	if model == "" {
-		model = o.llm.cfg.LLMModel
+	// generate calls the LLM, walking the fallback chain on 5xx / timeout
+	// and running requested tools until the model gives a final answer,
+	// and caches the answer unless the policy says not to. When shared it
+	// runs detached from the request that started it, since other requests
+	// may be waiting for it.
+	cacheKey, cacheTTL := o.cacheKey(config.CacheRouteComplete, messages, req, t, primary, user, temp, maxTok)
+	similar := o.similar.Query(messages, req.ResponseFormat, primary.Name, user.ID, temp, maxTok)
+	generate := func(ctx context.Context) (*sharedAnswer, error) {
+		llmResp, transcript, toolEvents, err := o.completeWithTools(ctx, req, user, conversationID, primary, messages)
+		if err != nil {
+			return nil, fmt.Errorf("orchestrator: llm: %w", err)
+		}
+		if len(llmResp.Choices) == 0 {
+			return nil, fmt.Errorf("orchestrator: llm returned no choices")
+		}
+		totalTokens := llmResp.Usage.TotalTokens
+		resp := &models.InferenceResponse{
+			ID:             uuid.New(),
+			ConversationID: conversationID,
+			UserMessageID:  &t.userMsgID,
+			Content:        llmResp.Choices[0].Message.Content,
+			Model:          llmResp.Model,
+			TokenCount:     &totalTokens,
+			LatencyMs:      float64(time.Since(start).Milliseconds()),
+			Cached:         false,
+			ToolEvents:     toolEvents,
+			Context:        &budget,
+		}
+		if cacheKey != "" {
+			if err := o.cache.SetJSONTTL(ctx, cacheKey, resp, cacheTTL); err != nil {
+				slog.Warn("orchestrator.cache_set_error", "error", err)
+			} else if len(toolEvents) == 0 {
+				o.similar.Store(ctx, similar, cacheKey, cacheTTL)
+			}
+		}
+		return &sharedAnswer{turn: t, resp: resp, llmResp: llmResp, transcript: transcript}, nil
 	}
-	temp := o.llm.cfg.LLMTemperature
-	if req.Temperature != nil {
-		temp = *req.Temperature
+
+	// 4. Check cache, exact context first, then similar single-turn
+	// prompts. A hit is stored as the turn's answer like any other. An
+	// expired answer is still served while it is refreshed in the
+	// background.
+	if cachedResp, stale := o.cachedResponse(ctx, cacheKey, similar, req); cachedResp != nil {
+		slog.Info("orchestrator.cache_hit", "conversation_id", conversationID, "stale", stale)
+		if stale {
+			o.revalidate(ctx, cacheKey, generate)
+		}
+		return o.completeCached(ctx, conv, t, cachedResp, budget, start), nil
 	}
-	maxTok := o.llm.cfg.LLMMaxTokens
-	if req.MaxTokens != nil {
-		maxTok = *req.MaxTokens
+
+	// 5. Generate the answer. Identical requests share one generation: on
+	// this instance by joining it, across instances by waiting for the one
+	// holding the lock. Requests that joined another's generation get its
+	// answer like a cache hit. A regenerate asks for a new answer, so it
+	// always gets its own, as do requests the policy does not cache.
+	var g *sharedAnswer
+	if req.RegenerateOf != nil || cacheKey == "" {
+		g, err = generate(ctx)
+	} else {
+		g, err = o.generateOnce(ctx, cacheKey, false, generate)
 	}
-	cacheKey, hashErr := SemanticContextHash(messages, model, userID.String(), temp, maxTok)
-	if hashErr != nil {
-		slog.Warn("orchestrator.cache_hash_error", "error", hashErr)
-		cacheKey = SemanticHash(req.Prompt, model, userID.String(), temp, maxTok)
+	if err != nil {
+		return nil, err
+	}
+	if g.turn != t {
+		slog.Info("orchestrator.cache_coalesced", "conversation_id", conversationID)
+		cachedResp := *g.resp
+		return o.completeCached(ctx, conv, t, &cachedResp, budget, start), nil
 	}
+	resp, llmResp := g.resp, g.llmResp
+
+	// 6. Persist user, tool round trips and assistant messages
+	o.persistMessages(ctx, conversationID, t, g.transcript, &turnOutcome{
+		assistantID:  resp.ID,
+		content:      resp.Content,
+		model:        llmResp.Model,
+		status:       models.MessageStatusComplete,
+		finishReason: finishReason(llmResp),
+		usage:        llmResp.Usage,
+		latencyMs:    resp.LatencyMs,
+	})
+	o.sum.Enqueue(conversationID)
+	o.titles.Schedule(conv, t.prompt, resp.Content)
+
+	return resp, nil
+}
+
+// completeCached answers turn t of conv with an answer that was cached or
+// generated for another request, storing it as the turn's answer.
+func (o *Orchestrator) completeCached(ctx context.Context, conv *models.Conversation, t *turn, cachedResp *models.InferenceResponse, budget models.ContextBudget, start time.Time) *models.InferenceResponse {
+	cachedResp.ID = uuid.New()
+	cachedResp.UserMessageID = &t.userMsgID
+	cachedResp.Cached = true
+	cachedResp.ConversationID = conv.ID
+	cachedResp.LatencyMs = float64(time.Since(start).Milliseconds())
+	cachedResp.Context = &budget
+
+	o.persistMessages(ctx, conv.ID, t, nil, &turnOutcome{
+		assistantID:  cachedResp.ID,
+		content:      cachedResp.Content,
+		model:        cachedResp.Model,
+		status:       models.MessageStatusComplete,
+		finishReason: "stop",
+		latencyMs:    cachedResp.LatencyMs,
+	})
+	o.sum.Enqueue(conv.ID)
+	o.titles.Schedule(conv, t.prompt, cachedResp.Content)
+	return cachedResp
+}
+
+// sharedAnswer is an answer generated and cached for a cache key, shared by
+// the requests that asked for it at the same time.
+type sharedAnswer struct {
+	turn       *turn // the turn it was generated for; nil if another instance did
+	resp       *models.InferenceResponse
+	llmResp    *models.LLMResponse
+	transcript []models.LLMMessage
+}
 
-	var cachedResp models.InferenceResponse
-	if found, _ := o.cache.GetJSON(ctx, cacheKey, &cachedResp); found {
-		slog.Info("orchestrator.cache_hit", "conversation_id", conversationID)
-		cachedResp.Cached = true
-		cachedResp.ConversationID = conversationID
-		cachedResp.LatencyMs = float64(time.Since(start).Milliseconds())
-		return &cachedResp, nil
+// generateOnce runs fn for cacheKey unless it is already being generated.
+// Callers on this instance join a generation in progress. Across instances
+// a Redis lock picks one to run fn; the others wait for its answer to be
+// cached, and run fn themselves if it does not arrive in time. With
+// refresh, fn is skipped instead while another instance holds the lock,
+// and the result is nil.
+//
+// fn runs detached from ctx; a caller that goes away stops waiting but the
+// generation completes for the others.
+func (o *Orchestrator) generateOnce(ctx context.Context, cacheKey string, refresh bool, fn func(context.Context) (*sharedAnswer, error)) (*sharedAnswer, error) {
+	flight := cacheKey
+	if refresh {
+		flight = "refresh:" + cacheKey
 	}
+	ch := o.flights.DoChan(flight, func() (any, error) {
+		ctx := context.WithoutCancel(ctx)
+		timeout := o.llm.cfg.CacheLockTimeout
+		release, ok, err := o.cache.Lock(ctx, cacheKey, timeout)
+		switch {
+		case err != nil:
+			slog.Warn("orchestrator.cache_lock_error", "error", err)
+		case ok:
+			defer release()
+		case refresh:
+			return (*sharedAnswer)(nil), nil
+		default:
+			var resp models.InferenceResponse
+			if o.cache.WaitJSON(ctx, cacheKey, &resp, timeout) {
+				return &sharedAnswer{resp: &resp}, nil
+			}
+			slog.Warn("orchestrator.cache_lock_timeout", "timeout", timeout)
+		}
+		return fn(ctx)
+	})
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(*sharedAnswer), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// revalidate regenerates the stale answer cached under cacheKey in the
+// background. Only one refresh of a key runs at a time across instances.
+func (o *Orchestrator) revalidate(ctx context.Context, cacheKey string, fn func(context.Context) (*sharedAnswer, error)) {
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		g, err := o.generateOnce(ctx, cacheKey, true, fn)
+		switch {
+		case err != nil:
+			slog.Warn("orchestrator.cache_refresh_error", "error", err)
+		case g != nil:
+			slog.Info("orchestrator.cache_refreshed")
+		}
+	}()
+}
+
+// StreamHandlers receives the output of StreamComplete.
+type StreamHandlers struct {
+	// OnChunk receives content chunks from the model.
+	OnChunk StreamCallback
+	// OnToolEvent, when set, is told about each tool call and its result.
+	OnToolEvent func(ev models.ToolEvent) error
+	// OnContext, when set, receives the context budget before the first chunk.
+	OnContext func(budget models.ContextBudget) error
+	// OnMeta, when set, receives the stream metadata before anything else.
+	OnMeta func(meta models.StreamMeta) error
+	// OnFinish, when set, is told how the answer ended once it is stored,
+	// including when the stream was cut short.
+	OnFinish func(done models.StreamDone, usage models.StreamUsage)
+}
+
+// StreamComplete runs the streaming inference pipeline. Once the model has
+// been called, the turn is persisted however the stream ends: completed,
+// cancelled by the client or failed upstream, with whatever was generated.
+func (o *Orchestrator) StreamComplete(ctx context.Context, req *models.InferenceRequest, user *models.User, h StreamHandlers) error {
+	start := time.Now()
 
-	// 5. Call LLM
-	var opts []RequestOption
-	if req.Model != "" {
-		opts = append(opts, WithModel(req.Model))
+	// 1. Resolve model against the allow-list
+	primary, err := o.models.Resolve(req.Model, user.Role)
+	if err != nil {
+		return err
 	}
-	if req.Temperature != nil {
-		opts = append(opts, WithTemperature(*req.Temperature))
+
+	// 2. Resolve or create conversation
+	conv, err := o.resolveConversation(ctx, req.ConversationID, user.ID)
+	if err != nil {
+		return err
 	}
-	if req.MaxTokens != nil {
-		opts = append(opts, WithMaxTokens(*req.MaxTokens))
+	conversationID := conv.ID
+	t, err := o.resolveTurn(ctx, conv, req)
+	if err != nil {
+		return err
 	}
 
-	llmResp, err := o.llm.Complete(ctx, messages, opts...)
+	// 3. Build message history, inject RAG context and fit the context window
+	temp, maxTok := o.requestParams(req, primary)
+	messages, budget, err := o.buildMessages(ctx, conversationID, t.history, t.prompt, primary, maxTok)
 	if err != nil {
-		return nil, fmt.Errorf("orchestrator: llm: %w", err)
+		return err
+	}
+
+	// 4. Replay a cached answer for the same context
+	out := &turnOutcome{assistantID: uuid.New(), model: primary.Name, status: models.MessageStatusComplete}
+	cacheKey, cacheTTL := o.cacheKey(config.CacheRouteStream, messages, req, t, primary, user, temp, maxTok)
+	similar := o.similar.Query(messages, req.ResponseFormat, primary.Name, user.ID, temp, maxTok)
+	// A stale answer is regenerated, which refreshes it.
+	cached, stale := o.cachedResponse(ctx, cacheKey, similar, req)
+	if stale {
+		cached = nil
+	}
+	if h.OnMeta != nil {
+		meta := models.StreamMeta{ConversationID: conversationID, UserMessageID: t.userMsgID, MessageID: out.assistantID, Model: primary.Name}
+		if cached != nil {
+			meta.Model, meta.Cached = cached.Model, true
+		}
+		if err := h.OnMeta(meta); err != nil {
+			return err
+		}
+	}
+	if h.OnContext != nil {
+		if err := h.OnContext(budget); err != nil {
+			return err
+		}
+	}
+	if cached != nil {
+		slog.Info("orchestrator.cache_hit", "conversation_id", conversationID, "stream", true)
+		return o.replayStream(ctx, conv, t, cached, out, h, start)
+	}
+
+	// 5. Stream from LLM, forwarding content chunks to the caller. When the
+	// model asks for tools, run them and stream the next round.
+	tok := o.tokens.For(primary)
+	onChunk := func(chunk models.LLMResponse) error {
+		if out.ttftMs == 0 && len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
+			out.ttftMs = float64(time.Since(start).Milliseconds())
+		}
+		return h.OnChunk(chunk)
 	}
 
-	if len(llmResp.Choices) == 0 {
-		return nil, fmt.Errorf("orchestrator: llm returned no choices")
+	var transcript []models.LLMMessage
+	for iter := 0; ; iter++ {
+		round, err := o.streamWithFallback(ctx, req, user.Role, primary, messages, iter < o.llm.cfg.LLMMaxToolIterations, onChunk)
+		out.add(round, tok, messages)
+		if err != nil {
+			out.fail(ctx, err)
+			o.finishStream(ctx, conv, t, transcript, out, h, start)
+			return fmt.Errorf("orchestrator: stream: %w", err)
+		}
+		if len(round.toolCalls) == 0 {
+			break
+		}
+
+		step, evs, err := o.runToolCalls(ctx, round.content, round.toolCalls, user.ID, conversationID, h.OnToolEvent)
+		if err != nil {
+			// Only the client going away fails a tool step; the answer so
+			// far is the content that came with the calls.
+			out.content = round.content
+			out.fail(ctx, err)
+			o.finishStream(ctx, conv, t, transcript, out, h, start)
+			return fmt.Errorf("orchestrator: stream: %w", err)
+		}
+		messages = append(messages, step...)
+		transcript = append(transcript, step...)
+		out.toolEvents = append(out.toolEvents, evs...)
+	}
+
+	// 6. Persist after stream completes, and cache the answer
+	o.finishStream(ctx, conv, t, transcript, out, h, start)
+	o.cacheStream(ctx, cacheKey, cacheTTL, similar, conversationID, t, out)
+	return nil
+}
+
+// replayStream sends a cached answer as a stream: the recorded tool events,
+// then the content in small deltas. The turn is persisted like a generated
+// one.
+func (o *Orchestrator) replayStream(ctx context.Context, conv *models.Conversation, t *turn, cached *models.InferenceResponse, out *turnOutcome, h StreamHandlers, start time.Time) error {
+	out.model = cached.Model
+	out.finishReason = "stop"
+
+	err := func() error {
+		if h.OnToolEvent != nil {
+			for _, ev := range cached.ToolEvents {
+				if err := h.OnToolEvent(ev); err != nil {
+					return err
+				}
+			}
+		}
+
+		deltas := replayChunks(cached.Content, replayChunkRunes)
+		if len(deltas) == 0 {
+			deltas = []string{""}
+		}
+		for i, delta := range deltas {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if out.ttftMs == 0 {
+				out.ttftMs = float64(time.Since(start).Milliseconds())
+			}
+			choice := models.LLMChoice{Delta: models.LLMMessage{Role: string(models.RoleAssistantMsg), Content: delta}}
+			if i == len(deltas)-1 {
+				choice.FinishReason = &out.finishReason
+			}
+			if err := h.OnChunk(models.LLMResponse{Model: cached.Model, Choices: []models.LLMChoice{choice}}); err != nil {
+				return &callbackError{err: err}
+			}
+			out.content += delta
+		}
+		return nil
+	}()
+	if err != nil {
+		out.fail(ctx, err)
+		o.finishStream(ctx, conv, t, nil, out, h, start)
+		return fmt.Errorf("orchestrator: replay: %w", err)
 	}
 
-	content := llmResp.Choices[0].Message.Content
-	latencyMs := float64(time.Since(start).Milliseconds())
-	totalTokens := llmResp.Usage.TotalTokens
+	o.finishStream(ctx, conv, t, nil, out, h, start)
+	return nil
+}
 
-	// 6. Build response
+// cacheStream caches a completed streamed answer in the shape Complete
+// caches, so either path can serve it.
+func (o *Orchestrator) cacheStream(ctx context.Context, cacheKey string, ttl time.Duration, similar *SimilarQuery, conversationID uuid.UUID, t *turn, out *turnOutcome) {
+	if cacheKey == "" || out.status != models.MessageStatusComplete {
+		return
+	}
 	resp := &models.InferenceResponse{
-		ID:             uuid.New(),
+		ID:             out.assistantID,
 		ConversationID: conversationID,
-		Content:        content,
-		Model:          llmResp.Model,
-		TokenCount:     &totalTokens,
-		LatencyMs:      latencyMs,
-		Cached:         false,
+		UserMessageID:  &t.userMsgID,
+		Content:        out.content,
+		Model:          out.model,
+		TokenCount:     positive(out.usage.TotalTokens),
+		LatencyMs:      out.latencyMs,
+		ToolEvents:     out.toolEvents,
+	}
+	if err := o.cache.SetJSONTTL(context.WithoutCancel(ctx), cacheKey, resp, ttl); err != nil {
+		slog.Warn("orchestrator.cache_set_error", "error", err, "stream", true)
+	} else if len(out.toolEvents) == 0 {
+		o.similar.Store(ctx, similar, cacheKey, ttl)
+	}
+}
+
+// cacheKey applies the cache policy for route and hashes the context sent to the
+// model; the prompt alone is the fallback when the messages cannot be
+// hashed. It returns the key and TTL to cache the answer under, or "" when
+// the policy does not cache the request.
+func (o *Orchestrator) cacheKey(route string, messages []models.LLMMessage, req *models.InferenceRequest, t *turn, m config.ModelConfig, user *models.User, temp float64, maxTok int) (string, time.Duration) {
+	d := o.policy.Decide(CacheRequest{
+		Route:       route,
+		Model:       m.Name,
+		Temperature: temp,
+		Role:        user.Role,
+		Prompt:      t.prompt,
+		Depth:       conversationDepth(messages),
+	})
+	if !d.Cache {
+		return "", 0
+	}
+	key, err := o.cache.ContextKey(d.Normalizer, applyResponseFormat(messages, req.ResponseFormat), m.Name, user.ID.String(), temp, maxTok)
+	if err != nil {
+		slog.Warn("orchestrator.cache_hash_error", "error", err)
+		return o.cache.PromptKey(d.Normalizer, t.prompt, m.Name, user.ID.String(), temp, maxTok), d.TTL
 	}
+	return key, d.TTL
+}
 
-	// 7. Cache response
-	if err := o.cache.SetJSON(ctx, cacheKey, resp); err != nil {
-		slog.Warn("orchestrator.cache_set_error", "error", err)
+// cachedResponse looks up a cached answer for the exact context, then for a
+// similar prompt when similar is set, and reports whether it is stale. A
+// regenerate asks for a different answer, so it never gets one, and
+// neither do requests the policy does not cache.
+func (o *Orchestrator) cachedResponse(ctx context.Context, cacheKey string, similar *SimilarQuery, req *models.InferenceRequest) (*models.InferenceResponse, bool) {
+	if req.RegenerateOf != nil || cacheKey == "" {
+		return nil, false
+	}
+	var resp models.InferenceResponse
+	if found, stale, _ := o.cache.GetStaleJSON(ctx, cacheKey, &resp); found {
+		return &resp, stale
 	}
+	return o.similar.Lookup(ctx, similar), false
+}
 
-	// 8. Persist user + assistant messages
-	o.persistMessages(ctx, conversationID, req.Prompt, content, llmResp.Model, totalTokens, latencyMs)
+// replayChunkRunes is the approximate size of a replayed delta.
+const replayChunkRunes = 24
 
-	return resp, nil
+// replayChunks splits content into deltas of about size runes, cutting after
+// whitespace where possible so words are not split.
+func replayChunks(content string, size int) []string {
+	var chunks []string
+	runes := []rune(content)
+	for len(runes) > 0 {
+		n := min(size, len(runes))
+		// Extend to the end of the current word, up to twice the size.
+		for n < len(runes) && n < 2*size && !unicode.IsSpace(runes[n-1]) {
+			n++
+		}
+		chunks = append(chunks, string(runes[:n]))
+		runes = runes[n:]
+	}
+	return chunks
 }
 
-// StreamComplete runs the streaming inference pipeline.
-func (o *Orchestrator) StreamComplete(ctx context.Context, req *models.InferenceRequest, userID uuid.UUID, cb StreamCallback) error {
-	// 1. Resolve or create conversation
-	conversationID, err := o.resolveConversation(ctx, req.ConversationID, userID)
-	if err != nil {
-		return err
+// finishStream persists a streamed turn and reports it to h.OnFinish. It
+// runs detached from ctx, which is already cancelled when the client has
+// gone away.
+func (o *Orchestrator) finishStream(ctx context.Context, conv *models.Conversation, t *turn, transcript []models.LLMMessage, out *turnOutcome, h StreamHandlers, start time.Time) {
+	ctx = context.WithoutCancel(ctx)
+	out.latencyMs = float64(time.Since(start).Milliseconds())
+
+	o.persistMessages(ctx, conv.ID, t, transcript, out)
+	slog.Info("orchestrator.stream_finished",
+		"conversation_id", conv.ID,
+		"model", out.model,
+		"status", out.status,
+		"finish_reason", out.finishReason,
+		"ttft_ms", out.ttftMs,
+		"latency_ms", out.latencyMs,
+		"prompt_tokens", out.usage.PromptTokens,
+		"completion_tokens", out.usage.CompletionTokens,
+	)
+	if h.OnFinish != nil {
+		h.OnFinish(out.done(conv.ID), out.streamUsage())
 	}
+	if out.status != models.MessageStatusComplete {
+		return
+	}
+	o.sum.Enqueue(conv.ID)
+	o.titles.Schedule(conv, t.prompt, out.content)
+}
 
-	// 2. Build message history
-	messages, err := o.buildMessages(ctx, conversationID, req.Prompt)
-	if err != nil {
-		return err
+// turnOutcome is the final assistant message of a turn and how it ended.
+type turnOutcome struct {
+	assistantID  uuid.UUID
+	content      string
+	model        string
+	status       models.MessageStatus
+	finishReason string
+	usage        models.LLMUsage
+	ttftMs       float64
+	latencyMs    float64
+	toolEvents   []models.ToolEvent
+}
+
+// add records a streamed round (possibly cut short). Usage comes from the
+// provider's final usage chunk; rounds that ended without one are counted
+// with tok.
+func (out *turnOutcome) add(round *streamRound, tok Tokenizer, messages []models.LLMMessage) {
+	if round == nil {
+		return
+	}
+	if round.model != "" {
+		out.model = round.model
 	}
+	out.content = round.content
+	out.finishReason = round.finishReason
 
-	// 3. Inject RAG context
-	messages = o.ctxInj.Inject(ctx, messages)
+	usage := round.usage
+	if usage.TotalTokens == 0 && usage.PromptTokens+usage.CompletionTokens == 0 {
+		usage = countUsage(tok, messages, round.content, round.toolCalls)
+	}
+	out.usage.PromptTokens += usage.PromptTokens
+	out.usage.CompletionTokens += usage.CompletionTokens
+	out.usage.TotalTokens += usage.PromptTokens + usage.CompletionTokens
+}
 
-	// 4. Prepare options
-	var opts []RequestOption
-	if req.Model != "" {
-		opts = append(opts, WithModel(req.Model))
+// fail marks the outcome cancelled when the client went away (ctx done or
+// the stream callback failed), otherwise as an upstream error.
+func (out *turnOutcome) fail(ctx context.Context, err error) {
+	out.status = models.MessageStatusError
+	if ctx.Err() != nil || isCallbackError(err) {
+		out.status = models.MessageStatusCancelled
+	}
+	if out.finishReason == "" {
+		out.finishReason = string(out.status)
+	}
+}
+
+// done is the closing stream event for the outcome.
+func (out *turnOutcome) done(conversationID uuid.UUID) models.StreamDone {
+	return models.StreamDone{
+		ConversationID: &conversationID,
+		MessageID:      &out.assistantID,
+		Status:         out.status,
+		FinishReason:   out.finishReason,
+	}
+}
+
+// streamUsage is the usage stream event for the outcome.
+func (out *turnOutcome) streamUsage() models.StreamUsage {
+	return models.StreamUsage{
+		PromptTokens:     out.usage.PromptTokens,
+		CompletionTokens: out.usage.CompletionTokens,
+		TotalTokens:      out.usage.TotalTokens,
+		TTFTMs:           out.ttftMs,
+		LatencyMs:        out.latencyMs,
+	}
+}
+
+// countUsage estimates the usage of one model call with tok.
+func countUsage(tok Tokenizer, messages []models.LLMMessage, content string, calls []models.LLMToolCall) models.LLMUsage {
+	prompt := tokensPerReply
+	for _, m := range messages {
+		prompt += CountMessage(tok, m)
+	}
+	completion := tok.Count(content)
+	for _, c := range calls {
+		completion += tok.Count(c.Function.Name) + tok.Count(c.Function.Arguments)
+	}
+	return models.LLMUsage{PromptTokens: prompt, CompletionTokens: completion, TotalTokens: prompt + completion}
+}
+
+// finishReason returns the finish reason of the first choice, if any.
+func finishReason(resp *models.LLMResponse) string {
+	if len(resp.Choices) == 0 || resp.Choices[0].FinishReason == nil {
+		return ""
+	}
+	return *resp.Choices[0].FinishReason
+}
+
+// streamRound is the outcome of one streamed model call.
+type streamRound struct {
+	model        string
+	content      string
+	toolCalls    []models.LLMToolCall
+	finishReason string
+	usage        models.LLMUsage
+}
+
+// streamWithFallback streams one model call, forwarding content chunks to cb
+// and collecting tool calls. Fallback models are only tried while nothing
+// has been forwarded yet. On error the round holds what was forwarded.
+func (o *Orchestrator) streamWithFallback(ctx context.Context, req *models.InferenceRequest, role models.UserRole, primary config.ModelConfig, messages []models.LLMMessage, withTools bool, cb StreamCallback) (*streamRound, error) {
+	chain := o.models.Chain(primary, role)
+
+	var err error
+	var round *streamRound
+	for i, m := range chain {
+		round = &streamRound{model: m.Name}
+		var acc toolCallAccumulator
+		var forwarded bool
+		err = o.llm.CompleteStream(ctx, messages, func(chunk models.LLMResponse) error {
+			if chunk.Usage.TotalTokens > 0 || chunk.Usage.PromptTokens+chunk.Usage.CompletionTokens > 0 {
+				round.usage = chunk.Usage
+			}
+			if len(chunk.Choices) > 0 {
+				delta := &chunk.Choices[0].Delta
+				if len(delta.ToolCalls) > 0 {
+					acc.Add(delta.ToolCalls)
+					delta.ToolCalls = nil
+				}
+				round.content += delta.Content
+				if fr := chunk.Choices[0].FinishReason; fr != nil {
+					round.finishReason = *fr
+				}
+
+				// Tool-call fragments and the end of a tool-call round are
+				// internal; only content reaches the client.
+				if len(acc.Calls()) > 0 {
+					if delta.Content == "" {
+						return nil
+					}
+					chunk.Choices[0].FinishReason = nil
+				}
+			}
+			forwarded = true
+			return cb(chunk)
+		}, o.roundOptions(req, m, withTools)...)
+		if err == nil {
+			round.toolCalls = acc.Calls()
+			return round, nil
+		}
+		if forwarded || i == len(chain)-1 || !shouldFallback(ctx, err) {
+			break
+		}
+		slog.Warn("orchestrator.model_fallback", "from", m.Name, "to", chain[i+1].Name, "stream", true, "error", err)
+	}
+	return round, err
+}
+
+// ListModels returns the models the given role may request.
+func (o *Orchestrator) ListModels(role models.UserRole) []models.ModelInfo {
+	return o.models.Allowed(role)
+}
+
+// completeWithTools calls the model and, while it asks for tools, executes
+// them and calls it again with the results. After LLM_MAX_TOOL_ITERATIONS
+// rounds the model is called without tools so it must answer. It returns the
+// final response (usage summed over all rounds), the assistant/tool messages
+// exchanged along the way and the tool events.
+func (o *Orchestrator) completeWithTools(ctx context.Context, req *models.InferenceRequest, user *models.User, conversationID uuid.UUID, primary config.ModelConfig, messages []models.LLMMessage) (*models.LLMResponse, []models.LLMMessage, []models.ToolEvent, error) {
+	var transcript []models.LLMMessage
+	var events []models.ToolEvent
+	var usage models.LLMUsage
+
+	for iter := 0; ; iter++ {
+		resp, err := o.completeWithFallback(ctx, req, user.Role, primary, messages, iter < o.llm.cfg.LLMMaxToolIterations)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		usage.PromptTokens += resp.Usage.PromptTokens
+		usage.CompletionTokens += resp.Usage.CompletionTokens
+		usage.TotalTokens += resp.Usage.TotalTokens
+
+		if len(resp.Choices) == 0 || len(resp.Choices[0].Message.ToolCalls) == 0 {
+			resp.Usage = usage
+			return resp, transcript, events, nil
+		}
+
+		msg := resp.Choices[0].Message
+		step, evs, err := o.runToolCalls(ctx, msg.Content, msg.ToolCalls, user.ID, conversationID, nil)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		messages = append(messages, step...)
+		transcript = append(transcript, step...)
+		events = append(events, evs...)
+	}
+}
+
+// runToolCalls executes the calls requested by the model. It returns the
+// assistant message that made the calls followed by one tool message per
+// call, plus the events reported to onEvent (which may be nil). Tool failures
+// are handed back to the model as results; only an onEvent error aborts.
+func (o *Orchestrator) runToolCalls(ctx context.Context, content string, calls []models.LLMToolCall, userID, conversationID uuid.UUID, onEvent func(models.ToolEvent) error) ([]models.LLMMessage, []models.ToolEvent, error) {
+	calls = append([]models.LLMToolCall(nil), calls...)
+	for i := range calls {
+		calls[i].Index = nil
+		if calls[i].ID == "" {
+			calls[i].ID = "call_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+		}
+	}
+
+	step := []models.LLMMessage{{Role: string(models.RoleAssistantMsg), Content: content, ToolCalls: calls}}
+	var events []models.ToolEvent
+	emit := func(ev models.ToolEvent) error {
+		events = append(events, ev)
+		if onEvent == nil {
+			return nil
+		}
+		return onEvent(ev)
+	}
+
+	for _, call := range calls {
+		if err := emit(models.ToolEvent{
+			Type:       "tool_call",
+			ToolCallID: call.ID,
+			Name:       call.Function.Name,
+			Arguments:  call.Function.Arguments,
+		}); err != nil {
+			return nil, nil, err
+		}
+
+		result, err := o.tools.Execute(ctx, call, userID, conversationID)
+		ev := models.ToolEvent{Type: "tool_result", ToolCallID: call.ID, Name: call.Function.Name, Result: result}
+		if err != nil {
+			ev.Error = err.Error()
+		}
+		if err := emit(ev); err != nil {
+			return nil, nil, err
+		}
+
+		step = append(step, models.LLMMessage{
+			Role:       string(models.RoleToolMsg),
+			Content:    result,
+			ToolCallID: call.ID,
+			Name:       call.Function.Name,
+		})
+	}
+	return step, events, nil
+}
+
+// completeWithFallback calls the primary model and, on a 5xx or timeout,
+// each fallback in turn.
+func (o *Orchestrator) completeWithFallback(ctx context.Context, req *models.InferenceRequest, role models.UserRole, primary config.ModelConfig, messages []models.LLMMessage, withTools bool) (*models.LLMResponse, error) {
+	chain := o.models.Chain(primary, role)
+
+	var lastErr error
+	for i, m := range chain {
+		resp, err := o.llm.Complete(ctx, messages, o.roundOptions(req, m, withTools)...)
+		if err == nil {
+			return resp, nil
+		}
+		lastErr = err
+		if i == len(chain)-1 || !shouldFallback(ctx, err) {
+			break
+		}
+		slog.Warn("orchestrator.model_fallback", "from", m.Name, "to", chain[i+1].Name, "error", err)
+	}
+	return nil, lastErr
+}
+
+// requestParams returns the effective temperature and max tokens for model m:
+// request overrides first, then the model's defaults, then global config.
+func (o *Orchestrator) requestParams(req *models.InferenceRequest, m config.ModelConfig) (float64, int) {
+	temp := o.llm.cfg.LLMTemperature
+	if m.Temperature != nil {
+		temp = *m.Temperature
 	}
 	if req.Temperature != nil {
-		opts = append(opts, WithTemperature(*req.Temperature))
+		temp = *req.Temperature
+	}
+
+	maxTok := o.llm.cfg.LLMMaxTokens
+	if m.MaxTokens != nil {
+		maxTok = *m.MaxTokens
 	}
 	if req.MaxTokens != nil {
-		opts = append(opts, WithMaxTokens(*req.MaxTokens))
+		maxTok = *req.MaxTokens
 	}
-	selectedModel := o.llm.cfg.LLMModel
-	if req.Model != "" {
-		selectedModel = req.Model
+	return temp, maxTok
+}
+
+// requestOptions builds the LLM request options for model m.
+func (o *Orchestrator) requestOptions(req *models.InferenceRequest, m config.ModelConfig) []RequestOption {
+	temp, maxTok := o.requestParams(req, m)
+	opts := []RequestOption{WithModel(m.Name), WithTemperature(temp), WithMaxTokens(maxTok)}
+	if req.ResponseFormat != nil {
+		opts = append(opts, WithResponseFormat(req.ResponseFormat))
 	}
+	return opts
+}
 
-	// 5. Stream from LLM, forwarding chunks to caller
-	var fullContent string
-	err = o.llm.CompleteStream(ctx, messages, func(chunk models.LLMResponse) error {
-		if len(chunk.Choices) > 0 {
-			fullContent += chunk.Choices[0].Delta.Content
-		}
-		return cb(chunk)
-	}, opts...)
+// roundOptions adds the tool declarations to requestOptions when withTools
+// is set and tool calling is enabled for model m.
+func (o *Orchestrator) roundOptions(req *models.InferenceRequest, m config.ModelConfig, withTools bool) []RequestOption {
+	opts := o.requestOptions(req, m)
+	if !withTools {
+		return opts
+	}
+	enabled := o.llm.cfg.LLMToolsEnabled
+	if m.Tools != nil {
+		enabled = *m.Tools
+	}
+	if defs := o.tools.Definitions(); enabled && len(defs) > 0 {
+		opts = append(opts, WithTools(defs))
+	}
+	return opts
+}
+
+// ListConversations returns one page of the user's conversations and the
+// cursor of the next page ("" on the last page).
+func (o *Orchestrator) ListConversations(ctx context.Context, userID uuid.UUID, params models.ConversationListParams) ([]models.Conversation, string, error) {
+	if params.Limit <= 0 {
+		params.Limit = DefaultConversationPageSize
+	}
+	params.Limit = min(params.Limit, MaxConversationPageSize)
+
+	// Fetch one extra row to learn whether another page follows.
+	limit := params.Limit
+	params.Limit++
+	conversations, err := o.pool.ListConversations(ctx, userID, params)
 	if err != nil {
-		return fmt.Errorf("orchestrator: stream: %w", err)
+		return nil, "", fmt.Errorf("orchestrator: list conversations: %w", err)
+	}
+	if len(conversations) <= limit {
+		return conversations, "", nil
 	}
 
-	// 6. Persist after stream completes
-	o.persistMessages(ctx, conversationID, req.Prompt, fullContent, selectedModel, 0, 0)
+	conversations = conversations[:limit]
+	last := conversations[limit-1]
+	return conversations, EncodeConversationCursor(models.ConversationCursor{
+		Pinned:    last.Pinned,
+		UpdatedAt: last.UpdatedAt,
+		ID:        last.ID,
+	}), nil
+}
 
-	return nil
+// UpdateConversation applies a PATCH to a conversation owned by the user
+// and returns the updated row. A title set here is marked as user-chosen, so
+// generated titles never replace it.
+func (o *Orchestrator) UpdateConversation(ctx context.Context, conversationID, userID uuid.UUID, req *models.UpdateConversationRequest) (*models.Conversation, error) {
+	conv, err := o.ownedConversation(ctx, conversationID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Title != nil {
+		if err := o.pool.RenameConversation(ctx, conv.ID, *req.Title); err != nil {
+			return nil, fmt.Errorf("orchestrator: rename conversation: %w", err)
+		}
+	}
+	if req.Pinned != nil || req.Archived != nil {
+		if err := o.pool.SetConversationState(ctx, conv.ID, req.Pinned, req.Archived); err != nil {
+			return nil, fmt.Errorf("orchestrator: update conversation: %w", err)
+		}
+	}
+
+	updated, err := o.pool.GetConversation(ctx, conv.ID)
+	if err != nil {
+		return nil, fmt.Errorf("orchestrator: get conversation: %w", err)
+	}
+	if updated == nil {
+		return nil, ErrConversationNotFound
+	}
+	return updated, nil
 }
 
-// ListConversations returns conversations for the given user.
-func (o *Orchestrator) ListConversations(ctx context.Context, userID uuid.UUID) ([]models.Conversation, error) {
-	conversations, err := o.pool.ListConversations(ctx, userID)
+// DeleteConversation soft-deletes a conversation owned by the user. It can be
+// restored within CONVERSATION_RESTORE_WINDOW_HOURS.
+func (o *Orchestrator) DeleteConversation(ctx context.Context, conversationID, userID uuid.UUID) error {
+	conv, err := o.ownedConversation(ctx, conversationID, userID)
 	if err != nil {
-		return nil, fmt.Errorf("orchestrator: list conversations: %w", err)
+		return err
 	}
-	return conversations, nil
+	if err := o.pool.SoftDeleteConversation(ctx, conv.ID); err != nil {
+		return fmt.Errorf("orchestrator: delete conversation: %w", err)
+	}
+	slog.Info("orchestrator.conversation_deleted", "id", conv.ID, "user_id", userID)
+	return nil
 }
 
-// ListConversationMessages returns all messages for a conversation owned by the user.
-func (o *Orchestrator) ListConversationMessages(ctx context.Context, conversationID, userID uuid.UUID) ([]models.Message, error) {
+// RestoreConversation undoes DeleteConversation while the restore window is
+// open. Restoring a conversation that is not deleted is a no-op.
+func (o *Orchestrator) RestoreConversation(ctx context.Context, conversationID, userID uuid.UUID) (*models.Conversation, error) {
 	conv, err := o.pool.GetConversation(ctx, conversationID)
 	if err != nil {
 		return nil, fmt.Errorf("orchestrator: get conversation: %w", err)
@@ -208,30 +937,108 @@ func (o *Orchestrator) ListConversationMessages(ctx context.Context, conversatio
 	if conv.UserID != userID {
 		return nil, ErrConversationForbidden
 	}
+	if conv.DeletedAt == nil {
+		return conv, nil
+	}
 
-	msgs, err := o.pool.GetConversationMessages(ctx, conversationID)
+	restored, err := o.pool.RestoreConversation(ctx, conv.ID, time.Now().Add(-o.llm.cfg.ConversationRestoreWindow))
 	if err != nil {
-		return nil, fmt.Errorf("orchestrator: get conversation messages: %w", err)
+		return nil, fmt.Errorf("orchestrator: restore conversation: %w", err)
+	}
+	if !restored {
+		return nil, ErrRestoreExpired
+	}
+	conv.DeletedAt = nil
+	slog.Info("orchestrator.conversation_restored", "id", conv.ID, "user_id", userID)
+	return conv, nil
+}
+
+// ownedConversation loads a live conversation and checks that userID owns
+// it. Soft-deleted conversations are reported as not found.
+func (o *Orchestrator) ownedConversation(ctx context.Context, conversationID, userID uuid.UUID) (*models.Conversation, error) {
+	conv, err := o.pool.GetConversation(ctx, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("orchestrator: get conversation: %w", err)
+	}
+	if conv == nil || conv.DeletedAt != nil {
+		return nil, ErrConversationNotFound
+	}
+	if conv.UserID != userID {
+		return nil, ErrConversationForbidden
+	}
+	return conv, nil
+}
+
+// ListConversationMessages returns one page of history for a conversation
+// owned by the user. When ifNoneMatch (an If-None-Match header value)
+// matches the page's ETag, the page is not loaded and NotModified is set.
+func (o *Orchestrator) ListConversationMessages(ctx context.Context, conversationID, userID uuid.UUID, params models.MessageListParams, ifNoneMatch string) (*MessagePage, error) {
+	conv, err := o.ownedConversation(ctx, conversationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if params.Limit <= 0 {
+		params.Limit = DefaultMessagePageSize
+	}
+	params.Limit = min(params.Limit, MaxMessagePageSize)
+
+	page := &MessagePage{ETag: messagesETag(conv.ID, conv.ActiveLeafID, params)}
+	if ifNoneMatch != "" && etagMatches(ifNoneMatch, page.ETag) {
+		page.NotModified = true
+		return page, nil
+	}
+
+	// Fetch one extra row to learn whether more messages lie beyond the page.
+	limit := params.Limit
+	params.Limit++
+	msgs, err := o.pool.ListMessages(ctx, conversationID, params)
+	if err != nil {
+		return nil, fmt.Errorf("orchestrator: list messages: %w", err)
+	}
+
+	forward := params.After != nil || params.Since != nil
+	more := len(msgs) > limit
+	switch {
+	case more && forward:
+		msgs = msgs[:limit]
+	case more:
+		msgs = msgs[1:] // the extra row is the oldest
 	}
+	page.Messages = msgs
+	if len(msgs) == 0 {
+		return page, nil
+	}
+
+	// Paging back from a cursor implies newer messages exist, and paging
+	// forward from one implies older. Since-pages only page forward.
+	if !forward && more || params.After != nil {
+		page.PrevCursor = messageCursor(msgs[0])
+	}
+	if forward && more || params.Before != nil {
+		page.NextCursor = messageCursor(msgs[len(msgs)-1])
+	}
+	return page, nil
+}
 
-	return msgs, nil
+func messageCursor(m models.Message) string {
+	return EncodeMessageCursor(models.MessageCursor{CreatedAt: m.CreatedAt, ID: m.ID})
 }
 
-// resolveConversation returns an existing conversation ID or creates a new one.
-func (o *Orchestrator) resolveConversation(ctx context.Context, convID *uuid.UUID, userID uuid.UUID) (uuid.UUID, error) {
+// resolveConversation returns an existing conversation or creates a new one.
+func (o *Orchestrator) resolveConversation(ctx context.Context, convID *uuid.UUID, userID uuid.UUID) (*models.Conversation, error) {
 	if convID != nil {
 		conv, err := o.pool.GetConversation(ctx, *convID)
 		if err != nil {
-			return uuid.Nil, fmt.Errorf("orchestrator: get conversation: %w", err)
+			return nil, fmt.Errorf("orchestrator: get conversation: %w", err)
 		}
-		if conv != nil {
+		if conv != nil && conv.DeletedAt == nil {
 			// C3 fix: Explicit ownership check — never silently fall through
 			if conv.UserID != userID {
-				return uuid.Nil, ErrConversationForbidden
+				return nil, ErrConversationForbidden
 			}
-			return conv.ID, nil
+			return conv, nil
 		}
-		return uuid.Nil, ErrConversationNotFound
+		return nil, ErrConversationNotFound
 	}
 
 	// Create a new conversation
@@ -240,32 +1047,50 @@ func (o *Orchestrator) resolveConversation(ctx context.Context, convID *uuid.UUI
 		UserID: userID,
 	}
 	if err := o.pool.CreateConversation(ctx, newConv); err != nil {
-		return uuid.Nil, fmt.Errorf("orchestrator: create conversation: %w", err)
+		return nil, fmt.Errorf("orchestrator: create conversation: %w", err)
 	}
 
 	slog.Info("orchestrator.new_conversation", "id", newConv.ID, "user_id", userID)
-	return newConv.ID, nil
+	return newConv, nil
 }
 
-// buildMessages loads conversation history and appends the new user prompt.
-func (o *Orchestrator) buildMessages(ctx context.Context, conversationID uuid.UUID, prompt string) ([]models.LLMMessage, error) {
-	dbMsgs, err := o.pool.GetConversationMessages(ctx, conversationID)
+// buildMessages takes the branch history of the turn, appends the new user
+// prompt, injects RAG context and trims the oldest history so everything fits
+// in model m's context window with maxTokens left for the answer.
+func (o *Orchestrator) buildMessages(ctx context.Context, conversationID uuid.UUID, history []models.Message, prompt string, m config.ModelConfig, maxTokens int) ([]models.LLMMessage, models.ContextBudget, error) {
+	// Turns covered by the rolling summary are replaced by the summary
+	// itself, as long as it summarizes this branch.
+	summary, err := o.pool.GetLatestConversationSummary(ctx, conversationID)
 	if err != nil {
-		return nil, fmt.Errorf("orchestrator: get history: %w", err)
+		slog.Warn("orchestrator.summary_error", "conversation_id", conversationID, "error", err)
+		summary = nil
+	}
+	if summary != nil && !onBranch(history, summary.LastMessageID) {
+		summary = nil
 	}
+	dbMsgs := unsummarized(history, summary)
 
-	// Limit to last 20 messages to stay within context window
-	const maxHistory = 20
+	// Backstop before token counting; the budgeter does the real trimming.
+	const maxHistory = 200
 	if len(dbMsgs) > maxHistory {
 		dbMsgs = dbMsgs[len(dbMsgs)-maxHistory:]
 	}
 
 	messages := make([]models.LLMMessage, 0, len(dbMsgs)+1)
-	for _, m := range dbMsgs {
-		messages = append(messages, models.LLMMessage{
-			Role:    string(m.Role),
-			Content: m.Content,
-		})
+	for _, dm := range dbMsgs {
+		// A stream that failed before any output leaves an empty answer.
+		if dm.Role == models.RoleAssistantMsg && dm.Content == "" && len(dm.ToolCalls) == 0 {
+			continue
+		}
+		msg := models.LLMMessage{
+			Role:      string(dm.Role),
+			Content:   dm.Content,
+			ToolCalls: dm.ToolCalls,
+		}
+		if dm.ToolCallID != nil {
+			msg.ToolCallID = *dm.ToolCallID
+		}
+		messages = append(messages, msg)
 	}
 
 	// Append current user prompt
@@ -274,42 +1099,123 @@ func (o *Orchestrator) buildMessages(ctx context.Context, conversationID uuid.UU
 		Content: prompt,
 	})
 
-	return messages, nil
+	// Inject RAG context, then the summary ahead of the recent history
+	messages = o.ctxInj.Inject(ctx, messages)
+	if summary != nil {
+		messages = insertAfterSystem(messages, summaryMessage(summary))
+	}
+
+	// Fit the context window
+	tok := o.tokens.For(m)
+	messages, budget, err := FitContext(tok, messages, m.ContextWindow, maxTokens)
+	slog.Info("orchestrator.context_budget",
+		"conversation_id", conversationID,
+		"model", m.Name,
+		"tokenizer", budget.Tokenizer,
+		"context_window", budget.ContextWindow,
+		"reserved", budget.ReservedTokens,
+		"system_tokens", budget.SystemTokens,
+		"history_tokens", budget.HistoryTokens,
+		"prompt_tokens", budget.PromptTokens,
+		"total_tokens", budget.TotalTokens,
+		"history_messages", budget.HistoryMessages,
+		"summary", summary != nil,
+		"dropped_messages", budget.DroppedMessages,
+	)
+	if err != nil {
+		return nil, budget, err
+	}
+	return messages, budget, nil
 }
 
-// persistMessages saves the user prompt and assistant response to the database.
-func (o *Orchestrator) persistMessages(ctx context.Context, convID uuid.UUID, prompt, response, model string, tokens int, latencyMs float64) {
+// persistMessages saves the user prompt (unless the turn answers a stored
+// one again), any assistant tool calls and tool results from the turn, and
+// the final assistant message described by out, each the parent of the next,
+// then makes the final message the conversation's active leaf.
+func (o *Orchestrator) persistMessages(ctx context.Context, convID uuid.UUID, t *turn, transcript []models.LLMMessage, out *turnOutcome) {
 	// User message
-	userMsg := &models.Message{
-		ID:             uuid.New(),
-		ConversationID: convID,
-		Role:           models.RoleUserMsg,
-		Content:        prompt,
-	}
-	if err := o.pool.CreateMessage(ctx, userMsg); err != nil {
-		slog.Error("orchestrator.persist_user_msg", "error", err)
+	if !t.existing {
+		userMsg := &models.Message{
+			ID:             t.userMsgID,
+			ConversationID: convID,
+			ParentID:       t.parentID,
+			Role:           models.RoleUserMsg,
+			Content:        t.prompt,
+		}
+		if err := o.pool.CreateMessage(ctx, userMsg); err != nil {
+			slog.Error("orchestrator.persist_user_msg", "error", err)
+			return
+		}
 	}
+	parent := t.userMsgID
 
-	// Assistant message
-	var tokenCount *int
-	if tokens > 0 {
-		tokenCount = &tokens
-	}
-	var lat *float64
-	if latencyMs > 0 {
-		lat = &latencyMs
+	// Tool round trips
+	for _, m := range transcript {
+		msg := &models.Message{
+			ID:             uuid.New(),
+			ConversationID: convID,
+			ParentID:       &parent,
+			Role:           models.MessageRole(m.Role),
+			Content:        m.Content,
+			ToolCalls:      m.ToolCalls,
+		}
+		if m.ToolCallID != "" {
+			msg.ToolCallID = &m.ToolCallID
+		}
+		if msg.Role == models.RoleAssistantMsg {
+			msg.ModelUsed = &out.model
+		}
+		if err := o.pool.CreateMessage(ctx, msg); err != nil {
+			slog.Error("orchestrator.persist_tool_msg", "role", m.Role, "error", err)
+			return
+		}
+		parent = msg.ID
 	}
 
+	// Assistant message
 	assistantMsg := &models.Message{
-		ID:             uuid.New(),
-		ConversationID: convID,
-		Role:           models.RoleAssistantMsg,
-		Content:        response,
-		TokenCount:     tokenCount,
-		ModelUsed:      &model,
-		LatencyMs:      lat,
+		ID:               out.assistantID,
+		ConversationID:   convID,
+		ParentID:         &parent,
+		Role:             models.RoleAssistantMsg,
+		Content:          out.content,
+		TokenCount:       positive(out.usage.TotalTokens),
+		ModelUsed:        &out.model,
+		LatencyMs:        positive(out.latencyMs),
+		Status:           out.status,
+		TTFTMs:           positive(out.ttftMs),
+		PromptTokens:     positive(out.usage.PromptTokens),
+		CompletionTokens: positive(out.usage.CompletionTokens),
+	}
+	if out.finishReason != "" {
+		assistantMsg.FinishReason = &out.finishReason
 	}
 	if err := o.pool.CreateMessage(ctx, assistantMsg); err != nil {
 		slog.Error("orchestrator.persist_assistant_msg", "error", err)
+		return
+	}
+
+	if err := o.pool.SetActiveLeaf(ctx, convID, out.assistantID); err != nil {
+		slog.Error("orchestrator.set_active_leaf", "conversation_id", convID, "error", err)
+	}
+}
+
+// positive returns a pointer to v, or nil when v is not positive.
+func positive[T int | float64](v T) *T {
+	if v <= 0 {
+		return nil
+	}
+	return &v
+}
+
+// insertAfterSystem inserts msg after the leading system messages.
+func insertAfterSystem(messages []models.LLMMessage, msg models.LLMMessage) []models.LLMMessage {
+	i := 0
+	for i < len(messages) && messages[i].Role == "system" {
+		i++
 	}
+	out := make([]models.LLMMessage, 0, len(messages)+1)
+	out = append(out, messages[:i]...)
+	out = append(out, msg)
+	return append(out, messages[i:]...)
 }
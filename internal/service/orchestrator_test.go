@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/prakyathpnayak/roognis/internal/models"
+)
+
+func TestTurnOutcomeFailStatus(t *testing.T) {
+	upstream := errors.New("llm: openai: unexpected EOF")
+
+	out := &turnOutcome{}
+	out.fail(context.Background(), upstream)
+	if out.status != models.MessageStatusError || out.finishReason != "error" {
+		t.Fatalf("expected an upstream error, got %+v", out)
+	}
+
+	out = &turnOutcome{}
+	out.fail(context.Background(), &callbackError{err: errors.New("write: broken pipe")})
+	if out.status != models.MessageStatusCancelled {
+		t.Fatalf("expected a failed write to count as cancelled, got %s", out.status)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	out = &turnOutcome{finishReason: "length"}
+	out.fail(ctx, upstream)
+	if out.status != models.MessageStatusCancelled || out.finishReason != "length" {
+		t.Fatalf("expected cancelled keeping the finish reason, got %+v", out)
+	}
+}
+
+func TestTurnOutcomeUsage(t *testing.T) {
+	messages := []models.LLMMessage{{Role: "user", Content: "one two three"}}
+	out := &turnOutcome{}
+
+	// Reported by the provider.
+	out.add(&streamRound{model: "m", content: "a b", usage: models.LLMUsage{PromptTokens: 20, CompletionTokens: 5, TotalTokens: 25}}, wordTokenizer{}, messages)
+	// Cut short before the usage chunk: counted locally.
+	out.add(&streamRound{model: "m", content: "four five"}, wordTokenizer{}, messages)
+
+	wantPrompt := 20 + tokensPerReply + tokensPerMessage + 3
+	if out.usage.PromptTokens != wantPrompt || out.usage.CompletionTokens != 5+2 {
+		t.Fatalf("unexpected usage: %+v (want prompt %d)", out.usage, wantPrompt)
+	}
+	if out.usage.TotalTokens != out.usage.PromptTokens+out.usage.CompletionTokens {
+		t.Fatalf("inconsistent total: %+v", out.usage)
+	}
+	if out.content != "four five" {
+		t.Fatalf("expected the last round's content, got %q", out.content)
+	}
+}
+
+func TestReplayChunks(t *testing.T) {
+	content := "Photosynthesis turns light into chemical energy — in chloroplasts. 光合作用"
+	chunks := replayChunks(content, 8)
+	if len(chunks) < 2 {
+		t.Fatalf("expected several chunks, got %q", chunks)
+	}
+	if got := strings.Join(chunks, ""); got != content {
+		t.Fatalf("chunks do not reassemble the content: %q", got)
+	}
+	for _, c := range chunks[:len(chunks)-1] {
+		if n := utf8.RuneCountInString(c); n > 16 {
+			t.Fatalf("chunk %q exceeds twice the size", c)
+		}
+	}
+	if replayChunks("", 8) != nil {
+		t.Fatal("expected no chunks for empty content")
+	}
+}
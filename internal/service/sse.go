@@ -6,12 +6,22 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prakyathpnayak/roognis/internal/models"
 )
 
-// SSEWriter writes Server-Sent Events to an http.ResponseWriter.
+// SSEWriter writes Server-Sent Events to an http.ResponseWriter. Every event
+// carries an increasing id: field. Writes are serialised, so a heartbeat can
+// run alongside the stream.
 type SSEWriter struct {
-	w       http.ResponseWriter
-	flusher http.Flusher
+	mu        sync.Mutex
+	w         http.ResponseWriter
+	flusher   http.Flusher
+	lastID    int64
+	lastWrite time.Time
 }
 
 // NewSSEWriter creates an SSE writer and sets up the response headers.
@@ -28,7 +38,7 @@ func NewSSEWriter(w http.ResponseWriter) (*SSEWriter, error) {
 	w.WriteHeader(http.StatusOK)
 	flusher.Flush()
 
-	return &SSEWriter{w: w, flusher: flusher}, nil
+	return &SSEWriter{w: w, flusher: flusher, lastWrite: time.Now()}, nil
 }
 
 // WriteEvent writes a named SSE event with JSON data.
@@ -38,16 +48,17 @@ func (s *SSEWriter) WriteEvent(event string, data any) error {
 		return fmt.Errorf("sse: marshal: %w", err)
 	}
 
-	if event != "" {
-		if _, err := fmt.Fprintf(s.w, "event: %s\n", event); err != nil {
-			return fmt.Errorf("sse: write event: %w", err)
-		}
-	}
-	if _, err := fmt.Fprintf(s.w, "data: %s\n\n", jsonData); err != nil {
-		return fmt.Errorf("sse: write data: %w", err)
-	}
-	s.flusher.Flush()
-	return nil
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.writeEvent(s.lastID+1, event, jsonData)
+}
+
+// WriteStreamEvent writes a buffered event of a resumable stream under its
+// own ID.
+func (s *SSEWriter) WriteStreamEvent(ev models.StreamEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.writeEvent(ev.ID, ev.Event, ev.Data)
 }
 
 // WriteData writes a data-only SSE event.
@@ -55,13 +66,89 @@ func (s *SSEWriter) WriteData(data any) error {
 	return s.WriteEvent("", data)
 }
 
+// WriteUnnumbered writes a data-only event without an id: field, as
+// OpenAI-compatible clients expect.
+func (s *SSEWriter) WriteUnnumbered(data any) error {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("sse: marshal: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.writeEvent(0, "", jsonData)
+}
+
 // WriteDone writes the [DONE] sentinel and closes the stream.
 func (s *SSEWriter) WriteDone() {
-	fmt.Fprint(s.w, "data: [DONE]\n\n")
-	s.flusher.Flush()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.write("data: [DONE]\n\n")
 }
 
-// WriteError writes an error event.
-func (s *SSEWriter) WriteError(msg string) {
-	s.WriteEvent("error", map[string]string{"error": msg})
+// WriteError writes an error event with a machine-readable code.
+func (s *SSEWriter) WriteError(code, msg string) {
+	s.WriteEvent("error", models.StreamError{Code: code, Message: msg})
+}
+
+// StartHeartbeat writes a comment line whenever the stream has been idle for
+// interval, so proxies do not close it while the model is thinking or a tool
+// is running. The returned function stops the heartbeat; call it before the
+// handler returns.
+func (s *SSEWriter) StartHeartbeat(interval time.Duration) (stop func()) {
+	if interval <= 0 {
+		return func() {}
+	}
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				s.mu.Lock()
+				if time.Since(s.lastWrite) >= interval {
+					s.write(": ping\n\n")
+				}
+				s.mu.Unlock()
+			}
+		}
+	}()
+	return sync.OnceFunc(func() {
+		close(done)
+		wg.Wait()
+	})
+}
+
+// writeEvent writes one event frame, without an id: field when id is 0.
+// s.mu must be held.
+func (s *SSEWriter) writeEvent(id int64, event string, data []byte) error {
+	var frame string
+	if id > 0 {
+		s.lastID = id
+		frame = "id: " + strconv.FormatInt(id, 10) + "\n"
+	}
+	if event != "" {
+		frame += "event: " + event + "\n"
+	}
+	frame += "data: " + string(data) + "\n\n"
+	if err := s.write(frame); err != nil {
+		return fmt.Errorf("sse: write event: %w", err)
+	}
+	return nil
+}
+
+// write sends a raw frame and flushes it. s.mu must be held.
+func (s *SSEWriter) write(frame string) error {
+	s.lastWrite = time.Now()
+	if _, err := fmt.Fprint(s.w, frame); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
 }
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSSEWriterEventIDs(t *testing.T) {
+	rec := httptest.NewRecorder()
+	sse, err := NewSSEWriter(rec)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sse.WriteEvent("meta", map[string]string{"a": "b"})
+	sse.WriteData(1)
+	sse.WriteError("upstream_error", "boom")
+	sse.WriteDone()
+
+	want := "id: 1\nevent: meta\ndata: {\"a\":\"b\"}\n\n" +
+		"id: 2\ndata: 1\n\n" +
+		"id: 3\nevent: error\ndata: {\"code\":\"upstream_error\",\"error\":\"boom\"}\n\n" +
+		"data: [DONE]\n\n"
+	if got := rec.Body.String(); got != want {
+		t.Fatalf("unexpected stream:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestSSEWriterUnnumbered(t *testing.T) {
+	rec := httptest.NewRecorder()
+	sse, err := NewSSEWriter(rec)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sse.WriteUnnumbered(map[string]int{"a": 1})
+	sse.WriteEvent("meta", 2)
+	sse.WriteDone()
+
+	want := "data: {\"a\":1}\n\n" +
+		"id: 1\nevent: meta\ndata: 2\n\n" +
+		"data: [DONE]\n\n"
+	if got := rec.Body.String(); got != want {
+		t.Fatalf("unexpected stream:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestSSEWriterHeartbeat(t *testing.T) {
+	rec := httptest.NewRecorder()
+	sse, err := NewSSEWriter(rec)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	stop := sse.StartHeartbeat(5 * time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	stop()
+	stop() // stopping twice is harmless
+
+	body := rec.Body.String()
+	if !strings.Contains(body, ": ping\n\n") {
+		t.Fatalf("expected a heartbeat comment, got %q", body)
+	}
+	n := len(body)
+	time.Sleep(20 * time.Millisecond)
+	if rec.Body.Len() != n {
+		t.Fatal("heartbeat kept writing after stop")
+	}
+}
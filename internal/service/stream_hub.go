@@ -0,0 +1,562 @@
+// Stream hub — buffers the events of streamed generations so clients can
+// resume them.
+// Maps to design.swift: Token Streamer (resumable streams)
+//
+// Each generation's events are kept per assistant message, numbered from 1,
+// in a Redis Stream. Subscribers read it with non-blocking XRANGEs when an
+// append is announced on one shared pub/sub connection, so they do not hold
+// pooled connections while waiting. The hub also keeps the registry of
+// running generations and carries cancel requests to the instance running
+// them over Redis pub/sub. Falls back to in-memory buffers when Redis is
+// unavailable, in which case streams can only be resumed on the instance
+// running them.
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prakyathpnayak/roognis/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrStreamNotFound is returned for streams that never existed, have
+// expired or belong to another user.
+var ErrStreamNotFound = errors.New("stream not found")
+
+const (
+	// streamMaxAge bounds how long the events of a stream that is never
+	// closed (its instance died) are kept.
+	streamMaxAge = time.Hour
+	// streamPollInterval is how long a Redis subscriber waits for an
+	// announced append before reading anyway and checking that the stream
+	// still exists.
+	streamPollInterval = 5 * time.Second
+	// streamReadCount bounds the events fetched by one XRANGE.
+	streamReadCount = 100
+)
+
+// StreamHub stores stream events and delivers them to subscribers.
+type StreamHub struct {
+	rdb       *redis.Client
+	retention time.Duration // how long a finished stream can be resumed
+	// In-memory fallback
+	mu      sync.Mutex
+	streams map[uuid.UUID]*memStream
+	active  map[uuid.UUID]models.ActiveGeneration
+	// Redis subscribers waiting for appends, woken by watchAppends
+	watchOnce sync.Once
+	watchers  map[uuid.UUID]map[chan struct{}]struct{}
+}
+
+// memStream is the in-memory buffer of one stream.
+type memStream struct {
+	owner   uuid.UUID
+	events  []models.StreamEvent
+	closed  bool
+	expires time.Time
+	lease   time.Time
+	notify  chan struct{} // closed and replaced on every change
+}
+
+// NewStreamHub creates a stream hub. If rdb is nil, uses in-memory buffers.
+func NewStreamHub(rdb *redis.Client, retention time.Duration) *StreamHub {
+	return &StreamHub{
+		rdb:       rdb,
+		retention: retention,
+		streams:   make(map[uuid.UUID]*memStream),
+		active:    make(map[uuid.UUID]models.ActiveGeneration),
+		watchers:  make(map[uuid.UUID]map[chan struct{}]struct{}),
+	}
+}
+
+// Open creates the stream of message id, resumable by owner only.
+func (h *StreamHub) Open(ctx context.Context, id, owner uuid.UUID) error {
+	if h.rdb != nil {
+		if err := h.rdb.Set(ctx, streamOwnerKey(id), owner.String(), streamMaxAge).Err(); err != nil {
+			return fmt.Errorf("stream_hub.open: %w", err)
+		}
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sweepLocked()
+	h.streams[id] = &memStream{owner: owner, expires: time.Now().Add(streamMaxAge), notify: make(chan struct{})}
+	return nil
+}
+
+// Append adds ev to the stream of message id. Event IDs must increase.
+func (h *StreamHub) Append(ctx context.Context, id uuid.UUID, ev models.StreamEvent) error {
+	if h.rdb != nil {
+		pipe := h.rdb.Pipeline()
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: streamEventsKey(id),
+			ID:     "0-" + strconv.FormatInt(ev.ID, 10),
+			Values: map[string]any{"event": ev.Event, "data": string(ev.Data)},
+		})
+		if ev.ID == 1 {
+			pipe.Expire(ctx, streamEventsKey(id), streamMaxAge)
+		}
+		pipe.Publish(ctx, appendChannel, id.String())
+		if _, err := pipe.Exec(ctx); err != nil {
+			return fmt.Errorf("stream_hub.append: %w", err)
+		}
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.streams[id]
+	if s == nil || s.closed {
+		return ErrStreamNotFound
+	}
+	s.events = append(s.events, ev)
+	s.changed()
+	return nil
+}
+
+// Close marks the stream of message id finished; it can be resumed for
+// the retention period.
+func (h *StreamHub) Close(ctx context.Context, id uuid.UUID) error {
+	if h.rdb != nil {
+		pipe := h.rdb.Pipeline()
+		pipe.Expire(ctx, streamEventsKey(id), h.retention)
+		pipe.Expire(ctx, streamOwnerKey(id), h.retention)
+		if _, err := pipe.Exec(ctx); err != nil {
+			return fmt.Errorf("stream_hub.close: %w", err)
+		}
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s := h.streams[id]; s != nil {
+		s.closed = true
+		s.expires = time.Now().Add(h.retention)
+		s.changed()
+	}
+	return nil
+}
+
+// Subscribe returns the events of message id's stream after event after,
+// followed by new ones as they are appended. The channel is closed after the
+// "done" event, when the stream expires or when ctx is cancelled.
+func (h *StreamHub) Subscribe(ctx context.Context, id, owner uuid.UUID, after int64) (<-chan models.StreamEvent, error) {
+	if h.rdb != nil {
+		return h.subscribeRedis(ctx, id, owner, after)
+	}
+	return h.subscribeMemory(ctx, id, owner, after)
+}
+
+// Touch leases the stream of message id to a reader for d. A generation
+// whose client has gone away keeps running while its stream is leased.
+func (h *StreamHub) Touch(ctx context.Context, id uuid.UUID, d time.Duration) {
+	if h.rdb != nil {
+		if err := h.rdb.Set(ctx, streamLeaseKey(id), 1, d).Err(); err != nil {
+			slog.Warn("stream_hub.touch_error", "message_id", id, "error", err)
+		}
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s := h.streams[id]; s != nil {
+		s.lease = time.Now().Add(d)
+	}
+}
+
+// Leased reports whether a reader has touched the stream of message id
+// within its lease.
+func (h *StreamHub) Leased(ctx context.Context, id uuid.UUID) bool {
+	if h.rdb != nil {
+		n, err := h.rdb.Exists(ctx, streamLeaseKey(id)).Result()
+		if err != nil {
+			slog.Warn("stream_hub.lease_error", "message_id", id, "error", err)
+			return false
+		}
+		return n > 0
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.streams[id]
+	return s != nil && time.Now().Before(s.lease)
+}
+
+// Register records a running generation.
+func (h *StreamHub) Register(ctx context.Context, g models.ActiveGeneration) error {
+	if h.rdb != nil {
+		data, err := json.Marshal(g)
+		if err != nil {
+			return fmt.Errorf("stream_hub.register: marshal: %w", err)
+		}
+		pipe := h.rdb.Pipeline()
+		pipe.Set(ctx, streamGenerationKey(g.MessageID), data, streamMaxAge)
+		pipe.ZAdd(ctx, activeGenerationsKey, redis.Z{Score: float64(g.StartedAt.Unix()), Member: g.MessageID.String()})
+		if _, err := pipe.Exec(ctx); err != nil {
+			return fmt.Errorf("stream_hub.register: %w", err)
+		}
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.active[g.MessageID] = g
+	return nil
+}
+
+// Unregister removes a generation that has ended from the registry.
+func (h *StreamHub) Unregister(ctx context.Context, id uuid.UUID) error {
+	if h.rdb != nil {
+		pipe := h.rdb.Pipeline()
+		pipe.Del(ctx, streamGenerationKey(id))
+		pipe.ZRem(ctx, activeGenerationsKey, id.String())
+		if _, err := pipe.Exec(ctx); err != nil {
+			return fmt.Errorf("stream_hub.unregister: %w", err)
+		}
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.active, id)
+	return nil
+}
+
+// Generation returns the running generation of message id, or nil.
+func (h *StreamHub) Generation(ctx context.Context, id uuid.UUID) (*models.ActiveGeneration, error) {
+	if h.rdb != nil {
+		data, err := h.rdb.Get(ctx, streamGenerationKey(id)).Bytes()
+		if err == redis.Nil {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("stream_hub.generation: %w", err)
+		}
+		var g models.ActiveGeneration
+		if err := json.Unmarshal(data, &g); err != nil {
+			return nil, fmt.Errorf("stream_hub.generation: unmarshal: %w", err)
+		}
+		return &g, nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	g, ok := h.active[id]
+	if !ok {
+		return nil, nil
+	}
+	return &g, nil
+}
+
+// Generations lists the running generations, oldest first.
+func (h *StreamHub) Generations(ctx context.Context) ([]models.ActiveGeneration, error) {
+	if h.rdb != nil {
+		return h.generationsRedis(ctx)
+	}
+
+	h.mu.Lock()
+	gens := make([]models.ActiveGeneration, 0, len(h.active))
+	for _, g := range h.active {
+		gens = append(gens, g)
+	}
+	h.mu.Unlock()
+	slices.SortFunc(gens, func(a, b models.ActiveGeneration) int { return a.StartedAt.Compare(b.StartedAt) })
+	return gens, nil
+}
+
+// RequestCancel asks the instance running the generation of message id to
+// cancel it. Without Redis there is no other instance, so it does nothing.
+func (h *StreamHub) RequestCancel(ctx context.Context, id uuid.UUID) error {
+	if h.rdb == nil {
+		return nil
+	}
+	if err := h.rdb.Publish(ctx, cancelChannel, id.String()).Err(); err != nil {
+		return fmt.Errorf("stream_hub.request_cancel: %w", err)
+	}
+	return nil
+}
+
+// CancelRequests delivers the message IDs of cancel requests from all
+// instances until ctx is cancelled. It returns nil without Redis.
+func (h *StreamHub) CancelRequests(ctx context.Context) <-chan uuid.UUID {
+	if h.rdb == nil {
+		return nil
+	}
+	sub := h.rdb.Subscribe(ctx, cancelChannel)
+	ch := make(chan uuid.UUID)
+	go func() {
+		defer close(ch)
+		for msg := range sub.Channel() {
+			id, err := uuid.Parse(msg.Payload)
+			if err != nil {
+				continue
+			}
+			select {
+			case ch <- id:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		sub.Close() // ends sub.Channel()
+	}()
+	return ch
+}
+
+// generationsRedis reads the registry, dropping entries of generations
+// whose instance died without unregistering them.
+func (h *StreamHub) generationsRedis(ctx context.Context) ([]models.ActiveGeneration, error) {
+	stale := strconv.FormatInt(time.Now().Add(-streamMaxAge).Unix(), 10)
+	if err := h.rdb.ZRemRangeByScore(ctx, activeGenerationsKey, "-inf", "("+stale).Err(); err != nil {
+		return nil, fmt.Errorf("stream_hub.generations: %w", err)
+	}
+	ids, err := h.rdb.ZRange(ctx, activeGenerationsKey, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("stream_hub.generations: %w", err)
+	}
+	if len(ids) == 0 {
+		return []models.ActiveGeneration{}, nil
+	}
+
+	members := make([]string, 0, len(ids))
+	keys := make([]string, 0, len(ids))
+	for _, member := range ids {
+		id, err := uuid.Parse(member)
+		if err != nil {
+			h.rdb.ZRem(ctx, activeGenerationsKey, member)
+			continue
+		}
+		members = append(members, member)
+		keys = append(keys, streamGenerationKey(id))
+	}
+	if len(keys) == 0 {
+		return []models.ActiveGeneration{}, nil
+	}
+	values, err := h.rdb.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("stream_hub.generations: %w", err)
+	}
+
+	gens := make([]models.ActiveGeneration, 0, len(values))
+	for i, v := range values {
+		data, ok := v.(string)
+		var g models.ActiveGeneration
+		if !ok || json.Unmarshal([]byte(data), &g) != nil {
+			h.rdb.ZRem(ctx, activeGenerationsKey, members[i])
+			continue
+		}
+		gens = append(gens, g)
+	}
+	return gens, nil
+}
+
+// subscribeRedis follows the Redis Stream, reading new events with XRANGE
+// whenever an append is announced.
+func (h *StreamHub) subscribeRedis(ctx context.Context, id, owner uuid.UUID, after int64) (<-chan models.StreamEvent, error) {
+	stored, err := h.rdb.Get(ctx, streamOwnerKey(id)).Result()
+	if err == redis.Nil || (err == nil && stored != owner.String()) {
+		return nil, ErrStreamNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("stream_hub.subscribe: %w", err)
+	}
+
+	h.watchOnce.Do(h.watchAppends)
+	ch := make(chan models.StreamEvent)
+	go func() {
+		defer close(ch)
+		// Watch before the first read so no append is missed in between.
+		wake, unwatch := h.watch(id)
+		defer unwatch()
+		poll := time.NewTicker(streamPollInterval)
+		defer poll.Stop()
+
+		last := "0-" + strconv.FormatInt(after, 10)
+		for {
+			msgs, err := h.rdb.XRangeN(ctx, streamEventsKey(id), "("+last, "+", streamReadCount).Result()
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				slog.Warn("stream_hub.read_error", "message_id", id, "error", err)
+				return
+			}
+			for _, msg := range msgs {
+				last = msg.ID
+				ev, ok := streamEventFromRedis(msg)
+				if !ok {
+					continue
+				}
+				select {
+				case ch <- ev:
+				case <-ctx.Done():
+					return
+				}
+				if ev.Event == "done" {
+					return
+				}
+			}
+			if len(msgs) == streamReadCount {
+				continue
+			}
+
+			select {
+			case <-wake:
+			case <-poll.C:
+				// Nothing announced: stop once the stream has expired.
+				if n, err := h.rdb.Exists(ctx, streamOwnerKey(id)).Result(); err != nil || n == 0 {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// watch registers a subscriber of message id's stream. The returned channel
+// receives when an append is announced; unwatch deregisters it.
+func (h *StreamHub) watch(id uuid.UUID) (wake <-chan struct{}, unwatch func()) {
+	c := make(chan struct{}, 1)
+	h.mu.Lock()
+	if h.watchers[id] == nil {
+		h.watchers[id] = make(map[chan struct{}]struct{})
+	}
+	h.watchers[id][c] = struct{}{}
+	h.mu.Unlock()
+	return c, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.watchers[id], c)
+		if len(h.watchers[id]) == 0 {
+			delete(h.watchers, id)
+		}
+	}
+}
+
+// watchAppends wakes the watchers of each stream announced on the append
+// channel, for the life of the process. One connection serves all of this
+// instance's subscribers.
+func (h *StreamHub) watchAppends() {
+	sub := h.rdb.Subscribe(context.Background(), appendChannel)
+	go func() {
+		for msg := range sub.Channel() {
+			id, err := uuid.Parse(msg.Payload)
+			if err != nil {
+				continue
+			}
+			h.mu.Lock()
+			for c := range h.watchers[id] {
+				select {
+				case c <- struct{}{}:
+				default: // already woken
+				}
+			}
+			h.mu.Unlock()
+		}
+	}()
+}
+
+// subscribeMemory follows an in-memory buffer.
+func (h *StreamHub) subscribeMemory(ctx context.Context, id, owner uuid.UUID, after int64) (<-chan models.StreamEvent, error) {
+	h.mu.Lock()
+	s := h.streams[id]
+	if s == nil || s.owner != owner || time.Now().After(s.expires) {
+		h.mu.Unlock()
+		return nil, ErrStreamNotFound
+	}
+	h.mu.Unlock()
+
+	ch := make(chan models.StreamEvent)
+	go func() {
+		defer close(ch)
+		next := 0 // index of the first event not yet sent
+		for {
+			h.mu.Lock()
+			var pending []models.StreamEvent
+			for _, ev := range s.events[next:] {
+				if ev.ID > after {
+					pending = append(pending, ev)
+				}
+			}
+			next = len(s.events)
+			closed, notify := s.closed, s.notify
+			h.mu.Unlock()
+
+			for _, ev := range pending {
+				select {
+				case ch <- ev:
+				case <-ctx.Done():
+					return
+				}
+				if ev.Event == "done" {
+					return
+				}
+			}
+			if closed {
+				return
+			}
+			select {
+			case <-notify:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// sweepLocked drops expired in-memory streams. h.mu must be held.
+func (h *StreamHub) sweepLocked() {
+	now := time.Now()
+	for id, s := range h.streams {
+		if now.After(s.expires) {
+			delete(h.streams, id)
+		}
+	}
+}
+
+// changed wakes the subscribers of s. The hub's lock must be held.
+func (s *memStream) changed() {
+	close(s.notify)
+	s.notify = make(chan struct{})
+}
+
+// streamEventFromRedis decodes a Redis Stream entry written by Append.
+func streamEventFromRedis(msg redis.XMessage) (models.StreamEvent, bool) {
+	_, seq, _ := strings.Cut(msg.ID, "-")
+	n, err := strconv.ParseInt(seq, 10, 64)
+	if err != nil {
+		return models.StreamEvent{}, false
+	}
+	event, _ := msg.Values["event"].(string)
+	data, _ := msg.Values["data"].(string)
+	return models.StreamEvent{ID: n, Event: event, Data: json.RawMessage(data)}, true
+}
+
+func streamEventsKey(id uuid.UUID) string { return "stream:" + id.String() + ":events" }
+func streamOwnerKey(id uuid.UUID) string  { return "stream:" + id.String() + ":owner" }
+func streamLeaseKey(id uuid.UUID) string  { return "stream:" + id.String() + ":lease" }
+func streamGenerationKey(id uuid.UUID) string {
+	return "stream:" + id.String() + ":generation"
+}
+
+const (
+	activeGenerationsKey = "streams:active"
+	cancelChannel        = "streams:cancel"
+	appendChannel        = "streams:appended"
+)
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prakyathpnayak/roognis/internal/models"
+)
+
+func hubEvent(id int64, event string) models.StreamEvent {
+	return models.StreamEvent{ID: id, Event: event, Data: json.RawMessage(`{}`)}
+}
+
+func collect(t *testing.T, ch <-chan models.StreamEvent) []int64 {
+	t.Helper()
+	var ids []int64
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				return ids
+			}
+			ids = append(ids, ev.ID)
+		case <-timeout:
+			t.Fatalf("subscription did not end, got %v", ids)
+		}
+	}
+}
+
+func TestStreamHubResume(t *testing.T) {
+	ctx := context.Background()
+	hub := NewStreamHub(nil, time.Minute)
+	id, owner := uuid.New(), uuid.New()
+
+	if err := hub.Open(ctx, id, owner); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	hub.Append(ctx, id, hubEvent(1, "meta"))
+	hub.Append(ctx, id, hubEvent(2, "delta"))
+
+	ch, err := hub.Subscribe(ctx, id, owner, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Events appended after subscribing are delivered live.
+	go func() {
+		hub.Append(ctx, id, hubEvent(3, "delta"))
+		hub.Append(ctx, id, hubEvent(4, "done"))
+		hub.Close(ctx, id)
+	}()
+
+	ids := collect(t, ch)
+	if len(ids) != 3 || ids[0] != 2 || ids[2] != 4 {
+		t.Fatalf("expected events 2..4, got %v", ids)
+	}
+
+	// A finished stream can still be replayed from the start.
+	ch, err = hub.Subscribe(ctx, id, owner, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ids := collect(t, ch); len(ids) != 4 {
+		t.Fatalf("expected 4 events, got %v", ids)
+	}
+}
+
+func TestStreamHubNotFound(t *testing.T) {
+	ctx := context.Background()
+	hub := NewStreamHub(nil, 0)
+	id, owner := uuid.New(), uuid.New()
+
+	if _, err := hub.Subscribe(ctx, id, owner, 0); !errors.Is(err, ErrStreamNotFound) {
+		t.Fatalf("expected ErrStreamNotFound for an unknown stream, got %v", err)
+	}
+
+	hub.Open(ctx, id, owner)
+	if _, err := hub.Subscribe(ctx, id, uuid.New(), 0); !errors.Is(err, ErrStreamNotFound) {
+		t.Fatalf("expected ErrStreamNotFound for another user, got %v", err)
+	}
+
+	// With no retention, a closed stream expires at once.
+	hub.Close(ctx, id)
+	time.Sleep(time.Millisecond)
+	if _, err := hub.Subscribe(ctx, id, owner, 0); !errors.Is(err, ErrStreamNotFound) {
+		t.Fatalf("expected ErrStreamNotFound for an expired stream, got %v", err)
+	}
+}
+
+func TestStreamHubLease(t *testing.T) {
+	ctx := context.Background()
+	hub := NewStreamHub(nil, time.Minute)
+	id := uuid.New()
+	hub.Open(ctx, id, uuid.New())
+
+	if hub.Leased(ctx, id) {
+		t.Fatal("expected a new stream not to be leased")
+	}
+	hub.Touch(ctx, id, time.Minute)
+	if !hub.Leased(ctx, id) {
+		t.Fatal("expected a touched stream to be leased")
+	}
+}
@@ -0,0 +1,344 @@
+// Streamed generations — run detached from the request that started them.
+// Maps to design.swift: Prompt Orchestrator → Token Streamer (resumable streams)
+//
+// A streamed generation publishes its events to the StreamHub under its
+// assistant message ID, and clients read them from there: the request that
+// started it as well as any client resuming it with Last-Event-ID. When the
+// starting client disconnects, the generation keeps running for a grace
+// period, longer while a resumed client is reading. Running generations are
+// registered in the hub so they can be listed and cancelled from any
+// instance.
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prakyathpnayak/roognis/internal/config"
+	"github.com/prakyathpnayak/roognis/internal/models"
+)
+
+// Streams starts streamed generations and serves their events.
+type Streams struct {
+	orch *Orchestrator
+	hub  *StreamHub
+	cfg  *config.Config
+
+	mu      sync.Mutex
+	running map[uuid.UUID]*runningGeneration // generations on this instance
+}
+
+// runningGeneration is a generation running on this instance.
+type runningGeneration struct {
+	cancel context.CancelFunc
+	done   chan struct{} // closed once its answer is stored
+}
+
+// ErrGenerationNotFound is returned for generations that are not running or
+// that the user may not cancel.
+var ErrGenerationNotFound = errors.New("generation not found")
+
+// cancelWait bounds how long WaitStopped waits for a cancelled generation.
+const cancelWait = 5 * time.Second
+
+// NewStreams creates the streamed generation runner.
+func NewStreams(orch *Orchestrator, hub *StreamHub, cfg *config.Config) *Streams {
+	return &Streams{orch: orch, hub: hub, cfg: cfg, running: make(map[uuid.UUID]*runningGeneration)}
+}
+
+// Start runs req as a streamed generation in the background. It returns
+// once the generation's stream is open, with its metadata, or with the error
+// that ended it before the model was called.
+func (s *Streams) Start(ctx context.Context, req *models.InferenceRequest, user *models.User) (*models.StreamMeta, error) {
+	genCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	started := make(chan models.StreamMeta, 1)
+	failed := make(chan error, 1)
+
+	go func() {
+		defer cancel()
+		g := &generation{hub: s.hub, ctx: context.WithoutCancel(genCtx)}
+		done := models.StreamDone{Status: models.MessageStatusError}
+
+		err := s.orch.StreamComplete(genCtx, req, user, StreamHandlers{
+			OnMeta: func(m models.StreamMeta) error {
+				if err := s.hub.Open(g.ctx, m.MessageID, user.ID); err != nil {
+					return err
+				}
+				g.meta = &m
+				if err := g.publish("meta", m); err != nil {
+					g.meta = nil
+					return err
+				}
+				done.ConversationID, done.MessageID = &m.ConversationID, &m.MessageID
+				s.track(g.ctx, models.ActiveGeneration{
+					MessageID:      m.MessageID,
+					ConversationID: m.ConversationID,
+					UserID:         user.ID,
+					Model:          m.Model,
+					StartedAt:      time.Now(),
+				}, cancel)
+				started <- m
+				go s.watch(ctx, genCtx, cancel, m.MessageID)
+				return nil
+			},
+			OnChunk: func(chunk models.LLMResponse) error {
+				if len(chunk.Choices) == 0 {
+					return nil
+				}
+				return g.publish("delta", models.StreamChunk{
+					ID:             g.meta.MessageID,
+					ConversationID: g.meta.ConversationID,
+					Delta:          chunk.Choices[0].Delta.Content,
+					FinishReason:   chunk.Choices[0].FinishReason,
+					Model:          chunk.Model,
+					Cached:         g.meta.Cached,
+				})
+			},
+			OnToolEvent: func(ev models.ToolEvent) error {
+				return g.publish(ev.Type, ev)
+			},
+			OnContext: func(budget models.ContextBudget) error {
+				return g.publish("context", budget)
+			},
+			OnFinish: func(d models.StreamDone, usage models.StreamUsage) {
+				done = d
+				g.publish("usage", usage)
+			},
+		})
+
+		if g.meta == nil {
+			if err == nil {
+				err = errors.New("stream ended before it started")
+			}
+			failed <- err
+			return
+		}
+		s.untrack(g.ctx, g.meta.MessageID)
+		if err != nil && genCtx.Err() == nil {
+			code, msg := StreamErrorCode(err)
+			if code == "upstream_error" {
+				slog.Error("streams.generation_error", "error", err, "message_id", g.meta.MessageID, "user_id", user.ID)
+			}
+			g.publish("error", models.StreamError{Code: code, Message: msg})
+		}
+		g.publish("done", done)
+		if err := s.hub.Close(g.ctx, g.meta.MessageID); err != nil {
+			slog.Warn("streams.close_error", "message_id", g.meta.MessageID, "error", err)
+		}
+	}()
+
+	select {
+	case m := <-started:
+		return &m, nil
+	case err := <-failed:
+		return nil, err
+	}
+}
+
+// Cancel stops the running generation of message id, which must be the
+// user's unless they are an admin. The generation stores what it has
+// generated with status cancelled and its stream ends with "usage" and a
+// "done" event; see WaitStopped.
+func (s *Streams) Cancel(ctx context.Context, id uuid.UUID, user *models.User) (*models.ActiveGeneration, error) {
+	gen, err := s.hub.Generation(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("streams: get generation: %w", err)
+	}
+	if gen == nil || (gen.UserID != user.ID && user.Role != models.RoleAdmin) {
+		return nil, ErrGenerationNotFound
+	}
+
+	slog.Info("streams.cancel", "message_id", id, "user_id", gen.UserID, "by", user.ID)
+	if !s.cancelLocal(id) {
+		if err := s.hub.RequestCancel(ctx, id); err != nil {
+			return nil, fmt.Errorf("streams: request cancel: %w", err)
+		}
+	}
+	return gen, nil
+}
+
+// WaitStopped waits a few seconds for the generation of message id to end
+// and reports whether it has.
+func (s *Streams) WaitStopped(ctx context.Context, id uuid.UUID) bool {
+	ctx, cancel := context.WithTimeout(ctx, cancelWait)
+	defer cancel()
+
+	s.mu.Lock()
+	r := s.running[id]
+	s.mu.Unlock()
+	if r != nil {
+		select {
+		case <-r.done:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	// Running on another instance: wait for it to leave the registry.
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if gen, err := s.hub.Generation(ctx, id); err == nil && gen == nil {
+			return true
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// Generations lists the running generations of userID, or of all users
+// when userID is nil, oldest first.
+func (s *Streams) Generations(ctx context.Context, userID *uuid.UUID) ([]models.ActiveGeneration, error) {
+	gens, err := s.hub.Generations(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("streams: list generations: %w", err)
+	}
+	if userID != nil {
+		gens = slices.DeleteFunc(gens, func(g models.ActiveGeneration) bool { return g.UserID != *userID })
+	}
+	return gens, nil
+}
+
+// Listen cancels generations of this instance that are cancelled through
+// another one, until ctx is cancelled.
+func (s *Streams) Listen(ctx context.Context) {
+	requests := s.hub.CancelRequests(ctx)
+	if requests == nil {
+		return
+	}
+	go func() {
+		for id := range requests {
+			s.cancelLocal(id)
+		}
+	}()
+}
+
+// cancelLocal cancels the generation of message id if it runs on this
+// instance.
+func (s *Streams) cancelLocal(id uuid.UUID) bool {
+	s.mu.Lock()
+	r, ok := s.running[id]
+	s.mu.Unlock()
+	if ok {
+		r.cancel()
+	}
+	return ok
+}
+
+// track registers a generation that has started on this instance.
+func (s *Streams) track(ctx context.Context, gen models.ActiveGeneration, cancel context.CancelFunc) {
+	s.mu.Lock()
+	s.running[gen.MessageID] = &runningGeneration{cancel: cancel, done: make(chan struct{})}
+	s.mu.Unlock()
+	if err := s.hub.Register(ctx, gen); err != nil {
+		slog.Warn("streams.register_error", "message_id", gen.MessageID, "error", err)
+	}
+}
+
+// untrack removes a generation whose answer is stored.
+func (s *Streams) untrack(ctx context.Context, id uuid.UUID) {
+	if err := s.hub.Unregister(ctx, id); err != nil {
+		slog.Warn("streams.unregister_error", "message_id", id, "error", err)
+	}
+	s.mu.Lock()
+	r := s.running[id]
+	delete(s.running, id)
+	s.mu.Unlock()
+	if r != nil {
+		close(r.done)
+	}
+}
+
+// Subscribe returns the events of the stream of message id, owned by
+// userID, after event after. See StreamHub.Subscribe.
+func (s *Streams) Subscribe(ctx context.Context, id, userID uuid.UUID, after int64) (<-chan models.StreamEvent, error) {
+	return s.hub.Subscribe(ctx, id, userID, after)
+}
+
+// Touch tells a generation that a client is still reading its stream.
+func (s *Streams) Touch(ctx context.Context, id uuid.UUID) {
+	s.hub.Touch(ctx, id, s.cfg.StreamResumeGrace)
+}
+
+// watch cancels a generation when the client that started it has gone away
+// and nobody has resumed its stream within the grace period.
+func (s *Streams) watch(reqCtx, genCtx context.Context, cancel context.CancelFunc, id uuid.UUID) {
+	select {
+	case <-genCtx.Done():
+		return
+	case <-reqCtx.Done():
+	}
+
+	bg := context.WithoutCancel(genCtx)
+	grace := s.cfg.StreamResumeGrace
+	s.hub.Touch(bg, id, grace)
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+	for {
+		select {
+		case <-genCtx.Done():
+			return
+		case <-timer.C:
+			if !s.hub.Leased(bg, id) {
+				slog.Info("streams.abandoned", "message_id", id)
+				cancel()
+				return
+			}
+			timer.Reset(grace)
+		}
+	}
+}
+
+// generation numbers and publishes the events of one stream.
+type generation struct {
+	hub    *StreamHub
+	ctx    context.Context // not cancelled with the generation
+	meta   *models.StreamMeta
+	nextID int64
+}
+
+func (g *generation) publish(event string, data any) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("streams: marshal %s: %w", event, err)
+	}
+	g.nextID++
+	ev := models.StreamEvent{ID: g.nextID, Event: event, Data: b}
+	if err := g.hub.Append(g.ctx, g.meta.MessageID, ev); err != nil {
+		slog.Warn("streams.publish_error", "message_id", g.meta.MessageID, "event", event, "error", err)
+		return err
+	}
+	return nil
+}
+
+// StreamErrorCode maps a StreamComplete error to the code and message of
+// its "error" event.
+func StreamErrorCode(err error) (code, msg string) {
+	switch {
+	case errors.Is(err, ErrConversationForbidden):
+		return "forbidden", "forbidden"
+	case errors.Is(err, ErrConversationNotFound):
+		return "conversation_not_found", "conversation not found"
+	case errors.Is(err, ErrMessageNotFound):
+		return "message_not_found", "message not found"
+	case errors.Is(err, ErrModelNotAllowed):
+		return "model_not_allowed", err.Error()
+	case errors.Is(err, ErrContextOverflow):
+		return "context_overflow", err.Error()
+	case errors.Is(err, ErrNotAssistantMessage), errors.Is(err, ErrNotUserMessage):
+		return "invalid_request", err.Error()
+	default:
+		return "upstream_error", "inference failed: " + err.Error()
+	}
+}
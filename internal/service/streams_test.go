@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prakyathpnayak/roognis/internal/config"
+	"github.com/prakyathpnayak/roognis/internal/models"
+)
+
+func TestStreamErrorCode(t *testing.T) {
+	cases := map[error]string{
+		ErrConversationNotFound:                             "conversation_not_found",
+		fmt.Errorf("wrapped: %w", ErrConversationForbidden): "forbidden",
+		ErrNotUserMessage:                                   "invalid_request",
+		errors.New("connection reset"):                      "upstream_error",
+	}
+	for err, want := range cases {
+		if code, _ := StreamErrorCode(err); code != want {
+			t.Fatalf("StreamErrorCode(%v) = %q, want %q", err, code, want)
+		}
+	}
+}
+
+func TestStreamsCancel(t *testing.T) {
+	ctx := context.Background()
+	s := NewStreams(nil, NewStreamHub(nil, time.Minute), &config.Config{})
+	owner := &models.User{ID: uuid.New(), Role: models.RoleStudent}
+	gen := models.ActiveGeneration{MessageID: uuid.New(), UserID: owner.ID, StartedAt: time.Now()}
+
+	genCtx, cancel := context.WithCancel(ctx)
+	s.track(ctx, gen, cancel)
+
+	other := &models.User{ID: uuid.New(), Role: models.RoleTeacher}
+	if _, err := s.Cancel(ctx, gen.MessageID, other); !errors.Is(err, ErrGenerationNotFound) {
+		t.Fatalf("expected another user's cancel to be rejected, got %v", err)
+	}
+	if genCtx.Err() != nil {
+		t.Fatal("generation cancelled by another user")
+	}
+
+	admin := &models.User{ID: uuid.New(), Role: models.RoleAdmin}
+	if _, err := s.Cancel(ctx, gen.MessageID, admin); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if genCtx.Err() == nil {
+		t.Fatal("expected the generation to be cancelled")
+	}
+
+	go s.untrack(ctx, gen.MessageID)
+	if !s.WaitStopped(ctx, gen.MessageID) {
+		t.Fatal("expected the generation to have stopped")
+	}
+	if _, err := s.Cancel(ctx, gen.MessageID, owner); !errors.Is(err, ErrGenerationNotFound) {
+		t.Fatalf("expected a finished generation not to be found, got %v", err)
+	}
+}
+
+func TestStreamsGenerations(t *testing.T) {
+	ctx := context.Background()
+	s := NewStreams(nil, NewStreamHub(nil, time.Minute), &config.Config{})
+	alice, bob := uuid.New(), uuid.New()
+	now := time.Now()
+
+	s.track(ctx, models.ActiveGeneration{MessageID: uuid.New(), UserID: bob, StartedAt: now}, func() {})
+	s.track(ctx, models.ActiveGeneration{MessageID: uuid.New(), UserID: alice, StartedAt: now.Add(-time.Minute)}, func() {})
+
+	all, err := s.Generations(ctx, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(all) != 2 || all[0].UserID != alice {
+		t.Fatalf("expected both generations, oldest first, got %+v", all)
+	}
+
+	mine, _ := s.Generations(ctx, &bob)
+	if len(mine) != 1 || mine[0].UserID != bob {
+		t.Fatalf("expected bob's generation only, got %+v", mine)
+	}
+}
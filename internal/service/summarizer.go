@@ -0,0 +1,232 @@
+// Conversation summarizer — folds old turns into a rolling summary.
+// Maps to design.swift: Context Assembler (long-term conversation memory)
+//
+// Runs in the background: the orchestrator enqueues a conversation after each
+// turn, and once enough messages are not yet covered by a summary the oldest
+// of them are summarized together with the previous summary.
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prakyathpnayak/roognis/internal/config"
+	"github.com/prakyathpnayak/roognis/internal/db"
+	"github.com/prakyathpnayak/roognis/internal/models"
+)
+
+const summarizerQueueSize = 64
+
+const summarizerPrompt = "You maintain a running summary of a tutoring conversation between a student and an AI tutor. " +
+	"Update the summary with the new messages. Keep the facts the tutor needs later: the student's goals, " +
+	"what they said about themselves, questions asked, answers and explanations given, and open threads. " +
+	"Write concise third-person prose, at most a few paragraphs. Output only the summary."
+
+// Summarizer maintains rolling conversation summaries.
+type Summarizer struct {
+	llm  *LLM
+	pool *db.Pool
+	cfg  *config.Config
+
+	queue   chan uuid.UUID
+	mu      sync.Mutex
+	pending map[uuid.UUID]bool
+}
+
+// NewSummarizer creates the summarizer. Call Start to run it.
+func NewSummarizer(llm *LLM, pool *db.Pool, cfg *config.Config) *Summarizer {
+	return &Summarizer{
+		llm:     llm,
+		pool:    pool,
+		cfg:     cfg,
+		queue:   make(chan uuid.UUID, summarizerQueueSize),
+		pending: make(map[uuid.UUID]bool),
+	}
+}
+
+// Start processes queued conversations until ctx is cancelled.
+func (s *Summarizer) Start(ctx context.Context) {
+	if s == nil || s.cfg.LLMSummaryThreshold <= 0 {
+		return
+	}
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case convID := <-s.queue:
+				s.mu.Lock()
+				delete(s.pending, convID)
+				s.mu.Unlock()
+
+				jobCtx, cancel := context.WithTimeout(ctx, 2*time.Duration(s.cfg.LLMTimeoutSeconds)*time.Second)
+				if err := s.Summarize(jobCtx, convID); err != nil {
+					slog.Warn("summarizer.error", "conversation_id", convID, "error", err)
+				}
+				cancel()
+			}
+		}
+	}()
+}
+
+// Enqueue schedules a conversation for a summary check. It never blocks: a
+// conversation already queued is skipped, and so is any when the queue is full
+// (the next turn enqueues it again).
+func (s *Summarizer) Enqueue(convID uuid.UUID) {
+	if s == nil || s.cfg.LLMSummaryThreshold <= 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending[convID] {
+		return
+	}
+	select {
+	case s.queue <- convID:
+		s.pending[convID] = true
+	default:
+		slog.Debug("summarizer.queue_full", "conversation_id", convID)
+	}
+}
+
+// Summarize folds the oldest unsummarized messages on a conversation's active
+// branch into a new summary when more than LLM_SUMMARY_THRESHOLD of them have piled up.
+func (s *Summarizer) Summarize(ctx context.Context, convID uuid.UUID) error {
+	msgs, err := s.pool.GetConversationMessages(ctx, convID)
+	if err != nil {
+		return err
+	}
+	prev, err := s.pool.GetLatestConversationSummary(ctx, convID)
+	if err != nil {
+		return err
+	}
+	// A summary of another branch does not apply; start over on this one.
+	if prev != nil && !onBranch(msgs, prev.LastMessageID) {
+		prev = nil
+	}
+
+	rest := unsummarized(msgs, prev)
+	if len(rest) <= s.cfg.LLMSummaryThreshold {
+		return nil
+	}
+
+	cut := len(rest) - s.cfg.LLMSummaryKeepRecent
+	// Never separate tool results from the call that produced them.
+	for cut < len(rest) && rest[cut].Role == models.RoleToolMsg {
+		cut++
+	}
+	if cut <= 0 {
+		return nil
+	}
+	fold := rest[:cut]
+
+	model := s.cfg.LLMSummaryModel
+	if model == "" {
+		model = s.cfg.LLMModel
+	}
+
+	start := time.Now()
+	resp, err := s.llm.Complete(ctx, summaryRequest(prev, fold),
+		WithModel(model), WithTemperature(0.2), WithMaxTokens(s.cfg.LLMSummaryMaxTokens))
+	if err != nil {
+		return fmt.Errorf("summarizer: llm: %w", err)
+	}
+	if len(resp.Choices) == 0 || strings.TrimSpace(resp.Choices[0].Message.Content) == "" {
+		return fmt.Errorf("summarizer: llm returned an empty summary")
+	}
+
+	last := fold[len(fold)-1]
+	sum := &models.ConversationSummary{
+		ID:             uuid.New(),
+		ConversationID: convID,
+		Content:        strings.TrimSpace(resp.Choices[0].Message.Content),
+		FirstMessageID: fold[0].ID,
+		LastMessageID:  last.ID,
+		CoveredUntil:   last.CreatedAt,
+		MessageCount:   len(fold),
+		ModelUsed:      &resp.Model,
+	}
+	if prev != nil {
+		sum.FirstMessageID = prev.FirstMessageID
+		sum.MessageCount += prev.MessageCount
+	}
+	if tokens := resp.Usage.TotalTokens; tokens > 0 {
+		sum.TokenCount = &tokens
+	}
+	if err := s.pool.CreateConversationSummary(ctx, sum); err != nil {
+		return err
+	}
+
+	slog.Info("summarizer.summary_created",
+		"conversation_id", convID,
+		"folded_messages", len(fold),
+		"covered_messages", sum.MessageCount,
+		"latency_ms", time.Since(start).Milliseconds(),
+	)
+	return nil
+}
+
+// unsummarized returns the messages after the ones covered by sum.
+func unsummarized(msgs []models.Message, sum *models.ConversationSummary) []models.Message {
+	if sum == nil {
+		return msgs
+	}
+	for i, m := range msgs {
+		if m.ID == sum.LastMessageID {
+			return msgs[i+1:]
+		}
+	}
+	// The last covered message is gone (e.g. edited away); fall back to time.
+	for i, m := range msgs {
+		if m.CreatedAt.After(sum.CoveredUntil) {
+			return msgs[i:]
+		}
+	}
+	return nil
+}
+
+// summaryRequest builds the prompt that merges fold into the previous summary.
+func summaryRequest(prev *models.ConversationSummary, fold []models.Message) []models.LLMMessage {
+	var b strings.Builder
+	if prev != nil {
+		b.WriteString("Current summary:\n")
+		b.WriteString(prev.Content)
+		b.WriteString("\n\n")
+	}
+	b.WriteString("New messages:\n")
+	for _, m := range fold {
+		switch {
+		case m.Role == models.RoleToolMsg:
+			fmt.Fprintf(&b, "[tool result] %s\n", m.Content)
+		case len(m.ToolCalls) > 0:
+			for _, tc := range m.ToolCalls {
+				fmt.Fprintf(&b, "[tutor called %s(%s)]\n", tc.Function.Name, tc.Function.Arguments)
+			}
+			if m.Content != "" {
+				fmt.Fprintf(&b, "%s: %s\n", m.Role, m.Content)
+			}
+		default:
+			fmt.Fprintf(&b, "%s: %s\n", m.Role, m.Content)
+		}
+	}
+
+	return []models.LLMMessage{
+		{Role: "system", Content: summarizerPrompt},
+		{Role: "user", Content: b.String()},
+	}
+}
+
+// summaryMessage renders a stored summary as the system message injected
+// ahead of the recent history.
+func summaryMessage(sum *models.ConversationSummary) models.LLMMessage {
+	return models.LLMMessage{
+		Role:    "system",
+		Content: "Summary of the earlier part of this conversation (those messages are not shown):\n" + sum.Content,
+	}
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prakyathpnayak/roognis/internal/models"
+)
+
+func testHistory(n int) []models.Message {
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	msgs := make([]models.Message, n)
+	for i := range msgs {
+		role := models.RoleUserMsg
+		if i%2 == 1 {
+			role = models.RoleAssistantMsg
+		}
+		msgs[i] = models.Message{ID: uuid.New(), Role: role, Content: "m", CreatedAt: base.Add(time.Duration(i) * time.Second)}
+	}
+	return msgs
+}
+
+func TestUnsummarized(t *testing.T) {
+	msgs := testHistory(6)
+
+	if got := unsummarized(msgs, nil); len(got) != 6 {
+		t.Fatalf("expected all messages without a summary, got %d", len(got))
+	}
+
+	sum := &models.ConversationSummary{LastMessageID: msgs[2].ID, CoveredUntil: msgs[2].CreatedAt}
+	if got := unsummarized(msgs, sum); len(got) != 3 || got[0].ID != msgs[3].ID {
+		t.Fatalf("expected messages after the covered one, got %d", len(got))
+	}
+
+	// The covered message was deleted: fall back to covered_until.
+	sum.LastMessageID = uuid.New()
+	if got := unsummarized(msgs, sum); len(got) != 3 || got[0].ID != msgs[3].ID {
+		t.Fatalf("expected time-based fallback, got %d", len(got))
+	}
+}
+
+func TestSummaryRequestIncludesPreviousSummary(t *testing.T) {
+	prev := &models.ConversationSummary{Content: "The student is learning fractions."}
+	fold := []models.Message{
+		{Role: models.RoleUserMsg, Content: "what is 1/2 + 1/4?"},
+		{Role: models.RoleAssistantMsg, ToolCalls: []models.LLMToolCall{{Function: models.LLMFunctionCall{Name: "calculator", Arguments: `{"expression":"1/2+1/4"}`}}}},
+		{Role: models.RoleToolMsg, Content: "0.75"},
+		{Role: models.RoleAssistantMsg, Content: "It is 3/4."},
+	}
+
+	msgs := summaryRequest(prev, fold)
+	if len(msgs) != 2 || msgs[0].Role != "system" {
+		t.Fatalf("unexpected request shape: %+v", msgs)
+	}
+	body := msgs[1].Content
+	for _, want := range []string{"learning fractions", "user: what is 1/2 + 1/4?", "[tutor called calculator(", "[tool result] 0.75", "assistant: It is 3/4."} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected %q in summary request:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Conversation titler — names conversations after the first reply.
+// Maps to design.swift: Interaction Logger (conversation metadata)
+package service
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/prakyathpnayak/roognis/internal/config"
+	"github.com/prakyathpnayak/roognis/internal/db"
+	"github.com/prakyathpnayak/roognis/internal/models"
+)
+
+const (
+	maxTitleRunes       = 80
+	maxConcurrentTitles = 4
+)
+
+const titlerPrompt = "Write a short title (at most 6 words) for a tutoring conversation that starts with the exchange below. " +
+	"Reply with the title only: no quotes, no trailing punctuation."
+
+// Titler generates conversation titles in the background.
+type Titler struct {
+	llm  *LLM
+	pool *db.Pool
+	cfg  *config.Config
+	sem  chan struct{}
+}
+
+// NewTitler creates the conversation titler.
+func NewTitler(llm *LLM, pool *db.Pool, cfg *config.Config) *Titler {
+	return &Titler{
+		llm:  llm,
+		pool: pool,
+		cfg:  cfg,
+		sem:  make(chan struct{}, maxConcurrentTitles),
+	}
+}
+
+// Schedule generates a title for conv from its first exchange, unless it
+// already has one. At most a few titles are generated at once; when busy the
+// request is dropped and retried after the next reply, since the
+// conversation is still untitled.
+func (t *Titler) Schedule(conv *models.Conversation, prompt, reply string) {
+	if t == nil || !t.cfg.LLMTitleEnabled || conv.Title != nil || conv.TitleSetByUser {
+		return
+	}
+
+	select {
+	case t.sem <- struct{}{}:
+	default:
+		slog.Debug("titler.busy", "conversation_id", conv.ID)
+		return
+	}
+
+	go func() {
+		defer func() { <-t.sem }()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(t.cfg.LLMTimeoutSeconds)*time.Second)
+		defer cancel()
+		if err := t.generate(ctx, conv.ID, prompt, reply); err != nil {
+			slog.Warn("titler.error", "conversation_id", conv.ID, "error", err)
+		}
+	}()
+}
+
+func (t *Titler) generate(ctx context.Context, convID uuid.UUID, prompt, reply string) error {
+	model := t.cfg.LLMTitleModel
+	if model == "" {
+		model = t.cfg.LLMModel
+	}
+
+	resp, err := t.llm.Complete(ctx, []models.LLMMessage{
+		{Role: "system", Content: titlerPrompt},
+		{Role: "user", Content: "Student: " + truncateRunes(prompt, 2000) + "\n\nTutor: " + truncateRunes(reply, 2000)},
+	}, WithModel(model), WithTemperature(0.3), WithMaxTokens(24))
+	if err != nil {
+		return err
+	}
+	if len(resp.Choices) == 0 {
+		return nil
+	}
+
+	title := cleanTitle(resp.Choices[0].Message.Content)
+	if title == "" {
+		return nil
+	}
+	updated, err := t.pool.SetGeneratedConversationTitle(ctx, convID, title)
+	if err != nil {
+		return err
+	}
+	slog.Info("titler.title_set", "conversation_id", convID, "updated", updated)
+	return nil
+}
+
+// cleanTitle keeps the first line of the model output, without quotes,
+// markdown emphasis, a "Title:" label or trailing punctuation.
+func cleanTitle(s string) string {
+	s, _, _ = strings.Cut(strings.TrimSpace(s), "\n")
+	s = strings.TrimSpace(s)
+	if label, rest, ok := strings.Cut(s, ":"); ok && strings.EqualFold(strings.TrimSpace(label), "title") {
+		s = strings.TrimSpace(rest)
+	}
+	s = strings.Trim(s, "\"'`*#“”‘’ ")
+	s = strings.TrimRightFunc(s, func(r rune) bool { return unicode.IsPunct(r) && r != ')' && r != '?' })
+	return truncateRunes(strings.TrimSpace(s), maxTitleRunes)
+}
+
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
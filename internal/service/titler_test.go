@@ -0,0 +1,28 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestCleanTitle(t *testing.T) {
+	tests := map[string]string{
+		"Photosynthesis Basics":                 "Photosynthesis Basics",
+		`"Solving Quadratic Equations."`:        "Solving Quadratic Equations",
+		"Title: **Fractions and Decimals**":     "Fractions and Decimals",
+		"World War I Causes\nSome extra prose.": "World War I Causes",
+		"What Is Gravity?":                      "What Is Gravity?",
+		"   ":                                   "",
+	}
+	for in, want := range tests {
+		if got := cleanTitle(in); got != want {
+			t.Fatalf("cleanTitle(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	long := cleanTitle(strings.Repeat("word ", 40))
+	if utf8.RuneCountInString(long) > maxTitleRunes {
+		t.Fatalf("title not truncated: %d runes", utf8.RuneCountInString(long))
+	}
+}
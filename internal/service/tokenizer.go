@@ -0,0 +1,349 @@
+// Tokenizers — token counting for context-window budgeting.
+// Maps to design.swift: Context Assembler (token budget)
+//
+// BPETokenizer reads a tiktoken rank file (e.g. cl100k_base.tiktoken) so
+// counts match OpenAI-style models exactly; HeuristicTokenizer is a cheap,
+// deliberately conservative estimate for everything else.
+package service
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/prakyathpnayak/roognis/internal/config"
+	"github.com/prakyathpnayak/roognis/internal/models"
+)
+
+// Tokenizer counts tokens the way a model family does.
+type Tokenizer interface {
+	Name() string
+	Count(text string) int
+}
+
+// Chat formats wrap every message in a few control tokens and prime the
+// reply with a few more; these match OpenAI's published accounting.
+const (
+	tokensPerMessage = 4
+	tokensPerReply   = 3
+)
+
+// CountMessage returns the tokens a chat message occupies in the prompt.
+func CountMessage(tok Tokenizer, m models.LLMMessage) int {
+	n := tokensPerMessage + tok.Count(m.Content)
+	for _, tc := range m.ToolCalls {
+		n += tok.Count(tc.Function.Name) + tok.Count(tc.Function.Arguments)
+	}
+	return n
+}
+
+// ── Heuristic ───────────────────────────────────────────────────────
+
+// HeuristicTokenizer estimates ~4 ASCII characters per token and one token
+// per non-ASCII character, which over- rather than under-counts for most
+// scripts.
+type HeuristicTokenizer struct{}
+
+// Name implements Tokenizer.
+func (HeuristicTokenizer) Name() string { return "heuristic" }
+
+// Count implements Tokenizer.
+func (HeuristicTokenizer) Count(text string) int {
+	ascii, other := 0, 0
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return (ascii+3)/4 + other
+}
+
+// ── Byte-pair encoding ──────────────────────────────────────────────
+
+// BPETokenizer implements tiktoken-style byte-level BPE.
+type BPETokenizer struct {
+	name  string
+	ranks map[string]int
+}
+
+// LoadBPETokenizer reads a tiktoken rank file: one "<base64 token> <rank>"
+// pair per line.
+func LoadBPETokenizer(name, path string) (*BPETokenizer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("tokenizer: open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	ranks := make(map[string]int, 100_000)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		tokenB64, rankStr, ok := strings.Cut(text, " ")
+		if !ok {
+			return nil, fmt.Errorf("tokenizer: %s:%d: malformed line", path, line)
+		}
+		token, err := base64.StdEncoding.DecodeString(tokenB64)
+		if err != nil {
+			return nil, fmt.Errorf("tokenizer: %s:%d: %w", path, line, err)
+		}
+		rank, err := strconv.Atoi(rankStr)
+		if err != nil {
+			return nil, fmt.Errorf("tokenizer: %s:%d: %w", path, line, err)
+		}
+		ranks[string(token)] = rank
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("tokenizer: read %s: %w", path, err)
+	}
+	return &BPETokenizer{name: name, ranks: ranks}, nil
+}
+
+// Name implements Tokenizer.
+func (t *BPETokenizer) Name() string { return t.name }
+
+// Count implements Tokenizer.
+func (t *BPETokenizer) Count(text string) int {
+	n := 0
+	for _, piece := range splitPretokens(text) {
+		n += t.countPiece(piece)
+	}
+	return n
+}
+
+// countPiece applies BPE merges to one pre-token, always merging the
+// adjacent pair with the lowest rank (leftmost on ties), and returns the
+// resulting token count. Parts form a linked list and candidate pairs a
+// heap, so long pieces cost O(n log n) rather than O(n²).
+func (t *BPETokenizer) countPiece(piece string) int {
+	if _, ok := t.ranks[piece]; ok {
+		return 1
+	}
+
+	// Parts are named by their start offset. next[i] is the start of the
+	// part after part i (len(piece) for the last one), prev[i] the start of
+	// the one before (-1 for the first); next is -1 once i is merged away.
+	n := len(piece)
+	next := make([]int, n)
+	prev := make([]int, n)
+	for i := range next {
+		next[i], prev[i] = i+1, i-1
+	}
+	var queue mergeQueue
+	candidate := func(left int) {
+		if left < 0 || next[left] >= n {
+			return
+		}
+		right := next[left]
+		if r, ok := t.ranks[piece[left:next[right]]]; ok {
+			heap.Push(&queue, mergeCandidate{rank: r, left: left, right: right, end: next[right]})
+		}
+	}
+	for i := 0; i+1 < n; i++ {
+		candidate(i)
+	}
+
+	parts := n
+	for queue.Len() > 0 {
+		c := heap.Pop(&queue).(mergeCandidate)
+		// Skip pairs an earlier merge has changed.
+		if next[c.left] != c.right || next[c.right] != c.end {
+			continue
+		}
+		next[c.left] = c.end
+		if c.end < n {
+			prev[c.end] = c.left
+		}
+		next[c.right] = -1
+		parts--
+		candidate(prev[c.left])
+		candidate(c.left)
+	}
+	return parts
+}
+
+// mergeCandidate is a pair of adjacent parts, piece[left:right] and
+// piece[right:end], whose concatenation has a rank.
+type mergeCandidate struct {
+	rank, left, right, end int
+}
+
+// mergeQueue is a heap of merge candidates, lowest rank then leftmost first.
+type mergeQueue []mergeCandidate
+
+func (q mergeQueue) Len() int { return len(q) }
+func (q mergeQueue) Less(i, j int) bool {
+	if q[i].rank != q[j].rank {
+		return q[i].rank < q[j].rank
+	}
+	return q[i].left < q[j].left
+}
+func (q mergeQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *mergeQueue) Push(x any)   { *q = append(*q, x.(mergeCandidate)) }
+func (q *mergeQueue) Pop() any {
+	old := *q
+	c := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return c
+}
+
+// splitPretokens splits text the way the cl100k pre-tokenizer regex does:
+//
+//	(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}|
+//	 ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+
+//
+// Go's regexp has no lookahead, hence the hand-written scanner.
+func splitPretokens(text string) []string {
+	rs := []rune(text)
+	var out []string
+	at := func(i int) rune {
+		if i < len(rs) {
+			return rs[i]
+		}
+		return -1
+	}
+	isLetter := func(r rune) bool { return r >= 0 && unicode.IsLetter(r) }
+	isNumber := func(r rune) bool { return r >= 0 && unicode.IsNumber(r) }
+	isSpace := func(r rune) bool { return r >= 0 && unicode.IsSpace(r) }
+	isNewline := func(r rune) bool { return r == '\r' || r == '\n' }
+	isOther := func(r rune) bool { return r >= 0 && !isSpace(r) && !isLetter(r) && !isNumber(r) }
+
+	for i := 0; i < len(rs); {
+		start := i
+		r := rs[i]
+
+		switch {
+		// Contractions
+		case r == '\'' && contractionLen(rs[i+1:]) > 0:
+			i += 1 + contractionLen(rs[i+1:])
+
+		// Optional non-letter prefix, then letters
+		case isLetter(r) || (!isNewline(r) && !isLetter(r) && !isNumber(r) && isLetter(at(i+1))):
+			if !isLetter(r) {
+				i++
+			}
+			for isLetter(at(i)) {
+				i++
+			}
+
+		// Up to three digits
+		case isNumber(r):
+			for i < len(rs) && i-start < 3 && isNumber(rs[i]) {
+				i++
+			}
+
+		// Optional space, punctuation run, trailing newlines
+		case isOther(r) || (r == ' ' && isOther(at(i+1))):
+			if r == ' ' {
+				i++
+			}
+			for isOther(at(i)) {
+				i++
+			}
+			for isNewline(at(i)) {
+				i++
+			}
+
+		// Whitespace
+		default:
+			j := i
+			lastNL := -1
+			for isSpace(at(j)) {
+				if isNewline(rs[j]) {
+					lastNL = j
+				}
+				j++
+			}
+			switch {
+			case lastNL >= 0:
+				i = lastNL + 1
+			case j < len(rs) && j-i > 1:
+				i = j - 1 // leave one space to prefix the next word
+			default:
+				i = j
+			}
+		}
+
+		out = append(out, string(rs[start:i]))
+	}
+	return out
+}
+
+func contractionLen(rs []rune) int {
+	lower := func(i int) rune {
+		if i < len(rs) {
+			return unicode.ToLower(rs[i])
+		}
+		return -1
+	}
+	switch lower(0) {
+	case 's', 't', 'm', 'd':
+		return 1
+	case 'r', 'v':
+		if lower(1) == 'e' {
+			return 2
+		}
+	case 'l':
+		if lower(1) == 'l' {
+			return 2
+		}
+	}
+	return 0
+}
+
+// ── Selection ───────────────────────────────────────────────────────
+
+// Tokenizers picks the tokenizer for each model.
+type Tokenizers struct {
+	bpe       Tokenizer // nil when LLM_TOKENIZER_PATH is unset or unreadable
+	heuristic Tokenizer
+}
+
+// NewTokenizers loads the BPE ranks from LLM_TOKENIZER_PATH when set. A
+// missing or corrupt file is logged and counting falls back to the heuristic.
+func NewTokenizers(cfg *config.Config) *Tokenizers {
+	t := &Tokenizers{heuristic: HeuristicTokenizer{}}
+	if cfg.LLMTokenizerPath == "" {
+		return t
+	}
+
+	bpe, err := LoadBPETokenizer("cl100k", cfg.LLMTokenizerPath)
+	if err != nil {
+		slog.Warn("tokenizer.load_failed", "path", cfg.LLMTokenizerPath, "error", err)
+		return t
+	}
+	slog.Info("tokenizer.loaded", "name", bpe.Name(), "tokens", len(bpe.ranks))
+	t.bpe = bpe
+	return t
+}
+
+// For returns the tokenizer declared for m ("cl100k" or "heuristic"). When
+// the model declares none, the BPE tokenizer is used if it is loaded.
+func (t *Tokenizers) For(m config.ModelConfig) Tokenizer {
+	if t == nil {
+		return HeuristicTokenizer{}
+	}
+	switch m.Tokenizer {
+	case "heuristic":
+		return t.heuristic
+	case "", "cl100k":
+		if t.bpe != nil {
+			return t.bpe
+		}
+	default:
+		slog.Debug("tokenizer.unknown", "model", m.Name, "tokenizer", m.Tokenizer)
+	}
+	return t.heuristic
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitPretokens(t *testing.T) {
+	tests := map[string][]string{
+		"Hello world":        {"Hello", " world"},
+		"I'm here, aren't I": {"I", "'m", " here", ",", " aren", "'t", " I"},
+		"x = 12345;":         {"x", " =", " ", "123", "45", ";"},
+		"a  b\n\nc":          {"a", " ", " b", "\n\n", "c"},
+		"(hello)":            {"(hello", ")"},
+		"end   ":             {"end", "   "},
+	}
+	for in, want := range tests {
+		if got := splitPretokens(in); !reflect.DeepEqual(got, want) {
+			t.Fatalf("splitPretokens(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// writeRanks writes a tiktoken-format rank file: every single byte, then the
+// given merges in rank order.
+func writeRanks(t testing.TB, merges ...string) string {
+	t.Helper()
+	var b strings.Builder
+	rank := 0
+	for i := 0; i < 256; i++ {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte{byte(i)}), rank)
+		rank++
+	}
+	for _, m := range merges {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(m)), rank)
+		rank++
+	}
+	path := filepath.Join(t.TempDir(), "ranks.tiktoken")
+	if err := os.WriteFile(path, []byte(b.String()), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBPETokenizerCount(t *testing.T) {
+	tok, err := LoadBPETokenizer("test", writeRanks(t, "he", "ll", "llo", "hello", " w", " wo"))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	tests := map[string]int{
+		"":            0,
+		"hello":       1, // whole piece is a token
+		"hellx":       3, // he + ll + x
+		" world":      4, // " wo" + r + l + d
+		"hello world": 5,
+	}
+	for in, want := range tests {
+		if got := tok.Count(in); got != want {
+			t.Fatalf("Count(%q) = %d, want %d", in, got, want)
+		}
+	}
+}
+
+// TestBPETokenizerMergeOrder checks the heap-based merge against the plain
+// rescan-every-pair definition of BPE.
+func TestBPETokenizerMergeOrder(t *testing.T) {
+	tok, err := LoadBPETokenizer("test", writeRanks(t, "ab", "bc", "ca", "abc", "bca", "aa", "aaa", "abca"))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	rng := rand.New(rand.NewPCG(1, 2))
+	for range 500 {
+		b := make([]byte, rng.IntN(40)+1)
+		for i := range b {
+			b[i] = "abc"[rng.IntN(3)]
+		}
+		piece := string(b)
+		if got, want := tok.countPiece(piece), naiveCountPiece(tok, piece); got != want {
+			t.Fatalf("countPiece(%q) = %d, want %d", piece, got, want)
+		}
+	}
+}
+
+// naiveCountPiece merges the lowest-ranked, leftmost pair until none is left,
+// rescanning every pair after each merge.
+func naiveCountPiece(tok *BPETokenizer, piece string) int {
+	parts := strings.Split(piece, "")
+	for {
+		best, bestRank := -1, 0
+		for i := 0; i+1 < len(parts); i++ {
+			if r, ok := tok.ranks[parts[i]+parts[i+1]]; ok && (best < 0 || r < bestRank) {
+				best, bestRank = i, r
+			}
+		}
+		if best < 0 {
+			return len(parts)
+		}
+		parts = append(parts[:best], append([]string{parts[best] + parts[best+1]}, parts[best+2:]...)...)
+	}
+}
+
+// BenchmarkBPETokenizerLongPiece counts one 32k-rune pre-token, the worst case
+// for merging.
+func BenchmarkBPETokenizerLongPiece(b *testing.B) {
+	tok, err := LoadBPETokenizer("test", writeRanks(b, "he", "ll", "llo", "hello", "oh", "ohe"))
+	if err != nil {
+		b.Fatalf("load: %v", err)
+	}
+	piece := strings.Repeat("hello", 32<<10/5)
+	if n := len(splitPretokens(piece)); n != 1 {
+		b.Fatalf("got %d pre-tokens, want 1", n)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tok.Count(piece)
+	}
+}
+
+func TestHeuristicTokenizerCount(t *testing.T) {
+	var tok HeuristicTokenizer
+	if got := tok.Count("abcdefgh"); got != 2 {
+		t.Fatalf("expected 2 tokens for 8 ASCII chars, got %d", got)
+	}
+	if got := tok.Count("日本語"); got != 3 {
+		t.Fatalf("expected one token per non-ASCII char, got %d", got)
+	}
+}
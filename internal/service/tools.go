@@ -0,0 +1,165 @@
+// Tool registry — server-side functions exposed to the model (function calling).
+// Maps to design.swift: Prompt Orchestrator (tool use loop)
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prakyathpnayak/roognis/internal/models"
+)
+
+// ErrUnknownTool is returned when the model calls a tool that is not registered.
+var ErrUnknownTool = errors.New("unknown tool")
+
+const (
+	toolCallTimeout    = 10 * time.Second
+	maxToolResultBytes = 8 << 10
+)
+
+// Tool is a server-side function the model may call.
+type Tool interface {
+	// Name is the function name advertised to the model.
+	Name() string
+	// Description tells the model when to use the tool.
+	Description() string
+	// Parameters is the JSON Schema of the arguments object.
+	Parameters() json.RawMessage
+	// Call executes the tool and returns the text handed back to the model.
+	Call(ctx context.Context, inv ToolInvocation) (string, error)
+}
+
+// ToolInvocation carries the call arguments and the caller's scope.
+type ToolInvocation struct {
+	UserID         uuid.UUID
+	ConversationID uuid.UUID
+	Arguments      json.RawMessage
+}
+
+// ToolRegistry holds the tools offered to the model.
+type ToolRegistry struct {
+	tools map[string]Tool
+}
+
+// NewToolRegistry creates a registry with the given tools.
+func NewToolRegistry(tools ...Tool) *ToolRegistry {
+	r := &ToolRegistry{tools: make(map[string]Tool, len(tools))}
+	for _, t := range tools {
+		r.tools[t.Name()] = t
+	}
+	return r
+}
+
+// Definitions returns the tool declarations sent with LLM requests, sorted
+// by name so requests (and cache keys) are deterministic.
+func (r *ToolRegistry) Definitions() []models.LLMTool {
+	if r == nil || len(r.tools) == 0 {
+		return nil
+	}
+	defs := make([]models.LLMTool, 0, len(r.tools))
+	for _, t := range r.tools {
+		defs = append(defs, models.LLMTool{
+			Type: "function",
+			Function: models.LLMFunctionDef{
+				Name:        t.Name(),
+				Description: t.Description(),
+				Parameters:  t.Parameters(),
+			},
+		})
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Function.Name < defs[j].Function.Name })
+	return defs
+}
+
+// Execute runs one tool call. Failures are returned both as err and as a
+// result string so the model can see what went wrong and recover.
+func (r *ToolRegistry) Execute(ctx context.Context, call models.LLMToolCall, userID, conversationID uuid.UUID) (string, error) {
+	var t Tool
+	var ok bool
+	if r != nil {
+		t, ok = r.tools[call.Function.Name]
+	}
+	if !ok {
+		err := fmt.Errorf("%w: %q", ErrUnknownTool, call.Function.Name)
+		return "error: " + err.Error(), err
+	}
+
+	args := json.RawMessage(call.Function.Arguments)
+	if len(args) == 0 {
+		args = json.RawMessage("{}")
+	}
+	if !json.Valid(args) {
+		err := fmt.Errorf("tool %s: arguments are not valid JSON", t.Name())
+		return "error: " + err.Error(), err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, toolCallTimeout)
+	defer cancel()
+
+	start := time.Now()
+	result, err := t.Call(ctx, ToolInvocation{UserID: userID, ConversationID: conversationID, Arguments: args})
+	slog.Info("tools.call",
+		"tool", t.Name(),
+		"conversation_id", conversationID,
+		"latency_ms", time.Since(start).Milliseconds(),
+		"error", err,
+	)
+	if err != nil {
+		return "error: " + err.Error(), err
+	}
+
+	if len(result) > maxToolResultBytes {
+		result = strings.ToValidUTF8(result[:maxToolResultBytes], "") + "…[truncated]"
+	}
+	return result, nil
+}
+
+// toolCallAccumulator reassembles tool calls from streamed deltas, where the
+// id and name arrive first and the arguments in fragments keyed by index.
+type toolCallAccumulator struct {
+	calls []models.LLMToolCall
+	byIdx map[int]int
+}
+
+// Add merges one chunk's tool-call deltas.
+func (a *toolCallAccumulator) Add(deltas []models.LLMToolCall) {
+	if a.byIdx == nil {
+		a.byIdx = make(map[int]int)
+	}
+	for _, d := range deltas {
+		pos := -1
+		if d.Index != nil {
+			if p, ok := a.byIdx[*d.Index]; ok {
+				pos = p
+			}
+		}
+		if pos < 0 {
+			a.calls = append(a.calls, models.LLMToolCall{Type: "function"})
+			pos = len(a.calls) - 1
+			if d.Index != nil {
+				a.byIdx[*d.Index] = pos
+			}
+		}
+
+		c := &a.calls[pos]
+		if d.ID != "" {
+			c.ID = d.ID
+		}
+		if d.Function.Name != "" {
+			c.Function.Name = d.Function.Name
+		}
+		c.Function.Arguments += d.Function.Arguments
+	}
+}
+
+// Calls returns the assembled calls in arrival order, without stream indexes.
+func (a *toolCallAccumulator) Calls() []models.LLMToolCall {
+	return a.calls
+}
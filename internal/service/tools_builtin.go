@@ -0,0 +1,328 @@
+// Built-in tools: arithmetic calculator and conversation-history lookup.
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/prakyathpnayak/roognis/internal/db"
+)
+
+// ── Calculator ──────────────────────────────────────────────────────
+
+// CalculatorTool evaluates arithmetic expressions so the model does not have
+// to do mental math.
+type CalculatorTool struct{}
+
+// Name implements Tool.
+func (CalculatorTool) Name() string { return "calculator" }
+
+// Description implements Tool.
+func (CalculatorTool) Description() string {
+	return "Evaluate an arithmetic expression. Supports + - * / % ^, parentheses, " +
+		"and the functions sqrt, abs, ln, log10, sin, cos, tan. Constants: pi, e."
+}
+
+// Parameters implements Tool.
+func (CalculatorTool) Parameters() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"expression": {"type": "string", "description": "Expression to evaluate, e.g. (3 + 4) * 2^3"}
+		},
+		"required": ["expression"],
+		"additionalProperties": false
+	}`)
+}
+
+// Call implements Tool.
+func (CalculatorTool) Call(ctx context.Context, inv ToolInvocation) (string, error) {
+	var args struct {
+		Expression string `json:"expression"`
+	}
+	if err := json.Unmarshal(inv.Arguments, &args); err != nil {
+		return "", fmt.Errorf("calculator: invalid arguments: %w", err)
+	}
+
+	v, err := EvalExpression(args.Expression)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatFloat(v, 'g', 15, 64), nil
+}
+
+// EvalExpression evaluates an arithmetic expression with a recursive-descent
+// parser. Precedence (low → high): + -, * / %, unary -, ^ (right-assoc).
+func EvalExpression(expr string) (float64, error) {
+	p := &exprParser{src: expr}
+	v, err := p.parseSum()
+	if err != nil {
+		return 0, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return 0, fmt.Errorf("calculator: unexpected %q at position %d", p.src[p.pos], p.pos)
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("calculator: result is not a finite number")
+	}
+	return v, nil
+}
+
+type exprParser struct {
+	src string
+	pos int
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *exprParser) peek() byte {
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+func (p *exprParser) parseSum() (float64, error) {
+	left, err := p.parseProduct()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		op := p.peek()
+		if op != '+' && op != '-' {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseProduct()
+		if err != nil {
+			return 0, err
+		}
+		if op == '+' {
+			left += right
+		} else {
+			left -= right
+		}
+	}
+}
+
+func (p *exprParser) parseProduct() (float64, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		op := p.peek()
+		if op != '*' && op != '/' && op != '%' {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return 0, err
+		}
+		switch op {
+		case '*':
+			left *= right
+		case '/', '%':
+			if right == 0 {
+				return 0, fmt.Errorf("calculator: division by zero")
+			}
+			if op == '/' {
+				left /= right
+			} else {
+				left = math.Mod(left, right)
+			}
+		}
+	}
+}
+
+func (p *exprParser) parseUnary() (float64, error) {
+	switch p.peek() {
+	case '-':
+		p.pos++
+		v, err := p.parseUnary()
+		return -v, err
+	case '+':
+		p.pos++
+		return p.parseUnary()
+	}
+	return p.parsePower()
+}
+
+func (p *exprParser) parsePower() (float64, error) {
+	base, err := p.parseAtom()
+	if err != nil {
+		return 0, err
+	}
+	if p.peek() == '^' {
+		p.pos++
+		exp, err := p.parseUnary()
+		if err != nil {
+			return 0, err
+		}
+		return math.Pow(base, exp), nil
+	}
+	return base, nil
+}
+
+var calculatorFuncs = map[string]func(float64) float64{
+	"sqrt":  math.Sqrt,
+	"abs":   math.Abs,
+	"ln":    math.Log,
+	"log10": math.Log10,
+	"sin":   math.Sin,
+	"cos":   math.Cos,
+	"tan":   math.Tan,
+}
+
+var calculatorConsts = map[string]float64{
+	"pi": math.Pi,
+	"e":  math.E,
+}
+
+func (p *exprParser) parseAtom() (float64, error) {
+	c := p.peek()
+	switch {
+	case c == '(':
+		p.pos++
+		v, err := p.parseSum()
+		if err != nil {
+			return 0, err
+		}
+		if p.peek() != ')' {
+			return 0, fmt.Errorf("calculator: missing closing parenthesis")
+		}
+		p.pos++
+		return v, nil
+
+	case c >= '0' && c <= '9' || c == '.':
+		start := p.pos
+		for p.pos < len(p.src) && (p.src[p.pos] >= '0' && p.src[p.pos] <= '9' || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		// Optional exponent, e.g. 1.5e-3
+		if p.pos < len(p.src) && (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') {
+			save := p.pos
+			p.pos++
+			if p.pos < len(p.src) && (p.src[p.pos] == '+' || p.src[p.pos] == '-') {
+				p.pos++
+			}
+			if p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+				for p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+					p.pos++
+				}
+			} else {
+				p.pos = save
+			}
+		}
+		v, err := strconv.ParseFloat(p.src[start:p.pos], 64)
+		if err != nil {
+			return 0, fmt.Errorf("calculator: invalid number %q", p.src[start:p.pos])
+		}
+		return v, nil
+
+	case c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+		start := p.pos
+		for p.pos < len(p.src) && (unicode.IsLetter(rune(p.src[p.pos])) || unicode.IsDigit(rune(p.src[p.pos]))) {
+			p.pos++
+		}
+		name := strings.ToLower(p.src[start:p.pos])
+		if v, ok := calculatorConsts[name]; ok {
+			return v, nil
+		}
+		fn, ok := calculatorFuncs[name]
+		if !ok {
+			return 0, fmt.Errorf("calculator: unknown identifier %q", name)
+		}
+		if p.peek() != '(' {
+			return 0, fmt.Errorf("calculator: expected '(' after %s", name)
+		}
+		arg, err := p.parseAtom()
+		if err != nil {
+			return 0, err
+		}
+		return fn(arg), nil
+
+	case c == 0:
+		return 0, fmt.Errorf("calculator: unexpected end of expression")
+	}
+	return 0, fmt.Errorf("calculator: unexpected %q at position %d", c, p.pos)
+}
+
+// ── Conversation history lookup ─────────────────────────────────────
+
+// HistoryLookupTool searches earlier turns of the current conversation, which
+// lets the model recall details that fell outside the history window.
+type HistoryLookupTool struct {
+	pool *db.Pool
+}
+
+// NewHistoryLookupTool creates the conversation-history lookup tool.
+func NewHistoryLookupTool(pool *db.Pool) *HistoryLookupTool {
+	return &HistoryLookupTool{pool: pool}
+}
+
+// Name implements Tool.
+func (t *HistoryLookupTool) Name() string { return "conversation_history_lookup" }
+
+// Description implements Tool.
+func (t *HistoryLookupTool) Description() string {
+	return "Search earlier messages in the current conversation for a word or phrase. " +
+		"Use it when the student refers to something said before that is not in the visible history."
+}
+
+// Parameters implements Tool.
+func (t *HistoryLookupTool) Parameters() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"query": {"type": "string", "description": "Word or phrase to search for"},
+			"limit": {"type": "integer", "minimum": 1, "maximum": 20, "description": "Maximum number of messages to return (default 5)"}
+		},
+		"required": ["query"],
+		"additionalProperties": false
+	}`)
+}
+
+// Call implements Tool.
+func (t *HistoryLookupTool) Call(ctx context.Context, inv ToolInvocation) (string, error) {
+	var args struct {
+		Query string `json:"query"`
+		Limit int    `json:"limit"`
+	}
+	if err := json.Unmarshal(inv.Arguments, &args); err != nil {
+		return "", fmt.Errorf("history lookup: invalid arguments: %w", err)
+	}
+	args.Query = strings.TrimSpace(args.Query)
+	if args.Query == "" {
+		return "", fmt.Errorf("history lookup: query is required")
+	}
+	if args.Limit <= 0 || args.Limit > 20 {
+		args.Limit = 5
+	}
+
+	msgs, err := t.pool.SearchConversationMessages(ctx, inv.ConversationID, args.Query, args.Limit)
+	if err != nil {
+		return "", err
+	}
+	if len(msgs) == 0 {
+		return "No earlier messages matched.", nil
+	}
+
+	var b strings.Builder
+	for _, m := range msgs {
+		fmt.Fprintf(&b, "[%s] %s: %s\n", m.CreatedAt.UTC().Format("2006-01-02 15:04"), m.Role, m.Content)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/prakyathpnayak/roognis/internal/models"
+)
+
+func TestEvalExpression(t *testing.T) {
+	tests := []struct {
+		expr string
+		want float64
+	}{
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"2 ^ 3 ^ 2", 512},
+		{"-2 ^ 2", -4},
+		{"10 % 4", 2},
+		{"sqrt(16) + abs(-3)", 7},
+		{"1.5e2 / 3", 50},
+		{"2 * pi", 2 * math.Pi},
+	}
+	for _, tt := range tests {
+		got, err := EvalExpression(tt.expr)
+		if err != nil {
+			t.Fatalf("EvalExpression(%q): %v", tt.expr, err)
+		}
+		if math.Abs(got-tt.want) > 1e-9 {
+			t.Fatalf("EvalExpression(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestEvalExpressionErrors(t *testing.T) {
+	for _, expr := range []string{"", "1 +", "(1 + 2", "1 / 0", "foo(2)", "2 3", "sqrt 4"} {
+		if _, err := EvalExpression(expr); err == nil {
+			t.Fatalf("EvalExpression(%q): expected error", expr)
+		}
+	}
+}
+
+func TestToolRegistryExecute(t *testing.T) {
+	reg := NewToolRegistry(CalculatorTool{})
+
+	call := models.LLMToolCall{ID: "call_1", Function: models.LLMFunctionCall{Name: "calculator", Arguments: `{"expression":"6*7"}`}}
+	got, err := reg.Execute(context.Background(), call, uuid.New(), uuid.New())
+	if err != nil || got != "42" {
+		t.Fatalf("Execute = %q, %v; want 42", got, err)
+	}
+
+	call.Function.Name = "missing"
+	got, err = reg.Execute(context.Background(), call, uuid.New(), uuid.New())
+	if !errors.Is(err, ErrUnknownTool) {
+		t.Fatalf("expected ErrUnknownTool, got %v", err)
+	}
+	if got == "" {
+		t.Fatal("expected an error result for the model")
+	}
+
+	if defs := reg.Definitions(); len(defs) != 1 || defs[0].Function.Name != "calculator" {
+		t.Fatalf("unexpected definitions: %+v", defs)
+	}
+}
+
+func TestToolCallAccumulator(t *testing.T) {
+	idx := func(i int) *int { return &i }
+
+	var acc toolCallAccumulator
+	acc.Add([]models.LLMToolCall{{Index: idx(0), ID: "a", Function: models.LLMFunctionCall{Name: "calculator"}}})
+	acc.Add([]models.LLMToolCall{{Index: idx(0), Function: models.LLMFunctionCall{Arguments: `{"expr`}}})
+	acc.Add([]models.LLMToolCall{{Index: idx(1), ID: "b", Function: models.LLMFunctionCall{Name: "conversation_history_lookup"}}})
+	acc.Add([]models.LLMToolCall{{Index: idx(0), Function: models.LLMFunctionCall{Arguments: `ession":"1+1"}`}}})
+
+	calls := acc.Calls()
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 calls, got %d", len(calls))
+	}
+	if calls[0].ID != "a" || calls[0].Function.Arguments != `{"expression":"1+1"}` {
+		t.Fatalf("unexpected first call: %+v", calls[0])
+	}
+	if calls[1].ID != "b" || calls[1].Function.Name != "conversation_history_lookup" || calls[1].Index != nil {
+		t.Fatalf("unexpected second call: %+v", calls[1])
+	}
+}